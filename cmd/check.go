@@ -1,13 +1,23 @@
 package cmd
 
 import (
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
 	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
 func newCheckCmd() *cobra.Command {
-	return &cobra.Command{
+	var rewritePaths []string
+
+	cmd := &cobra.Command{
 		Use:          "check",
 		Short:        "check that the api gateway config is valid",
 		Args:         cobra.ExactArgs(1),
@@ -18,7 +28,144 @@ func newCheckCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			return config.ValidateConfig(config_)
+			if err := config.ValidateConfig(config_); err != nil {
+				return err
+			}
+			config.ProbeBackends(config_)
+
+			printFailureHandling(cmd.OutOrStdout(), config_)
+			for _, path := range rewritePaths {
+				printRewrite(cmd.OutOrStdout(), config_, path)
+			}
+			return nil
 		},
 	}
+
+	cmd.Flags().StringArrayVar(&rewritePaths, "rewrite", nil, "show the routes a sample request path such as /api/v1/users?id=1, or a full URL to select a vhost, may match and how it is rewritten (repeatable)")
+	return cmd
+}
+
+// printFailureHandling 输出每个路由生效的失败判定、后端不健康的条件和恢复方式，以及所有后端都不可用时的处理方式
+// printFailureHandling prints the effective failure definition of every route, when backends are taken out and how
+// they recover, and what happens when every backend is down
+func printFailureHandling(out io.Writer, config_ *config.Config) {
+	fmt.Fprintln(out, "failure handling:")
+	for _, route := range config_.AllRoutes() {
+		passive := route.PassiveHealth.WithDefaults()
+		failureStatus := "none"
+		if len(passive.FailureStatus) > 0 {
+			statuses := make([]string, len(passive.FailureStatus))
+			for i, status := range passive.FailureStatus {
+				statuses[i] = strconv.Itoa(status)
+			}
+			failureStatus = strings.Join(statuses, ",")
+		}
+
+		var takeOut string
+		switch {
+		case route.CircuitBreaker.Enabled:
+			breaker := route.CircuitBreaker.WithDefaults()
+			takeOut = fmt.Sprintf("circuit breaker at %g%% errors over %s (min %d requests), open %s, %d half-open probes",
+				breaker.ErrorRate, breaker.Window, breaker.MinRequests, breaker.OpenDuration, breaker.HalfOpenRequests)
+		case route.HealthCheck.Enabled:
+			takeOut = fmt.Sprintf("max_fails %d, recovery by health check", passive.MaxFails)
+		default:
+			takeOut = fmt.Sprintf("max_fails %d, fail_timeout %s", passive.MaxFails, passive.FailTimeout)
+		}
+
+		fmt.Fprintf(out, "  route %s: failure_status %s; %s; all_down %s\n", route.ID(), failureStatus, takeOut, route.AllDownPolicy())
+	}
+}
+
+// printRewrite 输出示例请求路径按匹配顺序可能匹配的路由、它们的匹配条件以及每条匹配的重写规则的结果
+// printRewrite prints the routes a sample request path may match in match order with their match conditions, and the
+// result of every matching rewrite rule
+func printRewrite(out io.Writer, config_ *config.Config, sample string) {
+	fmt.Fprintln(out, sample)
+
+	// 完整的 URL 按其主机名选择虚拟主机，只有路径时使用默认虚拟主机
+	// A full URL selects the vhost by its host name, a bare path uses the default vhost
+	var host string
+	if sampleURL, err := url.Parse(sample); err == nil && sampleURL.Host != "" {
+		host = sampleURL.Host
+		sample = sampleURL.RequestURI()
+	}
+	path, query, _ := strings.Cut(sample, "?")
+
+	vhost, routes := config_.CandidateRoutes(host, path)
+	if vhost == nil {
+		fmt.Fprintln(out, "  no vhost serves this host")
+		return
+	}
+	if vhost.Name != "" {
+		fmt.Fprintf(out, "  vhost: %s\n", vhost.Name)
+	}
+	if len(routes) == 0 {
+		fmt.Fprintln(out, "  no route matches")
+		return
+	}
+
+	for _, route := range routes {
+		fmt.Fprintf(out, "  route: %s", route.ID())
+		if route.Name != "" {
+			fmt.Fprintf(out, " (%s)", route.Path)
+		}
+		if route.Priority != 0 {
+			fmt.Fprintf(out, " priority %d", route.Priority)
+		}
+		if conditions := matchConditions(route.Match); len(conditions) > 0 {
+			fmt.Fprintf(out, " when %s", strings.Join(conditions, " and "))
+		}
+		fmt.Fprintln(out)
+
+		rewrittenPath, rewrittenQuery, steps := route.Rewrite(path, query)
+		for _, step := range steps {
+			fmt.Fprintf(out, "    %s %s -> %s: %s\n", step.Rule.Match, step.Rule.From, step.Rule.To, withQuery(step.Path, step.Query))
+		}
+		if len(steps) == 0 {
+			fmt.Fprintln(out, "    no rewrite rule matches")
+		}
+		for _, group := range route.BackendGroups {
+			for _, backend := range group.Backends {
+				fmt.Fprintf(out, "    => [%s %d%%] %s%s\n", group.Name, group.Weight, backend, withQuery(rewrittenPath, rewrittenQuery))
+			}
+		}
+		if len(route.BackendGroups) == 0 {
+			for _, backend := range route.Backends {
+				fmt.Fprintf(out, "    => %s%s\n", backend, withQuery(rewrittenPath, rewrittenQuery))
+			}
+		}
+		if route.Mirror.Enabled {
+			mirror := route.Mirror.WithDefaults()
+			fmt.Fprintf(out, "    ~> [mirror %g%%] %s%s\n", *mirror.Percentage, mirror.Backend, withQuery(rewrittenPath, rewrittenQuery))
+		}
+	}
+}
+
+// matchConditions 返回路由匹配条件的可读描述
+// matchConditions returns readable descriptions of the route match conditions
+func matchConditions(match config.RouteMatch) []string {
+	var conditions []string
+	if len(match.Methods) > 0 {
+		conditions = append(conditions, "method in "+strings.Join(match.Methods, ","))
+	}
+	if len(match.Hosts) > 0 {
+		conditions = append(conditions, "host in "+strings.Join(match.Hosts, ","))
+	}
+	for _, name := range slices.Sorted(maps.Keys(match.Headers)) {
+		conditions = append(conditions, "header "+name+"="+match.Headers[name])
+	}
+	for _, name := range slices.Sorted(maps.Keys(match.Query)) {
+		conditions = append(conditions, "query "+name+"="+match.Query[name])
+	}
+	return conditions
+}
+
+// withQuery 拼接路径和查询字符串
+// withQuery joins a path and a query string
+func withQuery(path, query string) string {
+	if query == "" {
+		return path
+	}
+	return path + "?" + query
 }
@@ -8,7 +8,9 @@ import (
 )
 
 func newServeCmd(gitCommit string) *cobra.Command {
-	return &cobra.Command{
+	var watch bool
+
+	cmd := &cobra.Command{
 		Use:          "serve",
 		Short:        "serve the api gateway with the given config",
 		Args:         cobra.ExactArgs(1),
@@ -22,8 +24,12 @@ func newServeCmd(gitCommit string) *cobra.Command {
 			if err := config.ValidateConfig(config_); err != nil {
 				return err
 			}
-			router.Run(config_, gitCommit)
+			config.ProbeBackends(config_)
+			router.Run(config_, args[0], watch, gitCommit)
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVarP(&watch, "watch", "w", true, "Reload the config when the config file changes (SIGHUP always reloads)")
+	return cmd
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/go-redis/redis/v8"
 	loggerPkg "github.com/nerdneilsfield/shlogin/pkg/logger"
 	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/metrics"
 	"go.uber.org/zap"
 )
 
@@ -292,9 +293,11 @@ func (m *CacheManager) Get(key string) (*CacheItem, error) {
 	logger.Debug("Cache manager: get operation", zap.String("key", key))
 	value, err := m.cache.Get(key)
 	if err != nil {
+		metrics.CacheMiss()
 		logger.Debug("Cache manager: get operation failed", zap.String("key", key), zap.Error(err))
 		return nil, err
 	}
+	metrics.CacheHit()
 	logger.Debug("Cache manager: get operation succeeded", zap.String("key", key), zap.Int("size", len(value.Body)))
 	return value, nil
 }
@@ -306,8 +309,10 @@ func (m *CacheManager) Set(key string, value *CacheItem, ttl int) error {
 	err := m.cache.Set(key, value, ttl)
 	if err != nil {
 		logger.Debug("Cache manager: set operation failed", zap.String("key", key), zap.Error(err))
+		return err
 	}
-	return err
+	metrics.CacheSet()
+	return nil
 }
 
 // Delete removes a value from the cache by key
@@ -321,6 +326,15 @@ func (m *CacheManager) Delete(key string) error {
 	return err
 }
 
+// RedisClient returns the Redis client and key prefix of the cache, or a nil client when Redis is not used
+// 返回缓存使用的 Redis 客户端和键前缀，未使用 Redis 时客户端为 nil
+func (m *CacheManager) RedisClient() (*redis.Client, string) {
+	if redisCache, ok := m.cache.(*RedisCache); ok {
+		return redisCache.client, redisCache.prefix
+	}
+	return nil, ""
+}
+
 // Close cleans up resources used by the cache
 // 清理缓存使用的资源
 func (m *CacheManager) Close() error {
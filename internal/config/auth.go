@@ -0,0 +1,296 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/htpasswd"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/jwtauth"
+	"go.uber.org/zap"
+)
+
+// API 密钥认证的默认值
+// Defaults for API key authentication
+const (
+	DefaultAPIKeyHeader         = "X-API-Key"
+	DefaultAPIKeyIdentityHeader = "X-API-Key-Name"
+	DefaultJWTHeader            = "Authorization"
+	DefaultBasicAuthRealm       = "Restricted"
+)
+
+type APIKeys struct {
+	File string   `toml:"file"` // TOML file with more [[key]] entries, relative to the config file / 包含更多 [[key]] 的 TOML 文件，相对于配置文件
+	Keys []APIKey `toml:"key"`  // API keys / API 密钥列表
+}
+
+type APIKey struct {
+	Name   string   `toml:"name"`   // Identity passed to backends / 传递给后端的身份
+	Key    string   `toml:"key"`    // Secret key sent by clients / 客户端发送的密钥
+	Routes []string `toml:"routes"` // Route names or paths the key may access, all when empty / 允许访问的路由名称或路径，为空时允许全部
+}
+
+type APIKeyAuth struct {
+	Enabled        bool   `toml:"enabled"`         // Require an API key / 需要 API 密钥
+	Header         string `toml:"header"`          // Header carrying the key (default X-API-Key) / 携带密钥的请求头（默认 X-API-Key）
+	QueryParam     string `toml:"query_param"`     // Query parameter carrying the key, e.g. "api_key" (empty = header only) / 携带密钥的查询参数（为空时只使用请求头）
+	IdentityHeader string `toml:"identity_header"` // Header with the key name sent to the backend (default X-API-Key-Name) / 发送给后端的密钥名称请求头
+	ForwardKey     bool   `toml:"forward_key"`     // Forward the key to the backend instead of stripping it / 将密钥转发给后端而不是移除
+}
+
+type JWTAuth struct {
+	Enabled        bool              `toml:"enabled"`          // Require a signed JWT / 需要签名的 JWT
+	Algorithms     []string          `toml:"algorithms"`       // Allowed algorithms: HS256, RS256, ES256 (empty = all the keys support) / 允许的算法（为空时允许密钥支持的全部算法）
+	Secret         string            `toml:"secret"`           // HS256 shared secret / HS256 共享密钥
+	SecretFile     string            `toml:"secret_file"`      // File with the HS256 shared secret, relative to the config file / 包含 HS256 共享密钥的文件，相对于配置文件
+	PublicKeyFile  string            `toml:"public_key_file"`  // PEM RSA or EC public keys or certificates / PEM 格式的 RSA 或 EC 公钥或证书
+	JWKSFile       string            `toml:"jwks_file"`        // Local JWKS file / 本地 JWKS 文件
+	Issuer         string            `toml:"issuer"`           // Required iss claim (empty = not checked) / 要求的 iss 声明（为空时不检查）
+	Audience       []string          `toml:"audience"`         // Accepted aud values (empty = not checked) / 可接受的 aud 值（为空时不检查）
+	RequiredClaims []string          `toml:"required_claims"`  // Claims that must be present / 必须存在的声明
+	ClaimHeaders   map[string]string `toml:"claim_headers"`    // Claim to request header forwarded to the backend / 转发给后端的声明与请求头映射
+	CacheBySubject bool              `toml:"cache_by_subject"` // Include the sub claim in the cache key, tokens without sub are rejected / 缓存键包含 sub 声明，没有 sub 的令牌会被拒绝
+	Leeway         time.Duration     `toml:"leeway"`           // Clock skew allowed for exp and nbf (default 0) / exp 和 nbf 允许的时钟偏差（默认0）
+	Header         string            `toml:"header"`           // Header carrying the token (default Authorization with Bearer scheme) / 携带令牌的请求头（默认 Authorization，使用 Bearer）
+	StripToken     bool              `toml:"strip_token"`      // Remove the token before forwarding / 转发前移除令牌
+}
+
+type BasicAuth struct {
+	Enabled            bool   `toml:"enabled"`             // Require a user name and password / 需要用户名和密码
+	HtpasswdFile       string `toml:"htpasswd_file"`       // htpasswd file with bcrypt, {SHA}, $5$ or $6$ hashes, relative to the config file / 包含 bcrypt、{SHA}、$5$ 或 $6$ 哈希的 htpasswd 文件，相对于配置文件
+	Realm              string `toml:"realm"`               // Realm of the WWW-Authenticate challenge (default Restricted) / WWW-Authenticate 质询的 realm（默认 Restricted）
+	StripAuthorization bool   `toml:"strip_authorization"` // Remove the Authorization header before forwarding / 转发前移除 Authorization 请求头
+	UserHeader         string `toml:"user_header"`         // Header with the user name sent to the backend (empty = not sent) / 发送给后端的用户名请求头（为空时不发送）
+}
+
+// WithDefaults returns a copy of the basic auth config with defaults filled in
+// 返回填充了默认值的 Basic 认证配置副本
+func (b BasicAuth) WithDefaults() BasicAuth {
+	if b.Realm == "" {
+		b.Realm = DefaultBasicAuthRealm
+	}
+	return b
+}
+
+// WithDefaults returns a copy of the JWT auth config with defaults filled in
+// 返回填充了默认值的 JWT 认证配置副本
+func (j JWTAuth) WithDefaults() JWTAuth {
+	if j.Header == "" {
+		j.Header = DefaultJWTHeader
+	}
+	return j
+}
+
+// KeySources returns the key sources of the JWT auth config
+// 返回 JWT 认证配置的密钥来源
+func (j JWTAuth) KeySources() jwtauth.KeySources {
+	return jwtauth.KeySources{
+		Secret:        j.Secret,
+		SecretFile:    j.SecretFile,
+		PublicKeyFile: j.PublicKeyFile,
+		JWKSFile:      j.JWKSFile,
+	}
+}
+
+// WithDefaults returns a copy of the API key auth config with defaults filled in
+// 返回填充了默认值的 API 密钥认证配置副本
+func (a APIKeyAuth) WithDefaults() APIKeyAuth {
+	if a.Header == "" {
+		a.Header = DefaultAPIKeyHeader
+	}
+	if a.IdentityHeader == "" {
+		a.IdentityHeader = DefaultAPIKeyIdentityHeader
+	}
+	return a
+}
+
+// AllowsRoute reports whether the key may access the route, routes are listed by ID, or by path outside vhosts
+// 判断密钥是否可以访问该路由，路由按标识列出，虚拟主机之外的路由也可以按路径列出
+func (k APIKey) AllowsRoute(route Route) bool {
+	return len(k.Routes) == 0 || slices.Contains(k.Routes, route.ID()) || route.VHost == "" && slices.Contains(k.Routes, route.Path)
+}
+
+// resolvePath 将相对路径解析为相对于配置文件所在目录的路径
+// resolvePath resolves a relative path against the directory of the config file
+func resolvePath(configPath, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(configPath), path)
+}
+
+// loadAPIKeysFile 读取 API 密钥文件并追加到配置中的密钥之后
+// loadAPIKeysFile reads the API keys file and appends its keys to those in the config
+func loadAPIKeysFile(config *Config) error {
+	if config.APIKeys.File == "" {
+		return nil
+	}
+
+	var file struct {
+		Keys []APIKey `toml:"key"`
+	}
+	if _, err := toml.DecodeFile(config.APIKeys.File, &file); err != nil {
+		logger.Error("failed to parse API keys file", zap.String("file", config.APIKeys.File), zap.Error(err))
+		return fmt.Errorf("failed to parse API keys file: %v", err)
+	}
+
+	logger.Debug("API keys file loaded", zap.String("file", config.APIKeys.File), zap.Int("keyCount", len(file.Keys)))
+	config.APIKeys.Keys = append(config.APIKeys.Keys, file.Keys...)
+	return nil
+}
+
+// validateAPIKeys validates the API keys
+// 验证 API 密钥
+func validateAPIKeys(config *Config) error {
+	names := make(map[string]bool, len(config.APIKeys.Keys))
+	secrets := make(map[string]bool, len(config.APIKeys.Keys))
+
+	for _, key := range config.APIKeys.Keys {
+		if key.Name == "" || key.Key == "" {
+			logger.Error("API key name and key must not be empty", zap.String("name", key.Name))
+			return fmt.Errorf("API key name and key must not be empty")
+		}
+		if names[key.Name] {
+			logger.Error("API key name is duplicated", zap.String("name", key.Name))
+			return fmt.Errorf("API key name is duplicated: %s", key.Name)
+		}
+		if secrets[key.Key] {
+			logger.Error("API key is duplicated", zap.String("name", key.Name))
+			return fmt.Errorf("API key of %s is duplicated", key.Name)
+		}
+		names[key.Name] = true
+		secrets[key.Key] = true
+
+		if len(key.Key) < 16 {
+			logger.Warn("API key is shorter than 16 characters, use a longer random key", zap.String("name", key.Name))
+		}
+
+		for _, routePath := range key.Routes {
+			if !slices.ContainsFunc(config.AllRoutes(), func(route Route) bool { return route.ID() == routePath || route.VHost == "" && route.Path == routePath }) {
+				logger.Error("API key allows an unknown route", zap.String("name", key.Name), zap.String("route", routePath))
+				return fmt.Errorf("API key %s allows an unknown route: %s", key.Name, routePath)
+			}
+		}
+	}
+
+	for _, route := range config.AllRoutes() {
+		if route.APIKeyAuth.Enabled && len(config.APIKeys.Keys) == 0 {
+			logger.Warn("route requires an API key but no API keys are configured", zap.String("path", route.Path))
+		}
+	}
+
+	return nil
+}
+
+// validateAPIKeyAuth validates the API key authentication of a route
+// 验证路由的 API 密钥认证
+func validateAPIKeyAuth(route Route) error {
+	if !route.APIKeyAuth.Enabled {
+		return nil
+	}
+	auth := route.APIKeyAuth.WithDefaults()
+
+	if strings.ContainsAny(auth.Header, " :") || strings.ContainsAny(auth.IdentityHeader, " :") {
+		logger.Error("API key header names are not valid",
+			zap.String("path", route.Path),
+			zap.String("header", auth.Header),
+			zap.String("identity_header", auth.IdentityHeader))
+		return fmt.Errorf("API key header names are not valid")
+	}
+
+	if strings.EqualFold(auth.Header, auth.IdentityHeader) {
+		logger.Error("API key header and identity header must differ", zap.String("path", route.Path), zap.String("header", auth.Header))
+		return fmt.Errorf("API key header and identity header must differ")
+	}
+
+	return nil
+}
+
+// validateJWTAuth validates the JWT authentication of a route and loads its keys
+// 验证路由的 JWT 认证并读取密钥
+func validateJWTAuth(route Route) error {
+	if !route.JWT.Enabled {
+		return nil
+	}
+	auth := route.JWT.WithDefaults()
+
+	for _, algorithm := range auth.Algorithms {
+		if !slices.Contains([]string{jwtauth.HS256, jwtauth.RS256, jwtauth.ES256}, algorithm) {
+			logger.Error("JWT algorithm is not supported", zap.String("path", route.Path), zap.String("algorithm", algorithm))
+			return fmt.Errorf("JWT algorithm is not supported: %s", algorithm)
+		}
+	}
+
+	if _, err := jwtauth.LoadKeys(auth.KeySources()); err != nil {
+		logger.Error("failed to load JWT keys", zap.String("path", route.Path), zap.Error(err))
+		return fmt.Errorf("failed to load JWT keys of route %s: %v", route.Path, err)
+	}
+
+	if auth.Leeway < 0 {
+		logger.Error("JWT leeway must not be negative", zap.String("path", route.Path), zap.Duration("leeway", auth.Leeway))
+		return fmt.Errorf("JWT leeway must not be negative")
+	}
+
+	if strings.ContainsAny(auth.Header, " :") {
+		logger.Error("JWT header name is not valid", zap.String("path", route.Path), zap.String("header", auth.Header))
+		return fmt.Errorf("JWT header name is not valid: %s", auth.Header)
+	}
+
+	for claim, header := range auth.ClaimHeaders {
+		if header == "" || strings.ContainsAny(header, " :") {
+			logger.Error("JWT claim header name is not valid", zap.String("path", route.Path), zap.String("claim", claim), zap.String("header", header))
+			return fmt.Errorf("JWT claim header name is not valid: %s", header)
+		}
+		if strings.EqualFold(header, auth.Header) {
+			logger.Error("JWT claim header must differ from the token header", zap.String("path", route.Path), zap.String("header", header))
+			return fmt.Errorf("JWT claim header must differ from the token header: %s", header)
+		}
+	}
+
+	if auth.Secret != "" {
+		logger.Warn("JWT secret is set inline, prefer secret_file", zap.String("path", route.Path))
+	}
+
+	return nil
+}
+
+// validateBasicAuth validates the basic authentication of a route and loads its htpasswd file
+// 验证路由的 Basic 认证并读取 htpasswd 文件
+func validateBasicAuth(route Route) error {
+	if !route.BasicAuth.Enabled {
+		return nil
+	}
+	auth := route.BasicAuth.WithDefaults()
+
+	if auth.HtpasswdFile == "" {
+		logger.Error("basic auth requires an htpasswd file", zap.String("path", route.Path))
+		return fmt.Errorf("basic auth of route %s requires an htpasswd file", route.Path)
+	}
+
+	file, err := htpasswd.Load(auth.HtpasswdFile)
+	if err != nil {
+		logger.Error("failed to load htpasswd file", zap.String("path", route.Path), zap.Error(err))
+		return fmt.Errorf("failed to load htpasswd file of route %s: %v", route.Path, err)
+	}
+	if file.Len() == 0 {
+		logger.Warn("htpasswd file has no users, all requests will be rejected",
+			zap.String("path", route.Path),
+			zap.String("file", auth.HtpasswdFile))
+	}
+
+	if strings.ContainsAny(auth.Realm, "\"\r\n") {
+		logger.Error("basic auth realm must not contain quotes or line breaks", zap.String("path", route.Path), zap.String("realm", auth.Realm))
+		return fmt.Errorf("basic auth realm must not contain quotes or line breaks")
+	}
+
+	if strings.ContainsAny(auth.UserHeader, " :") || strings.EqualFold(auth.UserHeader, "Authorization") {
+		logger.Error("basic auth user header name is not valid", zap.String("path", route.Path), zap.String("user_header", auth.UserHeader))
+		return fmt.Errorf("basic auth user header name is not valid: %s", auth.UserHeader)
+	}
+
+	return nil
+}
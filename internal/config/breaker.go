@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 熔断器的默认值
+// Defaults for circuit breakers
+const (
+	DefaultCircuitBreakerWindow           = 10 * time.Second
+	DefaultCircuitBreakerMinRequests      = 20
+	DefaultCircuitBreakerErrorRate        = 50
+	DefaultCircuitBreakerOpenDuration     = 30 * time.Second
+	DefaultCircuitBreakerHalfOpenRequests = 3
+)
+
+type CircuitBreaker struct {
+	Enabled          bool          `toml:"enabled"`            // Enable a circuit breaker per backend / 为每个后端启用熔断器
+	Window           time.Duration `toml:"window"`             // Rolling window of the error rate (default 10s) / 统计错误率的滚动窗口（默认10秒）
+	MinRequests      int           `toml:"min_requests"`       // Requests in the window before the circuit may open (default 20) / 窗口内至少多少个请求才会打开（默认20）
+	ErrorRate        float64       `toml:"error_rate"`         // Error rate percentage opening the circuit (default 50) / 打开熔断器的错误率百分比（默认50）
+	OpenDuration     time.Duration `toml:"open_duration"`      // Time open before half-opening (default 30s) / 打开后多久进入半开状态（默认30秒）
+	HalfOpenRequests int           `toml:"half_open_requests"` // Probe requests while half-open (default 3) / 半开状态允许的探测请求数（默认3）
+}
+
+// WithDefaults returns a copy of the circuit breaker config with defaults filled in
+// 返回填充了默认值的熔断器配置副本
+func (b CircuitBreaker) WithDefaults() CircuitBreaker {
+	if b.Window == 0 {
+		b.Window = DefaultCircuitBreakerWindow
+	}
+	if b.MinRequests == 0 {
+		b.MinRequests = DefaultCircuitBreakerMinRequests
+	}
+	if b.ErrorRate == 0 {
+		b.ErrorRate = DefaultCircuitBreakerErrorRate
+	}
+	if b.OpenDuration == 0 {
+		b.OpenDuration = DefaultCircuitBreakerOpenDuration
+	}
+	if b.HalfOpenRequests == 0 {
+		b.HalfOpenRequests = DefaultCircuitBreakerHalfOpenRequests
+	}
+	return b
+}
+
+// validateCircuitBreaker validates the circuit breaker configuration of a route
+// 验证路由的熔断器配置
+func validateCircuitBreaker(route Route) error {
+	if !route.CircuitBreaker.Enabled {
+		return nil
+	}
+	breaker := route.CircuitBreaker.WithDefaults()
+
+	if breaker.Window < 0 || breaker.OpenDuration < 0 {
+		logger.Error("circuit breaker window and open duration must not be negative",
+			zap.String("path", route.Path),
+			zap.Duration("window", breaker.Window),
+			zap.Duration("open_duration", breaker.OpenDuration))
+		return fmt.Errorf("circuit breaker window and open duration of route %s must not be negative", route.ID())
+	}
+	if breaker.MinRequests < 0 || breaker.HalfOpenRequests < 0 {
+		logger.Error("circuit breaker min_requests and half_open_requests must not be negative",
+			zap.String("path", route.Path),
+			zap.Int("min_requests", breaker.MinRequests),
+			zap.Int("half_open_requests", breaker.HalfOpenRequests))
+		return fmt.Errorf("circuit breaker min_requests and half_open_requests of route %s must not be negative", route.ID())
+	}
+	if breaker.ErrorRate < 0 || breaker.ErrorRate > 100 {
+		logger.Error("circuit breaker error rate must be between 0 and 100", zap.String("path", route.Path), zap.Float64("error_rate", breaker.ErrorRate))
+		return fmt.Errorf("circuit breaker error rate of route %s must be between 0 and 100", route.ID())
+	}
+	return nil
+}
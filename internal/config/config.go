@@ -3,10 +3,15 @@ package config
 import (
 	"embed"
 	"fmt"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	loggerPkg "github.com/nerdneilsfield/shlogin/pkg/logger"
@@ -20,11 +25,18 @@ var logger = loggerPkg.GetLogger()
 var exampleConfigToml embed.FS
 
 type Config struct {
-	Port        int     `toml:"port"`
-	Host        string  `toml:"host"`
-	LogFilePath string  `toml:"log_file_path"`
-	Cache       Cache   `toml:"cache"`
-	Routes      []Route `toml:"route"`
+	Port        int       `toml:"port"`
+	Host        string    `toml:"host"`
+	LogFilePath string    `toml:"log_file_path"`
+	TLS         TLS       `toml:"tls"`       // HTTPS listener / HTTPS 监听
+	Forwarded   Forwarded `toml:"forwarded"` // Trusted proxies and forwarding headers / 受信任的代理和转发请求头
+	Timeouts    Timeouts  `toml:"timeouts"`  // Default backend timeouts for all routes / 所有路由的默认后端超时
+	Metrics     Metrics   `toml:"metrics"`   // Prometheus metrics endpoint / Prometheus 指标端点
+	Admin       Admin     `toml:"admin"`     // Admin API / 管理 API
+	APIKeys     APIKeys   `toml:"api_keys"`  // API keys for routes with api_key_auth / 用于 api_key_auth 路由的 API 密钥
+	Cache       Cache     `toml:"cache"`
+	Routes      []Route   `toml:"route"` // Routes of the default vhost / 默认虚拟主机的路由
+	VHosts      []VHost   `toml:"vhost"` // Virtual hosts with their own routes / 拥有独立路由的虚拟主机
 }
 
 type Cache struct {
@@ -35,16 +47,317 @@ type Cache struct {
 	RedisPrefix string `toml:"redis_prefix"` // Redis key prefix / Redis键前缀
 }
 
+// DefaultMetricsPath 指标端点的默认路径
+// DefaultMetricsPath is the default path of the metrics endpoint
+const DefaultMetricsPath = "/metrics"
+
+type Metrics struct {
+	Enabled bool   `toml:"enabled"` // Expose Prometheus metrics / 暴露 Prometheus 指标
+	Path    string `toml:"path"`    // Metrics endpoint path (default /metrics) / 指标端点路径（默认 /metrics）
+	Listen  string `toml:"listen"`  // Separate bind address, e.g. "127.0.0.1:9090"; empty serves on the gateway port / 单独的监听地址，为空时使用网关端口
+}
+
+// WithDefaults returns a copy of the metrics config with defaults filled in
+// 返回填充了默认值的指标配置副本
+func (m Metrics) WithDefaults() Metrics {
+	if m.Path == "" {
+		m.Path = DefaultMetricsPath
+	}
+	return m
+}
+
+// DefaultAdminPrefix 管理 API 的默认路径前缀
+// DefaultAdminPrefix is the default path prefix of the admin API
+const DefaultAdminPrefix = "/_admin"
+
+type Admin struct {
+	Enabled bool   `toml:"enabled"` // Serve the admin API / 提供管理 API
+	Token   string `toml:"token"`   // Bearer token required on every admin request / 每个管理请求都需要的 Bearer 令牌
+	Prefix  string `toml:"prefix"`  // Path prefix of the admin API (default /_admin) / 管理 API 的路径前缀（默认 /_admin）
+	Listen  string `toml:"listen"`  // Separate bind address, e.g. "127.0.0.1:9091"; empty serves on the gateway port / 单独的监听地址，为空时使用网关端口
+}
+
+// WithDefaults returns a copy of the admin config with defaults filled in
+// 返回填充了默认值的管理 API 配置副本
+func (a Admin) WithDefaults() Admin {
+	if a.Prefix == "" {
+		a.Prefix = DefaultAdminPrefix
+	}
+	a.Prefix = strings.TrimRight(a.Prefix, "/")
+	return a
+}
+
 type Route struct {
+	Name          string            `toml:"name"`           // Unique route name, required when routes share a path (default the path) / 路由名称，多个路由使用同一路径时必须唯一（默认为路径）
 	Path          string            `toml:"path"`           // Route path / 路由路径
+	Priority      int               `toml:"priority"`       // Higher priority routes are matched first (default 0) / 优先级高的路由先匹配（默认0）
+	Match         RouteMatch        `toml:"match"`          // Match conditions besides the path / 路径之外的匹配条件
 	Backends      []string          `toml:"backends"`       // Backend service URLs / 后端服务URL列表
 	UaClient      string            `toml:"ua_client"`      // User-Agent / 用户代理
 	CacheTTL      int               `toml:"cache_ttl"`      // Cache TTL in seconds (0 = no cache) / 缓存时间，单位为秒，0表示不缓存
 	CacheEnable   bool              `toml:"cache_enable"`   // Enable cache for this route / 是否启用缓存，默认跟随全局设置
 	CachePaths    []string          `toml:"cache_paths"`    // Relative paths that can be cached / 可以被缓存的相对路径列表
 	CustomHeaders map[string]string `toml:"custom_headers"` // Custom headers to add to requests / 添加到请求中的自定义头部
+	Headers       HeaderRules       `toml:"headers"`        // Request and response header rules / 请求头和响应头规则
 	RewriteFrom   string            `toml:"rewrite_from"`   // Path prefix to rewrite from / 要重写的路径前缀
 	RewriteTo     string            `toml:"rewrite_to"`     // Path prefix to rewrite to / 重写到的路径前缀
+	Rewrites      []RewriteRule     `toml:"rewrite"`        // Ordered rewrite rules applied after rewrite_from / 按顺序执行的重写规则，在 rewrite_from 之后执行
+	HealthCheck   HealthCheck       `toml:"health_check"`   // Active health check / 主动健康检查
+	PassiveHealth PassiveHealth     `toml:"passive_health"` // Failure thresholds of live traffic / 真实流量的失败阈值
+
+	LBStrategy     string         `toml:"lb_strategy"`     // Load balancing strategy (default round_robin) / 负载均衡策略（默认 round_robin）
+	LBHashKey      string         `toml:"lb_hash_key"`     // consistent_hash key: client_ip, header:<name> or cookie:<name> / 一致性哈希键
+	BackendWeights map[string]int `toml:"backend_weights"` // Backend URL to weight for weighted_round_robin / weighted_round_robin 的后端权重
+
+	BackendGroups []BackendGroup `toml:"backend_group"` // Named backend groups splitting traffic by weight, replace backends / 按权重拆分流量的命名后端分组，代替 backends
+	Split         TrafficSplit   `toml:"split"`         // Sticky assignment to backend groups / 后端分组的粘性分配
+
+	Retry          Retry          `toml:"retry"`           // Retry failed requests on another backend / 在其他后端上重试失败的请求
+	CircuitBreaker CircuitBreaker `toml:"circuit_breaker"` // Stop sending traffic to failing backends / 停止向失败的后端发送流量
+
+	Mirror Mirror `toml:"mirror"` // Send copies of requests to a mirror backend / 将请求副本发送到镜像后端
+
+	RateLimit RateLimit `toml:"rate_limit"` // Limit requests per client / 按客户端限制请求速率
+
+	APIKeyAuth APIKeyAuth `toml:"api_key_auth"` // Require an API key / 需要 API 密钥
+	JWT        JWTAuth    `toml:"jwt"`          // Require a signed JWT / 需要签名的 JWT
+	BasicAuth  BasicAuth  `toml:"basic_auth"`   // Require a user name and password / 需要用户名和密码
+
+	ClientCert ClientCertAuth `toml:"client_cert"` // Require a verified client certificate / 需要经过验证的客户端证书
+
+	Streaming          bool `toml:"streaming"`             // Stream request and response bodies instead of buffering / 流式转发请求体和响应体，不做完整缓冲
+	StreamCacheMaxSize int  `toml:"stream_cache_max_size"` // Max cacheable response size in bytes in streaming mode (default 1 MiB) / 流式模式下可缓存响应的最大字节数（默认1 MiB）
+
+	WebSocketIdleTimeout time.Duration `toml:"websocket_idle_timeout"` // Close idle WebSocket connections after this duration (default 60s) / WebSocket 连接空闲多久后关闭（默认60秒）
+
+	Timeouts        Timeouts            `toml:"timeouts"`         // Backend timeouts, override [timeouts] / 后端超时，覆盖全局 [timeouts]
+	BackendTimeouts map[string]Timeouts `toml:"backend_timeouts"` // Backend URL to timeouts, override the route timeouts / 单个后端的超时，覆盖路由超时
+
+	UpstreamTLS UpstreamTLS            `toml:"upstream_tls"` // TLS settings to connect to HTTPS backends / 连接 HTTPS 后端的 TLS 设置
+	BackendTLS  map[string]UpstreamTLS `toml:"backend_tls"`  // Backend URL to TLS settings, override upstream_tls / 单个后端的 TLS 设置，覆盖 upstream_tls
+
+	VHost       string `toml:"-"` // Name of the enclosing vhost / 所属虚拟主机的名称
+	CachePrefix string `toml:"-"` // Cache key prefix of the enclosing vhost / 所属虚拟主机的缓存键前缀
+}
+
+// DefaultStreamCacheMaxSize 流式模式下可缓存响应的默认最大字节数
+// DefaultStreamCacheMaxSize is the default max size in bytes of a cacheable response in streaming mode
+const DefaultStreamCacheMaxSize = 1 << 20
+
+// DefaultWebSocketIdleTimeout WebSocket 连接默认的空闲超时
+// DefaultWebSocketIdleTimeout is the default idle timeout of WebSocket connections
+const DefaultWebSocketIdleTimeout = 60 * time.Second
+
+// 后端超时的默认值
+// Defaults for backend timeouts
+const (
+	DefaultConnectTimeout        = 5 * time.Second
+	DefaultResponseHeaderTimeout = 30 * time.Second
+	DefaultTotalTimeout          = 60 * time.Second
+)
+
+type Timeouts struct {
+	Connect        time.Duration `toml:"connect"`         // Timeout to connect to the backend / 连接后端的超时时间
+	ResponseHeader time.Duration `toml:"response_header"` // Timeout until the backend starts responding / 等待后端开始响应的超时时间
+	Total          time.Duration `toml:"total"`           // Timeout of the whole proxied request / 整个代理请求的超时时间
+}
+
+// Merge returns a copy of the timeouts with unset values taken from fallback
+// 返回合并后的超时配置副本，未设置的值取自 fallback
+func (t Timeouts) Merge(fallback Timeouts) Timeouts {
+	if t.Connect == 0 {
+		t.Connect = fallback.Connect
+	}
+	if t.ResponseHeader == 0 {
+		t.ResponseHeader = fallback.ResponseHeader
+	}
+	if t.Total == 0 {
+		t.Total = fallback.Total
+	}
+	return t
+}
+
+// WithDefaults returns a copy of the timeouts with defaults filled in
+// 返回填充了默认值的超时配置副本
+func (t Timeouts) WithDefaults() Timeouts {
+	return t.Merge(Timeouts{
+		Connect:        DefaultConnectTimeout,
+		ResponseHeader: DefaultResponseHeaderTimeout,
+		Total:          DefaultTotalTimeout,
+	})
+}
+
+// TimeoutsFor returns the timeouts for a backend of the route, falling back to the route timeouts
+// 返回路由中某个后端的超时配置，未设置的值使用路由超时
+func (r Route) TimeoutsFor(backend string) Timeouts {
+	return r.BackendTimeouts[backend].Merge(r.Timeouts)
+}
+
+// UpstreamTLSFor returns the upstream TLS config for a backend of the route, falling back to the route config
+// 返回路由中某个后端的上游 TLS 配置，未设置的值使用路由配置
+func (r Route) UpstreamTLSFor(backend string) UpstreamTLS {
+	return r.BackendTLS[backend].Merge(r.UpstreamTLS)
+}
+
+// 可重试的错误类型
+// Retryable error kinds
+const (
+	RetryOnConnectFailure = "connect_failure" // Could not connect to the backend / 无法连接后端
+	RetryOnTimeout        = "timeout"         // The backend did not answer in time / 后端未及时响应
+	RetryOnReset          = "reset"           // The connection was closed or reset / 连接被关闭或重置
+)
+
+// 重试的默认值
+// Defaults for retries
+const (
+	DefaultRetryBackoff    = 50 * time.Millisecond
+	DefaultRetryMaxBackoff = time.Second
+)
+
+// DefaultRetryOnErrors 默认可重试的错误类型
+// DefaultRetryOnErrors are the error kinds retried by default
+var DefaultRetryOnErrors = []string{RetryOnConnectFailure, RetryOnTimeout, RetryOnReset}
+
+type Retry struct {
+	Attempts           int           `toml:"attempts"`             // Max attempts including the first, 0/1 = no retry / 最大尝试次数（含首次），0或1表示不重试
+	RetryOnStatus      []int         `toml:"retry_on_status"`      // Backend status codes to retry, e.g. [502, 503] / 需要重试的后端状态码
+	RetryOnErrors      []string      `toml:"retry_on_errors"`      // connect_failure, timeout, reset (default all) / 需要重试的错误类型（默认全部）
+	AllowNonIdempotent bool          `toml:"allow_non_idempotent"` // Also retry POST/PATCH/... / 同时重试非幂等方法
+	PerTryTimeout      time.Duration `toml:"per_try_timeout"`      // Timeout of each attempt, e.g. "2s" (0 = none) / 每次尝试的超时时间
+	Backoff            time.Duration `toml:"backoff"`              // Base backoff between attempts (default 50ms) / 重试间隔的基础退避时间
+	MaxBackoff         time.Duration `toml:"max_backoff"`          // Max backoff between attempts (default 1s) / 最大退避时间
+}
+
+// WithDefaults returns a copy of the retry config with defaults filled in
+// 返回填充了默认值的重试配置副本
+func (r Retry) WithDefaults() Retry {
+	if r.Attempts < 1 {
+		r.Attempts = 1
+	}
+	if len(r.RetryOnErrors) == 0 {
+		r.RetryOnErrors = DefaultRetryOnErrors
+	}
+	if r.Backoff == 0 {
+		r.Backoff = DefaultRetryBackoff
+	}
+	if r.MaxBackoff == 0 {
+		r.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	return r
+}
+
+// 负载均衡策略
+// Load balancing strategies
+const (
+	LBStrategyRoundRobin         = "round_robin"
+	LBStrategyWeightedRoundRobin = "weighted_round_robin"
+	LBStrategyLeastConnections   = "least_connections"
+	LBStrategyLeastResponseTime  = "least_response_time"
+	LBStrategyRandomTwoChoices   = "random_two_choices"
+	LBStrategyConsistentHash     = "consistent_hash"
+)
+
+// 一致性哈希键的来源
+// Sources of the consistent hash key
+const (
+	LBHashKeyClientIP     = "client_ip"
+	LBHashKeyHeaderPrefix = "header:"
+	LBHashKeyCookiePrefix = "cookie:"
+)
+
+// 限流算法
+// Rate limiting algorithms
+const (
+	RateLimitTokenBucket   = "token_bucket"
+	RateLimitSlidingWindow = "sliding_window"
+)
+
+// 限流键的来源
+// Sources of the rate limit key
+const (
+	RateLimitKeyClientIP     = "client_ip"
+	RateLimitKeyAPIKey       = "api_key"
+	RateLimitKeyHeaderPrefix = "header:"
+)
+
+// 限流的默认值
+// Defaults for rate limiting
+const (
+	DefaultRateLimitPeriod       = time.Second
+	DefaultRateLimitAPIKeyHeader = "X-API-Key"
+)
+
+type RateLimit struct {
+	Enabled   bool          `toml:"enabled"`   // Enable rate limiting / 启用限流
+	Algorithm string        `toml:"algorithm"` // token_bucket (default) or sliding_window / 限流算法，token_bucket（默认）或 sliding_window
+	Rate      int           `toml:"rate"`      // Requests allowed per period / 每个周期允许的请求数
+	Period    time.Duration `toml:"period"`    // Period of the rate, e.g. "1m" (default 1s) / 速率的周期，例如 "1m"（默认1秒）
+	Burst     int           `toml:"burst"`     // Token bucket capacity (default rate) / 令牌桶容量（默认等于 rate）
+	Key       string        `toml:"key"`       // client_ip (default), api_key or header:<name> / 限流键，client_ip（默认）、api_key 或 header:<name>
+}
+
+// WithDefaults returns a copy of the rate limit config with defaults filled in
+// 返回填充了默认值的限流配置副本
+func (r RateLimit) WithDefaults() RateLimit {
+	if r.Algorithm == "" {
+		r.Algorithm = RateLimitTokenBucket
+	}
+	if r.Period == 0 {
+		r.Period = DefaultRateLimitPeriod
+	}
+	if r.Burst == 0 {
+		r.Burst = r.Rate
+	}
+	if r.Key == "" {
+		r.Key = RateLimitKeyClientIP
+	}
+	return r
+}
+
+// 主动健康检查的默认值
+// Defaults for active health checks
+const (
+	DefaultHealthCheckPath     = "/"
+	DefaultHealthCheckMethod   = "GET"
+	DefaultHealthCheckInterval = 10 * time.Second
+	DefaultHealthCheckTimeout  = 2 * time.Second
+	DefaultHealthCheckRise     = 2
+	DefaultHealthCheckFall     = 3
+)
+
+type HealthCheck struct {
+	Enabled        bool          `toml:"enabled"`         // Enable active health checks / 启用主动健康检查
+	Path           string        `toml:"path"`            // Probe path appended to the backend URL / 追加到后端URL的探测路径
+	Method         string        `toml:"method"`          // Probe HTTP method / 探测使用的HTTP方法
+	ExpectedStatus []int         `toml:"expected_status"` // Expected status codes, any 2xx when empty / 期望的状态码，为空时接受任意2xx
+	Interval       time.Duration `toml:"interval"`        // Probe interval, e.g. "10s" / 探测间隔，例如 "10s"
+	Timeout        time.Duration `toml:"timeout"`         // Probe timeout, e.g. "2s" / 探测超时，例如 "2s"
+	Rise           int           `toml:"rise"`            // Consecutive successes to mark healthy / 连续成功多少次后标记为健康
+	Fall           int           `toml:"fall"`            // Consecutive failures to mark unhealthy / 连续失败多少次后标记为不健康
+}
+
+// WithDefaults returns a copy of the health check with defaults filled in
+// 返回填充了默认值的健康检查配置副本
+func (h HealthCheck) WithDefaults() HealthCheck {
+	if h.Path == "" {
+		h.Path = DefaultHealthCheckPath
+	}
+	if h.Method == "" {
+		h.Method = DefaultHealthCheckMethod
+	}
+	if h.Interval == 0 {
+		h.Interval = DefaultHealthCheckInterval
+	}
+	if h.Timeout == 0 {
+		h.Timeout = DefaultHealthCheckTimeout
+	}
+	if h.Rise == 0 {
+		h.Rise = DefaultHealthCheckRise
+	}
+	if h.Fall == 0 {
+		h.Fall = DefaultHealthCheckFall
+	}
+	return h
 }
 
 // ParseConfig parses the config file at the given path
@@ -58,17 +371,101 @@ func ParseConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	// 配置引用的文件相对于配置文件所在目录
+	// Files referenced by the config are relative to the directory of the config file
+	config.APIKeys.File = resolvePath(path, config.APIKeys.File)
+	resolveCertificatePaths(path, config.TLS.Certificates)
+	if err := loadAPIKeysFile(&config); err != nil {
+		return nil, err
+	}
+	resolveRoutePaths(path, config.Routes)
+	expandBackendGroups(config.Routes)
+	for i := range config.VHosts {
+		resolveCertificatePaths(path, config.VHosts[i].Certificates)
+		resolveRoutePaths(path, config.VHosts[i].Routes)
+		expandBackendGroups(config.VHosts[i].Routes)
+		config.VHosts[i].applyVHostDefaults()
+	}
+
 	if config.LogFilePath != "" {
 		logger.SetLogFilePath(config.LogFilePath)
 		logger.SetSaveToFile(true)
 		logger.Reset()
 	}
 
-	logger.Debug("config parsed", zap.Any("config", config))
+	// 不记录完整配置，其中包含 API 密钥、JWT 密钥和管理令牌等机密
+	// The full config is not logged, it holds secrets such as API keys, JWT secrets and the admin token
+	logger.Debug("config parsed",
+		zap.String("host", config.Host),
+		zap.Int("port", config.Port),
+		zap.Int("routes", len(config.Routes)),
+		zap.Int("vhosts", len(config.VHosts)))
 
 	return &config, nil
 }
 
+// resolveCertificatePaths 将证书和私钥文件解析为相对于配置文件所在目录的路径
+// resolveCertificatePaths resolves the certificate and key files against the directory of the config file
+func resolveCertificatePaths(configPath string, certificates []TLSCertificate) {
+	for i := range certificates {
+		certificates[i].CertFile = resolvePath(configPath, certificates[i].CertFile)
+		certificates[i].KeyFile = resolvePath(configPath, certificates[i].KeyFile)
+	}
+}
+
+// resolveRoutePaths 将路由引用的文件解析为相对于配置文件所在目录的路径
+// resolveRoutePaths resolves the files referenced by the routes against the directory of the config file
+func resolveRoutePaths(configPath string, routes []Route) {
+	for i := range routes {
+		jwt := &routes[i].JWT
+		jwt.SecretFile = resolvePath(configPath, jwt.SecretFile)
+		jwt.PublicKeyFile = resolvePath(configPath, jwt.PublicKeyFile)
+		jwt.JWKSFile = resolvePath(configPath, jwt.JWKSFile)
+		routes[i].BasicAuth.HtpasswdFile = resolvePath(configPath, routes[i].BasicAuth.HtpasswdFile)
+		routes[i].ClientCert.CAFile = resolvePath(configPath, routes[i].ClientCert.CAFile)
+		routes[i].UpstreamTLS = routes[i].UpstreamTLS.resolvePaths(configPath)
+		for backend, upstreamTLS := range routes[i].BackendTLS {
+			routes[i].BackendTLS[backend] = upstreamTLS.resolvePaths(configPath)
+		}
+	}
+}
+
+// IncludedFiles 返回配置引用的其他文件，这些文件变化时也需要重新加载配置
+// IncludedFiles returns the other files referenced by the config, whose changes also reload the config
+func (c *Config) IncludedFiles() []string {
+	var files []string
+	if c.APIKeys.File != "" {
+		files = append(files, c.APIKeys.File)
+	}
+	if c.TLS.Enabled {
+		for _, certificate := range c.EffectiveTLS().Certificates {
+			files = append(files, certificate.CertFile, certificate.KeyFile)
+		}
+	}
+	for _, route := range c.AllRoutes() {
+		var routeFiles []string
+		if route.JWT.Enabled {
+			routeFiles = append(routeFiles, route.JWT.SecretFile, route.JWT.PublicKeyFile, route.JWT.JWKSFile)
+		}
+		if route.BasicAuth.Enabled {
+			routeFiles = append(routeFiles, route.BasicAuth.HtpasswdFile)
+		}
+		if route.ClientCert.Enabled {
+			routeFiles = append(routeFiles, route.ClientCert.CAFile)
+		}
+		for _, backend := range route.Backends {
+			upstreamTLS := route.UpstreamTLSFor(backend)
+			routeFiles = append(routeFiles, upstreamTLS.CAFile, upstreamTLS.CertFile, upstreamTLS.KeyFile)
+		}
+		for _, file := range routeFiles {
+			if file != "" && !slices.Contains(files, file) {
+				files = append(files, file)
+			}
+		}
+	}
+	return files
+}
+
 // ValidateConfig validates the config
 // 验证配置
 func ValidateConfig(config *Config) error {
@@ -82,11 +479,41 @@ func ValidateConfig(config *Config) error {
 		return err
 	}
 
+	// 验证全局超时配置
+	if err := validateTimeouts("", config.Timeouts); err != nil {
+		return err
+	}
+
+	// 验证 TLS 配置
+	if err := validateTLSConfig(config); err != nil {
+		return err
+	}
+
+	// 验证转发配置
+	if err := validateForwardedConfig(config); err != nil {
+		return err
+	}
+
+	// 验证指标配置
+	if err := validateMetricsConfig(config); err != nil {
+		return err
+	}
+
+	// 验证管理 API 配置
+	if err := validateAdminConfig(config); err != nil {
+		return err
+	}
+
 	// 验证路由配置
 	if err := validateRoutes(config); err != nil {
 		return err
 	}
 
+	// 验证 API 密钥
+	if err := validateAPIKeys(config); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -126,21 +553,111 @@ func validateCacheConfig(config *Config) error {
 	return nil
 }
 
+// validateMetricsConfig validates the metrics configuration
+// 验证指标配置
+func validateMetricsConfig(config *Config) error {
+	if !config.Metrics.Enabled {
+		return nil
+	}
+	metrics := config.Metrics.WithDefaults()
+
+	if !strings.HasPrefix(metrics.Path, "/") {
+		logger.Error("metrics path must start with /", zap.String("path", metrics.Path))
+		return fmt.Errorf("metrics path must start with /: %s", metrics.Path)
+	}
+
+	if metrics.Listen != "" {
+		if _, _, err := net.SplitHostPort(metrics.Listen); err != nil {
+			logger.Error("metrics listen address is not valid", zap.String("listen", metrics.Listen), zap.Error(err))
+			return fmt.Errorf("metrics listen address is not valid: %v", err)
+		}
+		return nil
+	}
+
+	// 指标端点与网关共用端口时优先于路由，提示被遮挡的路由
+	// When sharing the gateway port the metrics endpoint takes precedence, warn about routes it shadows
+	warnShadowedRoutes("metrics", metrics.Path, config.AllRoutes())
+
+	return nil
+}
+
+// validateAdminConfig validates the admin API configuration
+// 验证管理 API 配置
+func validateAdminConfig(config *Config) error {
+	if !config.Admin.Enabled {
+		return nil
+	}
+	admin := config.Admin.WithDefaults()
+
+	if admin.Token == "" {
+		logger.Error("admin token is required when the admin API is enabled")
+		return fmt.Errorf("admin token is required when the admin API is enabled")
+	}
+	if len(admin.Token) < 16 {
+		logger.Warn("admin token is shorter than 16 characters, use a longer random token")
+	}
+
+	if admin.Prefix != "" && !strings.HasPrefix(admin.Prefix, "/") {
+		logger.Error("admin prefix must start with /", zap.String("prefix", admin.Prefix))
+		return fmt.Errorf("admin prefix must start with /: %s", admin.Prefix)
+	}
+
+	if admin.Listen != "" {
+		if _, _, err := net.SplitHostPort(admin.Listen); err != nil {
+			logger.Error("admin listen address is not valid", zap.String("listen", admin.Listen), zap.Error(err))
+			return fmt.Errorf("admin listen address is not valid: %v", err)
+		}
+		return nil
+	}
+
+	if admin.Prefix == "" {
+		logger.Error("admin prefix must not be / when sharing the gateway port")
+		return fmt.Errorf("admin prefix must not be / when sharing the gateway port")
+	}
+
+	// 管理 API 与网关共用端口时优先于路由，提示被遮挡的路由
+	// When sharing the gateway port the admin API takes precedence, warn about routes it shadows
+	warnShadowedRoutes("admin", admin.Prefix, config.AllRoutes())
+
+	return nil
+}
+
+// warnShadowedRoutes 提示被网关端口上的内置端点遮挡的路由
+// warnShadowedRoutes warns about routes shadowed by a built-in endpoint served on the gateway port
+func warnShadowedRoutes(endpoint, path string, routes []Route) {
+	for _, route := range routes {
+		prefix := strings.TrimRight(route.Path, "/")
+		if strings.EqualFold(path, prefix) || strings.HasPrefix(strings.ToLower(path), strings.ToLower(prefix)+"/") {
+			logger.Warn(endpoint+" path shadows part of a route, set "+endpoint+" listen to serve it separately",
+				zap.String(endpoint+"_path", path),
+				zap.String("route", route.Path))
+		}
+	}
+}
+
 // validateRoutes validates the route configurations
 // 验证路由配置
 func validateRoutes(config *Config) error {
-	if len(config.Routes) == 0 {
+	if len(config.AllRoutes()) == 0 {
 		logger.Error("no routes found in config")
 		return fmt.Errorf("no routes found in config")
 	}
 
-	existingPaths := make(map[string]bool)
+	if err := validateVHosts(config); err != nil {
+		return err
+	}
+
+	existingIDs := make(map[string]bool)
 
-	for _, route := range config.Routes {
-		if err := validateSingleRoute(route, existingPaths); err != nil {
+	for _, route := range config.AllRoutes() {
+		if err := validateSingleRoute(route, existingIDs); err != nil {
 			return err
 		}
-		existingPaths[route.Path] = true
+		existingIDs[route.ID()] = true
+	}
+
+	for _, vhost := range config.VirtualHosts() {
+		warnUnreachableRoutes(vhost.OrderedRoutes())
 	}
 
 	return nil
@@ -148,9 +665,19 @@ func validateRoutes(config *Config) error {
 
 // validateSingleRoute validates a single route configuration
 // 验证单个路由配置
-func validateSingleRoute(route Route, existingPaths map[string]bool) error {
+func validateSingleRoute(route Route, existingIDs map[string]bool) error {
 	// 验证路径
-	if err := validateRoutePath(route, existingPaths); err != nil {
+	if err := validateRoutePath(route, existingIDs); err != nil {
+		return err
+	}
+
+	// 验证匹配条件
+	if err := validateRouteMatch(route); err != nil {
+		return err
+	}
+
+	// 验证后端分组
+	if err := validateBackendGroups(route); err != nil {
 		return err
 	}
 
@@ -169,13 +696,96 @@ func validateSingleRoute(route Route, existingPaths map[string]bool) error {
 	if err := validateRewriteRule(route); err != nil {
 		return err
 	}
+	if err := validateRewriteRules(route); err != nil {
+		return err
+	}
+
+	// 验证请求头规则
+	if err := validateHeaderRules(route); err != nil {
+		return err
+	}
+
+	// 验证健康检查
+	if err := validateHealthCheck(route); err != nil {
+		return err
+	}
+
+	// 验证被动健康检查
+	if err := validatePassiveHealth(route); err != nil {
+		return err
+	}
+
+	// 验证负载均衡策略
+	if err := validateLoadBalancing(route); err != nil {
+		return err
+	}
+
+	// 验证重试配置
+	if err := validateRetry(route); err != nil {
+		return err
+	}
+
+	// 验证熔断器配置
+	if err := validateCircuitBreaker(route); err != nil {
+		return err
+	}
+
+	// 验证流量镜像配置
+	if err := validateMirror(route); err != nil {
+		return err
+	}
+
+	// 验证限流配置
+	if err := validateRateLimit(route); err != nil {
+		return err
+	}
+
+	// 验证 API 密钥认证
+	if err := validateAPIKeyAuth(route); err != nil {
+		return err
+	}
+
+	// 验证 JWT 认证
+	if err := validateJWTAuth(route); err != nil {
+		return err
+	}
+
+	// 验证 Basic 认证
+	if err := validateBasicAuth(route); err != nil {
+		return err
+	}
+
+	// 验证客户端证书认证
+	if err := validateClientCertAuth(route); err != nil {
+		return err
+	}
+
+	// 验证超时配置
+	if err := validateRouteTimeouts(route); err != nil {
+		return err
+	}
+
+	// 验证上游 TLS 配置
+	if err := validateUpstreamTLS(route); err != nil {
+		return err
+	}
+
+	// 验证流式配置
+	if err := validateStreaming(route); err != nil {
+		return err
+	}
+
+	// 验证 WebSocket 配置
+	if err := validateWebSocket(route); err != nil {
+		return err
+	}
 
 	return nil
 }
 
 // validateRoutePath validates the route path
 // 验证路由路径
-func validateRoutePath(route Route, existingPaths map[string]bool) error {
+func validateRoutePath(route Route, existingIDs map[string]bool) error {
 	if route.Path == "" {
 		logger.Error("route path is empty", zap.String("path", route.Path))
 		return fmt.Errorf("route path is empty")
@@ -194,9 +804,13 @@ func validateRoutePath(route Route, existingPaths map[string]bool) error {
 		return fmt.Errorf("route path is reserved for wiki")
 	}
 
-	if existingPaths[route.Path] {
-		logger.Error("route path is duplicated", zap.String("path", route.Path))
-		return fmt.Errorf("route path is duplicated")
+	if existingIDs[route.ID()] {
+		if route.Name != "" {
+			logger.Error("route name is duplicated", zap.String("name", route.Name), zap.String("path", route.Path))
+			return fmt.Errorf("route name is duplicated: %s", route.Name)
+		}
+		logger.Error("route path is duplicated, give routes sharing a path a unique name", zap.String("path", route.Path), zap.String("vhost", route.VHost))
+		return fmt.Errorf("route path is duplicated, give routes sharing a path a unique name: %s", route.ID())
 	}
 
 	return nil
@@ -213,7 +827,7 @@ func validateRouteBackends(route Route) error {
 
 	// 验证每个后端服务URL
 	for _, backend := range route.Backends {
-		if err := validateSingleBackend(route.Path, backend); err != nil {
+		if err := validateSingleBackend(route.Path, backend, route.UpstreamTLSFor(backend)); err != nil {
 			return err
 		}
 	}
@@ -221,9 +835,9 @@ func validateRouteBackends(route Route) error {
 	return nil
 }
 
-// validateSingleBackend validates a single backend URL
-// 验证单个后端服务URL
-func validateSingleBackend(routePath, backend string) error {
+// validateSingleBackend validates a single backend URL, connectivity is checked separately by ProbeBackends
+// 验证单个后端服务URL，连通性由 ProbeBackends 单独检查
+func validateSingleBackend(routePath, backend string, upstreamTLS UpstreamTLS) error {
 	if backend == "" {
 		logger.Error("route backend is empty", zap.String("path", routePath))
 		return fmt.Errorf("route backend is empty")
@@ -234,14 +848,59 @@ func validateSingleBackend(routePath, backend string) error {
 		return fmt.Errorf("route backend is not a valid URL")
 	}
 
-	if _, err := network.HttpConnect(backend); err != nil {
-		logger.Warn("failed to connect to route backend, but will try during runtime",
-			zap.String("path", routePath),
-			zap.String("backend", backend),
-			zap.Error(err))
+	return nil
+}
+
+// ProbeBackends 并发尝试连接所有路由的后端和镜像后端，连接失败只记录警告。热重载时不调用，避免不可达的后端拖慢重载
+// ProbeBackends tries to connect to the backends and mirror backends of all routes concurrently, failures are only
+// logged. It is not called on hot reload so unreachable backends do not stall reloads
+func ProbeBackends(config *Config) {
+	var wg sync.WaitGroup
+	probe := func(route Route, backend string, upstreamTLS UpstreamTLS) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := connectBackend(backend, upstreamTLS); err != nil {
+				logger.Warn("failed to connect to route backend, but will try during runtime",
+					zap.String("path", route.Path),
+					zap.String("backend", backend),
+					zap.Error(err))
+			}
+		}()
 	}
 
-	return nil
+	for _, route := range config.AllRoutes() {
+		for _, backend := range route.Backends {
+			probe(route, backend, route.UpstreamTLSFor(backend))
+		}
+		if route.Mirror.Enabled {
+			probe(route, route.Mirror.Backend, route.UpstreamTLS)
+		}
+	}
+	wg.Wait()
+}
+
+// connectBackend 尝试连接后端，配置了上游 TLS 时使用相同的 TLS 设置。TLS 配置无效时由 validateUpstreamTLS 报告
+// connectBackend tries to connect to the backend with the upstream TLS settings when configured. Invalid TLS configs are
+// reported by validateUpstreamTLS
+func connectBackend(backend string, upstreamTLS UpstreamTLS) error {
+	tlsConfig, err := upstreamTLS.ClientConfig()
+	if err != nil || tlsConfig == nil {
+		_, err := network.HttpConnect(backend)
+		return err
+	}
+
+	client := http.Client{
+		Timeout:   3 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Get(backend)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
 }
 
 // validateRewriteRule validates the rewrite rule configuration
@@ -280,6 +939,241 @@ func validateRewriteRule(route Route) error {
 	return nil
 }
 
+// validateHealthCheck validates the active health check configuration
+// 验证主动健康检查配置
+func validateHealthCheck(route Route) error {
+	if !route.HealthCheck.Enabled {
+		return nil
+	}
+
+	healthCheck := route.HealthCheck.WithDefaults()
+
+	if !strings.HasPrefix(healthCheck.Path, "/") {
+		logger.Error("health check path must start with /", zap.String("path", route.Path), zap.String("health_check_path", healthCheck.Path))
+		return fmt.Errorf("health check path must start with /")
+	}
+
+	if healthCheck.Interval < 0 || healthCheck.Timeout < 0 {
+		logger.Error("health check interval and timeout must not be negative",
+			zap.String("path", route.Path),
+			zap.Duration("interval", healthCheck.Interval),
+			zap.Duration("timeout", healthCheck.Timeout))
+		return fmt.Errorf("health check interval and timeout must not be negative")
+	}
+
+	if healthCheck.Timeout > healthCheck.Interval {
+		logger.Error("health check timeout must not exceed the interval",
+			zap.String("path", route.Path),
+			zap.Duration("interval", healthCheck.Interval),
+			zap.Duration("timeout", healthCheck.Timeout))
+		return fmt.Errorf("health check timeout must not exceed the interval")
+	}
+
+	if healthCheck.Rise < 0 || healthCheck.Fall < 0 {
+		logger.Error("health check rise and fall must not be negative",
+			zap.String("path", route.Path),
+			zap.Int("rise", healthCheck.Rise),
+			zap.Int("fall", healthCheck.Fall))
+		return fmt.Errorf("health check rise and fall must not be negative")
+	}
+
+	for _, status := range healthCheck.ExpectedStatus {
+		if status < 100 || status > 599 {
+			logger.Error("health check expected status is not valid", zap.String("path", route.Path), zap.Int("status", status))
+			return fmt.Errorf("health check expected status is not valid: %d", status)
+		}
+	}
+
+	return nil
+}
+
+// validateLoadBalancing validates the load balancing strategy, hash key and backend weights
+// 验证负载均衡策略、哈希键和后端权重
+func validateLoadBalancing(route Route) error {
+	switch route.LBStrategy {
+	case "", LBStrategyRoundRobin, LBStrategyWeightedRoundRobin, LBStrategyLeastConnections,
+		LBStrategyLeastResponseTime, LBStrategyRandomTwoChoices, LBStrategyConsistentHash:
+	default:
+		logger.Error("route lb_strategy is not valid", zap.String("path", route.Path), zap.String("lb_strategy", route.LBStrategy))
+		return fmt.Errorf("route lb_strategy is not valid: %s", route.LBStrategy)
+	}
+
+	if route.LBHashKey != "" {
+		if route.LBStrategy != LBStrategyConsistentHash {
+			logger.Error("lb_hash_key requires lb_strategy consistent_hash", zap.String("path", route.Path), zap.String("lb_hash_key", route.LBHashKey))
+			return fmt.Errorf("lb_hash_key requires lb_strategy consistent_hash")
+		}
+
+		name := ""
+		switch {
+		case route.LBHashKey == LBHashKeyClientIP:
+			name = route.LBHashKey
+		case strings.HasPrefix(route.LBHashKey, LBHashKeyHeaderPrefix):
+			name = strings.TrimPrefix(route.LBHashKey, LBHashKeyHeaderPrefix)
+		case strings.HasPrefix(route.LBHashKey, LBHashKeyCookiePrefix):
+			name = strings.TrimPrefix(route.LBHashKey, LBHashKeyCookiePrefix)
+		}
+		if name == "" {
+			logger.Error("route lb_hash_key is not valid", zap.String("path", route.Path), zap.String("lb_hash_key", route.LBHashKey))
+			return fmt.Errorf("route lb_hash_key must be client_ip, header:<name> or cookie:<name>")
+		}
+	}
+
+	if len(route.BackendWeights) > 0 && route.LBStrategy != LBStrategyWeightedRoundRobin {
+		logger.Error("backend_weights requires lb_strategy weighted_round_robin", zap.String("path", route.Path))
+		return fmt.Errorf("backend_weights requires lb_strategy weighted_round_robin")
+	}
+
+	for backend, weight := range route.BackendWeights {
+		if !slices.Contains(route.Backends, backend) {
+			logger.Error("backend_weights references an unknown backend", zap.String("path", route.Path), zap.String("backend", backend))
+			return fmt.Errorf("backend_weights references an unknown backend: %s", backend)
+		}
+		if weight <= 0 {
+			logger.Error("backend weight must be positive", zap.String("path", route.Path), zap.String("backend", backend), zap.Int("weight", weight))
+			return fmt.Errorf("backend weight must be positive: %s", backend)
+		}
+	}
+
+	return nil
+}
+
+// validateRetry validates the retry configuration
+// 验证重试配置
+func validateRetry(route Route) error {
+	retry := route.Retry
+
+	if retry.Attempts < 0 {
+		logger.Error("retry attempts must not be negative", zap.String("path", route.Path), zap.Int("attempts", retry.Attempts))
+		return fmt.Errorf("retry attempts must not be negative")
+	}
+
+	for _, status := range retry.RetryOnStatus {
+		if status < 100 || status > 599 {
+			logger.Error("retry status is not valid", zap.String("path", route.Path), zap.Int("status", status))
+			return fmt.Errorf("retry status is not valid: %d", status)
+		}
+	}
+
+	for _, kind := range retry.RetryOnErrors {
+		if !slices.Contains(DefaultRetryOnErrors, kind) {
+			logger.Error("retry error kind is not valid", zap.String("path", route.Path), zap.String("retry_on_errors", kind))
+			return fmt.Errorf("retry error kind must be connect_failure, timeout or reset: %s", kind)
+		}
+	}
+
+	if retry.PerTryTimeout < 0 || retry.Backoff < 0 || retry.MaxBackoff < 0 {
+		logger.Error("retry timeouts and backoff must not be negative",
+			zap.String("path", route.Path),
+			zap.Duration("per_try_timeout", retry.PerTryTimeout),
+			zap.Duration("backoff", retry.Backoff),
+			zap.Duration("max_backoff", retry.MaxBackoff))
+		return fmt.Errorf("retry timeouts and backoff must not be negative")
+	}
+
+	return nil
+}
+
+// validateRateLimit validates the rate limit configuration
+// 验证限流配置
+func validateRateLimit(route Route) error {
+	if !route.RateLimit.Enabled {
+		return nil
+	}
+	rateLimit := route.RateLimit.WithDefaults()
+
+	if rateLimit.Algorithm != RateLimitTokenBucket && rateLimit.Algorithm != RateLimitSlidingWindow {
+		logger.Error("rate limit algorithm is not valid", zap.String("path", route.Path), zap.String("algorithm", rateLimit.Algorithm))
+		return fmt.Errorf("rate limit algorithm must be token_bucket or sliding_window: %s", rateLimit.Algorithm)
+	}
+
+	if rateLimit.Rate <= 0 {
+		logger.Error("rate limit rate must be positive", zap.String("path", route.Path), zap.Int("rate", rateLimit.Rate))
+		return fmt.Errorf("rate limit rate must be positive")
+	}
+
+	if rateLimit.Period < 0 || rateLimit.Burst < 0 {
+		logger.Error("rate limit period and burst must not be negative",
+			zap.String("path", route.Path),
+			zap.Duration("period", rateLimit.Period),
+			zap.Int("burst", rateLimit.Burst))
+		return fmt.Errorf("rate limit period and burst must not be negative")
+	}
+
+	if rateLimit.Algorithm == RateLimitSlidingWindow && route.RateLimit.Burst != 0 {
+		logger.Warn("rate limit burst is ignored by the sliding_window algorithm", zap.String("path", route.Path))
+	}
+
+	if rateLimit.Key != RateLimitKeyClientIP && rateLimit.Key != RateLimitKeyAPIKey &&
+		(!strings.HasPrefix(rateLimit.Key, RateLimitKeyHeaderPrefix) || rateLimit.Key == RateLimitKeyHeaderPrefix) {
+		logger.Error("rate limit key is not valid", zap.String("path", route.Path), zap.String("key", rateLimit.Key))
+		return fmt.Errorf("rate limit key must be client_ip, api_key or header:<name>")
+	}
+
+	return nil
+}
+
+// validateStreaming validates the streaming configuration
+// 验证流式配置
+func validateStreaming(route Route) error {
+	if route.StreamCacheMaxSize < 0 {
+		logger.Error("stream_cache_max_size must not be negative", zap.String("path", route.Path), zap.Int("stream_cache_max_size", route.StreamCacheMaxSize))
+		return fmt.Errorf("stream_cache_max_size must not be negative")
+	}
+
+	if route.StreamCacheMaxSize > 0 && !route.Streaming {
+		logger.Warn("stream_cache_max_size has no effect without streaming", zap.String("path", route.Path))
+	}
+
+	return nil
+}
+
+// validateWebSocket validates the WebSocket configuration
+// 验证 WebSocket 配置
+func validateWebSocket(route Route) error {
+	if route.WebSocketIdleTimeout < 0 {
+		logger.Error("websocket_idle_timeout must not be negative", zap.String("path", route.Path), zap.Duration("websocket_idle_timeout", route.WebSocketIdleTimeout))
+		return fmt.Errorf("websocket_idle_timeout must not be negative")
+	}
+
+	return nil
+}
+
+// validateRouteTimeouts validates the route and per-backend timeouts
+// 验证路由和单个后端的超时配置
+func validateRouteTimeouts(route Route) error {
+	if err := validateTimeouts(route.Path, route.Timeouts); err != nil {
+		return err
+	}
+
+	for backend, timeouts := range route.BackendTimeouts {
+		if !slices.Contains(route.Backends, backend) {
+			logger.Error("backend_timeouts references an unknown backend", zap.String("path", route.Path), zap.String("backend", backend))
+			return fmt.Errorf("backend_timeouts references an unknown backend: %s", backend)
+		}
+		if err := validateTimeouts(route.Path, timeouts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateTimeouts validates a timeouts block, path is empty for the global timeouts
+// 验证超时配置，全局超时的 path 为空
+func validateTimeouts(path string, timeouts Timeouts) error {
+	if timeouts.Connect < 0 || timeouts.ResponseHeader < 0 || timeouts.Total < 0 {
+		logger.Error("timeouts must not be negative",
+			zap.String("path", path),
+			zap.Duration("connect", timeouts.Connect),
+			zap.Duration("response_header", timeouts.ResponseHeader),
+			zap.Duration("total", timeouts.Total))
+		return fmt.Errorf("timeouts must not be negative")
+	}
+
+	return nil
+}
+
 // GetExampleConfig returns the example config as a string
 // 返回示例配置作为字符串
 func GetExampleConfig() (string, error) {
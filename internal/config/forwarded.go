@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// 转发请求头的格式
+// Formats of the forwarding headers
+const (
+	ForwardedHeaderXForwarded = "x-forwarded"
+	ForwardedHeaderRFC7239    = "forwarded"
+)
+
+type Forwarded struct {
+	TrustedProxies []string `toml:"trusted_proxies"` // CIDRs or IPs of proxies whose forwarding headers are trusted / 信任其转发请求头的代理 CIDR 或 IP
+	Header         string   `toml:"header"`          // Headers sent to backends: x-forwarded (default) or forwarded (RFC 7239) / 发送给后端的请求头
+}
+
+// WithDefaults returns a copy of the forwarded config with defaults filled in
+// 返回填充了默认值的转发配置副本
+func (f Forwarded) WithDefaults() Forwarded {
+	if f.Header == "" {
+		f.Header = ForwardedHeaderXForwarded
+	}
+	return f
+}
+
+// ParseTrustedProxies 解析受信任的代理，单个 IP 视为只包含该地址的前缀
+// ParseTrustedProxies parses the trusted proxies, a single IP is a prefix holding only that address
+func (f Forwarded) ParseTrustedProxies() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(f.TrustedProxies))
+	for _, proxy := range f.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			addr, err := netip.ParseAddr(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// validateForwardedConfig validates the trusted proxies and the forwarding header format
+// 验证受信任的代理和转发请求头格式
+func validateForwardedConfig(config *Config) error {
+	forwarded := config.Forwarded.WithDefaults()
+
+	if forwarded.Header != ForwardedHeaderXForwarded && forwarded.Header != ForwardedHeaderRFC7239 {
+		logger.Error("forwarded header is not valid", zap.String("header", forwarded.Header))
+		return fmt.Errorf("forwarded header is not valid, use %s or %s: %s", ForwardedHeaderXForwarded, ForwardedHeaderRFC7239, forwarded.Header)
+	}
+
+	prefixes, err := forwarded.ParseTrustedProxies()
+	if err != nil {
+		logger.Error("trusted proxies are not valid", zap.Error(err))
+		return err
+	}
+	for _, prefix := range prefixes {
+		if prefix.Bits() == 0 {
+			logger.Warn("trusting every address lets any client choose its IP through forwarding headers",
+				zap.String("trusted_proxy", prefix.String()))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"net/textproto"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// 请求头模板中可用的变量
+// Variables available in header templates
+const (
+	HeaderVarClientIP  = "client_ip"
+	HeaderVarRequestID = "request_id"
+	HeaderVarRoute     = "route"
+	HeaderVarBackend   = "backend"
+	HeaderVarGroup     = "group"
+)
+
+// 逐跳请求头，见 RFC 7230 第 6.1 节，代理不转发这些请求头
+// Hop-by-hop headers, see RFC 7230 section 6.1, which proxies do not forward
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+type HeaderRules struct {
+	Request  HeaderOps `toml:"request"`  // Rules applied to requests sent to backends / 应用于发送给后端的请求的规则
+	Response HeaderOps `toml:"response"` // Rules applied to responses sent to clients / 应用于发送给客户端的响应的规则
+}
+
+// HeaderOps 请求头操作，依次执行 remove、rename、set、add
+// HeaderOps are header operations, applied in the order remove, rename, set, add
+type HeaderOps struct {
+	Remove []string          `toml:"remove"` // Headers to remove / 要移除的请求头
+	Rename map[string]string `toml:"rename"` // Old name to new name, values are kept / 旧名称到新名称，保留值
+	Set    map[string]string `toml:"set"`    // Headers to replace, values may use templates / 要替换的请求头，值可以使用模板
+	Add    map[string]string `toml:"add"`    // Headers to append, values may use templates / 要追加的请求头，值可以使用模板
+}
+
+// IsHopByHopHeader 判断是否为逐跳请求头
+// IsHopByHopHeader reports whether the header is a hop-by-hop header
+func IsHopByHopHeader(name string) bool {
+	return slices.Contains(hopByHopHeaders, textproto.CanonicalMIMEHeaderKey(name))
+}
+
+// ExpandHeaderTemplate 将 {name} 形式的变量替换为 lookup 返回的值，未知变量保持原样
+// ExpandHeaderTemplate replaces variables of the form {name} with the values returned by lookup, unknown variables
+// are kept as is
+func ExpandHeaderTemplate(value string, lookup func(name string) (string, bool)) string {
+	if !strings.Contains(value, "{") {
+		return value
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(value, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(value[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		b.WriteString(value[:start])
+		if expanded, ok := lookup(value[start+1 : end]); ok {
+			b.WriteString(expanded)
+		} else {
+			b.WriteString(value[start : end+1])
+		}
+		value = value[end+1:]
+	}
+	b.WriteString(value)
+	return b.String()
+}
+
+// validateHeaderRules validates the request and response header rules of a route
+// 验证路由的请求头和响应头规则
+func validateHeaderRules(route Route) error {
+	if err := validateHeaderOps(route.Path, "request", route.Headers.Request); err != nil {
+		return err
+	}
+	return validateHeaderOps(route.Path, "response", route.Headers.Response)
+}
+
+// validateHeaderOps validates the header names and templates of header operations
+// 验证请求头操作的名称和模板
+func validateHeaderOps(routePath, direction string, ops HeaderOps) error {
+	names := slices.Clone(ops.Remove)
+	for from, to := range ops.Rename {
+		names = append(names, from, to)
+	}
+	for _, values := range []map[string]string{ops.Set, ops.Add} {
+		for name, value := range values {
+			names = append(names, name)
+			if err := validateHeaderTemplate(value); err != nil {
+				logger.Error("header rule value is not valid",
+					zap.String("path", routePath),
+					zap.String("direction", direction),
+					zap.String("header", name),
+					zap.Error(err))
+				return fmt.Errorf("%s header rule value of %s is not valid: %v", direction, name, err)
+			}
+		}
+	}
+
+	for _, name := range names {
+		if name == "" || strings.ContainsAny(name, " :\r\n") {
+			logger.Error("header rule name is not valid", zap.String("path", routePath), zap.String("direction", direction), zap.String("header", name))
+			return fmt.Errorf("%s header rule name is not valid: %q", direction, name)
+		}
+		if IsHopByHopHeader(name) {
+			logger.Error("header rules must not use hop-by-hop headers", zap.String("path", routePath), zap.String("direction", direction), zap.String("header", name))
+			return fmt.Errorf("%s header rules must not use the hop-by-hop header %s", direction, name)
+		}
+	}
+
+	return nil
+}
+
+// validateHeaderTemplate 检查模板中的变量是否都已知
+// validateHeaderTemplate checks that all variables of the template are known
+func validateHeaderTemplate(value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("value must not contain line breaks")
+	}
+
+	var unknown string
+	ExpandHeaderTemplate(value, func(name string) (string, bool) {
+		switch name {
+		case HeaderVarClientIP, HeaderVarRequestID, HeaderVarRoute, HeaderVarBackend, HeaderVarGroup:
+		default:
+			if unknown == "" {
+				unknown = name
+			}
+		}
+		return "", true
+	})
+	if unknown != "" {
+		return fmt.Errorf("unknown template variable {%s}, use {%s}, {%s}, {%s}, {%s} or {%s}",
+			unknown, HeaderVarClientIP, HeaderVarRequestID, HeaderVarRoute, HeaderVarBackend, HeaderVarGroup)
+	}
+	return nil
+}
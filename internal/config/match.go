@@ -0,0 +1,192 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// RouteMatch 路径之外的路由匹配条件，所有配置的条件都满足时路由才匹配
+// RouteMatch holds route match conditions besides the path, a route only matches when all configured conditions hold
+type RouteMatch struct {
+	Methods []string          `toml:"methods"` // HTTP methods, empty matches any method / HTTP 方法，为空时匹配任意方法
+	Hosts   []string          `toml:"hosts"`   // Host names, "*.example.com" matches any subdomain / 主机名，"*.example.com" 匹配任意子域名
+	Headers map[string]string `toml:"headers"` // Header name to exact value, "*" only requires the header / 请求头名称到精确值，"*" 只要求请求头存在
+	Query   map[string]string `toml:"query"`   // Query parameter to exact value, "*" only requires the parameter / 查询参数到精确值，"*" 只要求参数存在
+}
+
+// MatchAny 表示只要求请求头或查询参数存在的值
+// MatchAny is the value that only requires a header or query parameter to be present
+const MatchAny = "*"
+
+// RouteRequest 路由匹配使用的请求信息
+// RouteRequest is the request information used for route matching
+type RouteRequest struct {
+	Path   string
+	Method string
+	Host   string
+	Header func(name string) (string, bool)
+	Query  func(name string) (string, bool)
+}
+
+// 路由匹配可以使用的 HTTP 方法
+// HTTP methods that can be used for route matching
+var matchMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE"}
+
+// matchHostPattern 主机名或以 "*." 开头的通配主机名
+// matchHostPattern is a host name or a wildcard host name starting with "*."
+var matchHostPattern = regexp.MustCompile(`^(\*\.)?[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
+// ID 返回路由的标识，用于指标、管理 API 和 API 密钥，未设置名称时使用路径，虚拟主机的路由以 "<vhost>:" 开头
+// ID returns the route identifier used by metrics, the admin API and API keys, the path when no name is set; routes of
+// a vhost are prefixed with "<vhost>:"
+func (r Route) ID() string {
+	id := r.Path
+	if r.Name != "" {
+		id = r.Name
+	}
+	if r.VHost != "" {
+		return r.VHost + ":" + id
+	}
+	return id
+}
+
+// IsEmpty 判断是否没有配置任何匹配条件
+// IsEmpty reports whether no match condition is configured
+func (m RouteMatch) IsEmpty() bool {
+	return len(m.Methods) == 0 && len(m.Hosts) == 0 && len(m.Headers) == 0 && len(m.Query) == 0
+}
+
+// Matches 判断请求是否满足所有匹配条件
+// Matches reports whether the request satisfies all match conditions
+func (m RouteMatch) Matches(req RouteRequest) bool {
+	if len(m.Methods) > 0 && !slices.ContainsFunc(m.Methods, func(method string) bool { return strings.EqualFold(method, req.Method) }) {
+		return false
+	}
+	if len(m.Hosts) > 0 {
+		host := requestHostName(req.Host)
+		if !slices.ContainsFunc(m.Hosts, func(pattern string) bool { return matchHost(pattern, host) }) {
+			return false
+		}
+	}
+	for name, want := range m.Headers {
+		if !matchValue(req.Header, name, want) {
+			return false
+		}
+	}
+	for name, want := range m.Query {
+		if !matchValue(req.Query, name, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches 判断请求是否属于该路由：路径匹配并且满足所有匹配条件
+// Matches reports whether the request belongs to the route: the path matches and all match conditions hold
+func (r Route) Matches(req RouteRequest) bool {
+	return r.MatchesPath(req.Path) && r.Match.Matches(req)
+}
+
+// MatchesPath 判断请求路径是否属于该路由，语义与 app.All(route.Path+"/*") 一致
+// MatchesPath reports whether the request path belongs to the route, same semantics as app.All(route.Path+"/*")
+func (r Route) MatchesPath(path string) bool {
+	prefix := strings.TrimRight(r.Path, "/")
+	if len(path) < len(r.Path) || !strings.EqualFold(path[:len(prefix)], prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
+
+// requestHostName 去掉 Host 中的端口并转换为小写
+// requestHostName strips the port from the Host and converts it to lower case
+func requestHostName(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// matchHost 判断主机名是否匹配，"*.example.com" 匹配任意层级的子域名但不匹配 example.com 本身
+// matchHost reports whether the host name matches, "*.example.com" matches subdomains at any depth but not
+// example.com itself
+func matchHost(pattern, host string) bool {
+	pattern = strings.ToLower(pattern)
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return len(host) > len(suffix) && strings.HasSuffix(host, suffix)
+	}
+	return host == pattern
+}
+
+// matchValue 判断请求头或查询参数是否存在并等于期望值，期望值为 "*" 时只要求存在
+// matchValue reports whether the header or query parameter is present and equals the expected value, "*" only
+// requires it to be present
+func matchValue(lookup func(string) (string, bool), name, want string) bool {
+	if lookup == nil {
+		return false
+	}
+	value, ok := lookup(name)
+	return ok && (want == MatchAny || value == want)
+}
+
+// validateRouteMatch validates the match conditions of a route
+// 验证路由的匹配条件
+func validateRouteMatch(route Route) error {
+	for _, method := range route.Match.Methods {
+		if !slices.Contains(matchMethods, strings.ToUpper(method)) {
+			logger.Error("route match method is not valid", zap.String("path", route.Path), zap.String("method", method))
+			return fmt.Errorf("match method of route %s is not valid: %s", route.ID(), method)
+		}
+	}
+
+	for _, host := range route.Match.Hosts {
+		if !matchHostPattern.MatchString(strings.ToLower(host)) {
+			logger.Error("route match host is not valid", zap.String("path", route.Path), zap.String("host", host))
+			return fmt.Errorf("match host of route %s is not valid, use a host name without port or *.domain: %s", route.ID(), host)
+		}
+	}
+
+	for name, value := range route.Match.Headers {
+		if name == "" || strings.ContainsAny(name, " :\r\n") {
+			logger.Error("route match header name is not valid", zap.String("path", route.Path), zap.String("header", name))
+			return fmt.Errorf("match header name of route %s is not valid: %q", route.ID(), name)
+		}
+		if value == "" {
+			logger.Error("route match header value is empty, use * to only require the header", zap.String("path", route.Path), zap.String("header", name))
+			return fmt.Errorf("match header %s of route %s has an empty value, use * to only require the header", name, route.ID())
+		}
+	}
+
+	for name, value := range route.Match.Query {
+		if name == "" {
+			logger.Error("route match query parameter name is empty", zap.String("path", route.Path))
+			return fmt.Errorf("match query parameter name of route %s is empty", route.ID())
+		}
+		if value == "" {
+			logger.Error("route match query value is empty, use * to only require the parameter", zap.String("path", route.Path), zap.String("query", name))
+			return fmt.Errorf("match query parameter %s of route %s has an empty value, use * to only require the parameter", name, route.ID())
+		}
+	}
+
+	return nil
+}
+
+// warnUnreachableRoutes 对被前面没有匹配条件的路由完全遮挡、永远不会匹配的路由发出警告
+// warnUnreachableRoutes warns about routes that are fully shadowed by an earlier route without match conditions and
+// can never match
+func warnUnreachableRoutes(routes []Route) {
+	for i, route := range routes {
+		for _, earlier := range routes[:i] {
+			if earlier.Match.IsEmpty() && earlier.MatchesPath(route.Path) {
+				logger.Warn("route is shadowed by an earlier route without match conditions and never matches",
+					zap.String("route", route.ID()),
+					zap.String("shadowedBy", earlier.ID()))
+				break
+			}
+		}
+	}
+}
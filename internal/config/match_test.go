@@ -0,0 +1,122 @@
+package config
+
+import "testing"
+
+// lookup 返回在 values 中查找名称的函数
+// lookup returns a function looking up names in values
+func lookup(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+func TestRouteMatchMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		match RouteMatch
+		req   RouteRequest
+		want  bool
+	}{
+		{name: "no conditions", req: RouteRequest{Method: "GET"}, want: true},
+		{name: "method case insensitive", match: RouteMatch{Methods: []string{"get", "POST"}}, req: RouteRequest{Method: "GET"}, want: true},
+		{name: "method not listed", match: RouteMatch{Methods: []string{"POST"}}, req: RouteRequest{Method: "GET"}, want: false},
+		{name: "host with port", match: RouteMatch{Hosts: []string{"api.example.com"}}, req: RouteRequest{Host: "API.example.com:8080"}, want: true},
+		{name: "ipv6 host", match: RouteMatch{Hosts: []string{"::1"}}, req: RouteRequest{Host: "[::1]:8080"}, want: true},
+		{name: "wildcard subdomain", match: RouteMatch{Hosts: []string{"*.example.com"}}, req: RouteRequest{Host: "a.b.example.com"}, want: true},
+		{name: "wildcard excludes the domain itself", match: RouteMatch{Hosts: []string{"*.example.com"}}, req: RouteRequest{Host: "example.com"}, want: false},
+		{name: "wildcard needs a dot boundary", match: RouteMatch{Hosts: []string{"*.example.com"}}, req: RouteRequest{Host: "badexample.com"}, want: false},
+		{
+			name:  "header exact value",
+			match: RouteMatch{Headers: map[string]string{"X-Version": "2"}},
+			req:   RouteRequest{Header: lookup(map[string]string{"X-Version": "2"})},
+			want:  true,
+		},
+		{
+			name:  "header wrong value",
+			match: RouteMatch{Headers: map[string]string{"X-Version": "2"}},
+			req:   RouteRequest{Header: lookup(map[string]string{"X-Version": "1"})},
+			want:  false,
+		},
+		{
+			name:  "header present",
+			match: RouteMatch{Headers: map[string]string{"X-Debug": MatchAny}},
+			req:   RouteRequest{Header: lookup(map[string]string{"X-Debug": ""})},
+			want:  true,
+		},
+		{
+			name:  "header missing",
+			match: RouteMatch{Headers: map[string]string{"X-Debug": MatchAny}},
+			req:   RouteRequest{Header: lookup(nil)},
+			want:  false,
+		},
+		{
+			name:  "query without lookup",
+			match: RouteMatch{Query: map[string]string{"beta": MatchAny}},
+			req:   RouteRequest{},
+			want:  false,
+		},
+		{
+			name: "all conditions hold",
+			match: RouteMatch{
+				Methods: []string{"POST"},
+				Hosts:   []string{"api.example.com"},
+				Headers: map[string]string{"X-Version": "2"},
+				Query:   map[string]string{"beta": "1"},
+			},
+			req: RouteRequest{
+				Method: "POST",
+				Host:   "api.example.com",
+				Header: lookup(map[string]string{"X-Version": "2"}),
+				Query:  lookup(map[string]string{"beta": "1"}),
+			},
+			want: true,
+		},
+		{
+			name: "one condition fails",
+			match: RouteMatch{
+				Methods: []string{"POST"},
+				Query:   map[string]string{"beta": "1"},
+			},
+			req: RouteRequest{
+				Method: "POST",
+				Query:  lookup(map[string]string{"beta": "0"}),
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.match.Matches(tt.req); got != tt.want {
+				t.Fatalf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteMatchesPath(t *testing.T) {
+	tests := []struct {
+		route string
+		path  string
+		want  bool
+	}{
+		{route: "/api", path: "/api", want: true},
+		{route: "/api", path: "/api/", want: true},
+		{route: "/api", path: "/api/users", want: true},
+		{route: "/api", path: "/API/users", want: true},
+		{route: "/api", path: "/apiv2", want: false},
+		{route: "/api", path: "/ap", want: false},
+		{route: "/api", path: "/other", want: false},
+		{route: "/api/", path: "/api/users", want: true},
+		{route: "/", path: "/anything", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.route+" "+tt.path, func(t *testing.T) {
+			if got := (Route{Path: tt.route}).MatchesPath(tt.path); got != tt.want {
+				t.Fatalf("MatchesPath = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 流量镜像的默认值
+// Defaults for traffic mirroring
+const (
+	DefaultMirrorPercentage  = 100
+	DefaultMirrorTimeout     = 5 * time.Second
+	DefaultMirrorMaxInFlight = 100
+)
+
+type Mirror struct {
+	Enabled     bool          `toml:"enabled"`       // Send copies of requests to the mirror backend / 将请求副本发送到镜像后端
+	Backend     string        `toml:"backend"`       // Mirror backend URL, responses are discarded / 镜像后端URL，响应会被丢弃
+	Percentage  *float64      `toml:"percentage"`    // Percentage of requests mirrored, 0 for none (default 100) / 被镜像的请求百分比，0 表示不镜像（默认100）
+	Timeout     time.Duration `toml:"timeout"`       // Timeout of a mirrored request (default 5s) / 镜像请求的超时时间（默认5秒）
+	MaxInFlight int           `toml:"max_in_flight"` // Mirrored requests in flight before copies are dropped (default 100) / 超过该数量的进行中镜像请求时丢弃副本（默认100）
+}
+
+// WithDefaults returns a copy of the mirror config with defaults filled in, only an unset percentage defaults to 100
+// 返回填充了默认值的流量镜像配置副本，只有未设置的百分比默认为100
+func (m Mirror) WithDefaults() Mirror {
+	if m.Percentage == nil {
+		percentage := float64(DefaultMirrorPercentage)
+		m.Percentage = &percentage
+	}
+	if m.Timeout == 0 {
+		m.Timeout = DefaultMirrorTimeout
+	}
+	if m.MaxInFlight == 0 {
+		m.MaxInFlight = DefaultMirrorMaxInFlight
+	}
+	return m
+}
+
+// validateMirror validates the traffic mirroring configuration of a route
+// 验证路由的流量镜像配置
+func validateMirror(route Route) error {
+	if !route.Mirror.Enabled {
+		return nil
+	}
+	mirror := route.Mirror.WithDefaults()
+
+	if mirror.Backend == "" {
+		logger.Error("mirror requires a backend", zap.String("path", route.Path))
+		return fmt.Errorf("mirror of route %s requires a backend", route.ID())
+	}
+	if err := validateSingleBackend(route.Path, mirror.Backend, route.UpstreamTLS); err != nil {
+		return err
+	}
+	if slices.Contains(route.Backends, mirror.Backend) {
+		logger.Warn("mirror backend also serves the route, it receives mirrored copies on top of its share of traffic",
+			zap.String("path", route.Path),
+			zap.String("backend", mirror.Backend))
+	}
+
+	if *mirror.Percentage < 0 || *mirror.Percentage > 100 {
+		logger.Error("mirror percentage must be between 0 and 100", zap.String("path", route.Path), zap.Float64("percentage", *mirror.Percentage))
+		return fmt.Errorf("mirror percentage of route %s must be between 0 and 100", route.ID())
+	}
+	if mirror.Timeout < 0 || mirror.MaxInFlight < 0 {
+		logger.Error("mirror timeout and max_in_flight must not be negative",
+			zap.String("path", route.Path),
+			zap.Duration("timeout", mirror.Timeout),
+			zap.Int("max_in_flight", mirror.MaxInFlight))
+		return fmt.Errorf("mirror timeout and max_in_flight of route %s must not be negative", route.ID())
+	}
+	if *mirror.Percentage == 0 {
+		logger.Warn("mirror percentage is 0, no requests are mirrored", zap.String("path", route.Path))
+	}
+	if route.Streaming {
+		logger.Warn("mirroring skips requests with streamed bodies", zap.String("path", route.Path))
+	}
+
+	return nil
+}
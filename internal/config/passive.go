@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 所有后端都不可用时的处理方式
+// What to do when every backend is down
+const (
+	AllDownFailOpen = "fail_open" // Reset and send requests to every enabled backend / 重置并向所有启用的后端发送请求
+	AllDownReject   = "reject"    // Answer 503 until a backend recovers / 在后端恢复前返回 503
+)
+
+// 被动健康检查的默认值
+// Defaults for passive health checks
+const (
+	DefaultPassiveHealthMaxFails    = 3
+	DefaultPassiveHealthFailTimeout = 30 * time.Second
+)
+
+// DefaultFailureStatus 默认计为后端失败的状态码
+// DefaultFailureStatus are the status codes counted as backend failures by default
+var DefaultFailureStatus = []int{502, 503, 504}
+
+type PassiveHealth struct {
+	MaxFails      int           `toml:"max_fails"`      // Consecutive failures marking a backend unhealthy (default 3) / 连续失败多少次后标记后端不健康（默认3）
+	FailTimeout   time.Duration `toml:"fail_timeout"`   // Time before an unhealthy backend is retried (default 30s) / 不健康的后端多久后重试（默认30秒）
+	FailureStatus []int         `toml:"failure_status"` // 5xx status codes counted as failures, [] for none (default 502, 503, 504) / 计为失败的5xx状态码，[] 表示不计（默认502、503、504）
+	AllDown       string        `toml:"all_down"`       // fail_open or reject when every backend is down / 所有后端都不可用时使用 fail_open 或 reject
+}
+
+// WithDefaults returns a copy of the passive health config with defaults filled in
+// 返回填充了默认值的被动健康检查配置副本
+func (p PassiveHealth) WithDefaults() PassiveHealth {
+	if p.MaxFails == 0 {
+		p.MaxFails = DefaultPassiveHealthMaxFails
+	}
+	if p.FailTimeout == 0 {
+		p.FailTimeout = DefaultPassiveHealthFailTimeout
+	}
+	if p.FailureStatus == nil {
+		p.FailureStatus = DefaultFailureStatus
+	}
+	return p
+}
+
+// IsFailureStatus 判断后端响应的状态码是否计为失败
+// IsFailureStatus reports whether a backend response status counts as a failure
+func (r Route) IsFailureStatus(statusCode int) bool {
+	return slices.Contains(r.PassiveHealth.WithDefaults().FailureStatus, statusCode)
+}
+
+// AllDownPolicy 返回所有后端都不可用时的处理方式。未设置时，启用主动健康检查或熔断器的路由返回 503，
+// 其他路由重置所有后端
+// AllDownPolicy returns what to do when every backend is down. When unset, routes with active health checks or
+// circuit breakers answer 503 and other routes reset every backend
+func (r Route) AllDownPolicy() string {
+	if r.PassiveHealth.AllDown != "" {
+		return r.PassiveHealth.AllDown
+	}
+	if r.HealthCheck.Enabled || r.CircuitBreaker.Enabled {
+		return AllDownReject
+	}
+	return AllDownFailOpen
+}
+
+// validatePassiveHealth validates the passive health configuration of a route
+// 验证路由的被动健康检查配置
+func validatePassiveHealth(route Route) error {
+	passive := route.PassiveHealth.WithDefaults()
+
+	if passive.MaxFails < 0 || passive.FailTimeout < 0 {
+		logger.Error("passive health max_fails and fail_timeout must not be negative",
+			zap.String("path", route.Path),
+			zap.Int("max_fails", passive.MaxFails),
+			zap.Duration("fail_timeout", passive.FailTimeout))
+		return fmt.Errorf("passive health max_fails and fail_timeout of route %s must not be negative", route.ID())
+	}
+
+	for _, status := range passive.FailureStatus {
+		if status < 500 || status > 599 {
+			logger.Error("passive health failure status must be a 5xx status code", zap.String("path", route.Path), zap.Int("status", status))
+			return fmt.Errorf("passive health failure status of route %s must be a 5xx status code: %d", route.ID(), status)
+		}
+	}
+
+	switch passive.AllDown {
+	case "", AllDownFailOpen, AllDownReject:
+	default:
+		logger.Error("passive health all_down is not valid", zap.String("path", route.Path), zap.String("all_down", passive.AllDown))
+		return fmt.Errorf("passive health all_down of route %s must be %s or %s: %s", route.ID(), AllDownFailOpen, AllDownReject, passive.AllDown)
+	}
+
+	if route.CircuitBreaker.Enabled && (route.PassiveHealth.MaxFails != 0 || route.PassiveHealth.FailTimeout != 0) {
+		logger.Warn("max_fails and fail_timeout have no effect with a circuit breaker", zap.String("path", route.Path))
+	}
+	if route.HealthCheck.Enabled && route.PassiveHealth.FailTimeout != 0 {
+		logger.Warn("fail_timeout has no effect with active health checks, backends recover through probes", zap.String("path", route.Path))
+	}
+
+	return nil
+}
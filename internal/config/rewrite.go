@@ -0,0 +1,267 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// 重写规则的匹配方式
+// Match types of rewrite rules
+const (
+	RewriteMatchExact  = "exact"
+	RewriteMatchPrefix = "prefix"
+	RewriteMatchRegex  = "regex"
+)
+
+type RewriteRule struct {
+	Match string   `toml:"match"` // exact, prefix (default) or regex / 匹配方式：exact、prefix（默认）或 regex
+	From  string   `toml:"from"`  // Path, prefix or regular expression to match / 要匹配的路径、前缀或正则表达式
+	To    string   `toml:"to"`    // Replacement, regex may use $1 or ${name}, text after ? is added to the query (empty = path unchanged) / 替换值，regex 可以使用 $1 或 ${name}，? 之后的部分加入查询字符串（为空时路径不变）
+	Last  bool     `toml:"last"`  // Stop evaluating later rules after this one matched / 匹配后不再执行后续规则
+	Query QueryOps `toml:"query"` // Query parameter operations applied when the rule matches / 规则匹配时执行的查询参数操作
+}
+
+// QueryOps 查询参数操作，依次执行 remove、rename、set、add
+// QueryOps are query parameter operations, applied in the order remove, rename, set, add
+type QueryOps struct {
+	Remove []string          `toml:"remove"` // Parameters to remove / 要移除的参数
+	Rename map[string]string `toml:"rename"` // Old name to new name, values are kept / 旧名称到新名称，保留值
+	Set    map[string]string `toml:"set"`    // Parameters to replace / 要替换的参数
+	Add    map[string]string `toml:"add"`    // Parameters to append / 要追加的参数
+}
+
+// 编译后的正则表达式，按表达式缓存，热重载时未变化的表达式不需要重新编译
+// Compiled regular expressions cached by expression, so unchanged expressions are not recompiled on hot reload
+var rewriteRegexps sync.Map
+
+// isEmpty 判断是否没有任何查询参数操作
+// isEmpty reports whether there are no query parameter operations
+func (q QueryOps) isEmpty() bool {
+	return len(q.Remove) == 0 && len(q.Rename) == 0 && len(q.Set) == 0 && len(q.Add) == 0
+}
+
+// apply 对查询参数执行操作
+// apply applies the operations to the query parameters
+func (q QueryOps) apply(values url.Values) {
+	for _, name := range q.Remove {
+		values.Del(name)
+	}
+	for from, to := range q.Rename {
+		if renamed, exists := values[from]; exists {
+			delete(values, from)
+			values[to] = renamed
+		}
+	}
+	for name, value := range q.Set {
+		values.Set(name, value)
+	}
+	for name, value := range q.Add {
+		values.Add(name, value)
+	}
+}
+
+// WithDefaults returns a copy of the rewrite rule with defaults filled in
+// 返回填充了默认值的重写规则副本
+func (r RewriteRule) WithDefaults() RewriteRule {
+	if r.Match == "" {
+		r.Match = RewriteMatchPrefix
+	}
+	return r
+}
+
+// Regexp 返回 regex 规则编译后的正则表达式
+// Regexp returns the compiled regular expression of a regex rule
+func (r RewriteRule) Regexp() (*regexp.Regexp, error) {
+	if compiled, ok := rewriteRegexps.Load(r.From); ok {
+		return compiled.(*regexp.Regexp), nil
+	}
+	compiled, err := regexp.Compile(r.From)
+	if err != nil {
+		return nil, err
+	}
+	rewriteRegexps.Store(r.From, compiled)
+	return compiled, nil
+}
+
+// rewrite 规则匹配时返回重写后的路径
+// rewrite returns the rewritten path when the rule matches
+func (r RewriteRule) rewrite(path string) (string, bool) {
+	switch r.Match {
+	case RewriteMatchExact:
+		if path != r.From {
+			return path, false
+		}
+		if r.To == "" {
+			return path, true
+		}
+		return r.To, true
+	case RewriteMatchRegex:
+		re, err := r.Regexp()
+		if err != nil || !re.MatchString(path) {
+			return path, false
+		}
+		if r.To == "" {
+			return path, true
+		}
+		return re.ReplaceAllString(path, r.To), true
+	default:
+		if !strings.HasPrefix(path, r.From) {
+			return path, false
+		}
+		if r.To == "" {
+			return path, true
+		}
+		return r.To + path[len(r.From):], true
+	}
+}
+
+// RewriteRules 返回路由的重写规则，rewrite_from 和 rewrite_to 作为第一个前缀规则
+// RewriteRules returns the rewrite rules of the route, rewrite_from and rewrite_to being the first prefix rule
+func (r Route) RewriteRules() []RewriteRule {
+	rules := make([]RewriteRule, 0, len(r.Rewrites)+1)
+	if r.RewriteFrom != "" && r.RewriteTo != "" {
+		rules = append(rules, RewriteRule{Match: RewriteMatchPrefix, From: r.RewriteFrom, To: r.RewriteTo})
+	}
+	for _, rule := range r.Rewrites {
+		rules = append(rules, rule.WithDefaults())
+	}
+	return rules
+}
+
+// RewriteStep 一条匹配的重写规则及其结果
+// RewriteStep is a matched rewrite rule and its result
+type RewriteStep struct {
+	Rule  RewriteRule
+	Path  string
+	Query string
+}
+
+// Rewrite 去掉路由前缀后依次执行匹配的重写规则，返回发送给后端的路径、查询字符串以及每个匹配的步骤。
+// 没有查询参数操作时查询字符串保持原样
+// Rewrite removes the route prefix from the request path, applies the matching rewrite rules in order and returns the
+// path and query string sent to the backend along with each matched step. The query string is kept as is without query
+// operations
+func (r Route) Rewrite(requestPath, query string) (string, string, []RewriteStep) {
+	path := normalizeBackendPath(requestPath[min(len(r.Path), len(requestPath)):])
+
+	var steps []RewriteStep
+	var values url.Values
+	for _, rule := range r.RewriteRules() {
+		rewritten, matched := rule.rewrite(path)
+		if !matched {
+			continue
+		}
+		// 替换值中 ? 之后的部分追加到查询字符串之前
+		// The part of the replacement after ? is prepended to the query string
+		rewritten, extraQuery, hasQuery := strings.Cut(rewritten, "?")
+		path = normalizeBackendPath(rewritten)
+		if hasQuery && extraQuery != "" {
+			if query != "" {
+				extraQuery += "&" + query
+			}
+			query = extraQuery
+			values = nil
+		}
+
+		if !rule.Query.isEmpty() {
+			if values == nil {
+				// 无法解析的参数会被丢弃，与后端的常见行为一致
+				// Parameters that cannot be parsed are dropped, as most backends would do
+				values, _ = url.ParseQuery(query)
+			}
+			rule.Query.apply(values)
+			query = values.Encode()
+		}
+
+		steps = append(steps, RewriteStep{Rule: rule, Path: path, Query: query})
+		if rule.Last {
+			break
+		}
+	}
+	return path, query, steps
+}
+
+// normalizeBackendPath 确保路径能正确拼接到后端 URL
+// normalizeBackendPath makes sure the path joins cleanly with the backend URL
+func normalizeBackendPath(path string) string {
+	if path != "" && !strings.HasPrefix(path, "/") {
+		return "/" + path
+	}
+	return path
+}
+
+// validateRewriteRules validates the ordered rewrite rules of a route
+// 验证路由的有序重写规则
+func validateRewriteRules(route Route) error {
+	for i, rule := range route.Rewrites {
+		rule = rule.WithDefaults()
+		fields := []zap.Field{zap.String("path", route.Path), zap.Int("rule", i+1), zap.String("from", rule.From)}
+
+		switch rule.Match {
+		case RewriteMatchExact, RewriteMatchPrefix:
+			if !strings.HasPrefix(rule.From, "/") {
+				logger.Error("rewrite rule must match a path starting with /", fields...)
+				return fmt.Errorf("rewrite rule %d of route %s must match a path starting with /", i+1, route.Path)
+			}
+			if rule.To != "" && !strings.HasPrefix(rule.To, "/") {
+				logger.Error("rewrite rule target must start with /", append(fields, zap.String("to", rule.To))...)
+				return fmt.Errorf("rewrite rule %d of route %s must rewrite to a path starting with /", i+1, route.Path)
+			}
+		case RewriteMatchRegex:
+			re, err := rule.Regexp()
+			if err != nil {
+				logger.Error("rewrite rule regular expression is not valid", append(fields, zap.Error(err))...)
+				return fmt.Errorf("rewrite rule %d of route %s has an invalid regular expression: %v", i+1, route.Path, err)
+			}
+			if err := validateRegexReplacement(re, rule.To); err != nil {
+				logger.Error("rewrite rule replacement is not valid", append(fields, zap.String("to", rule.To), zap.Error(err))...)
+				return fmt.Errorf("rewrite rule %d of route %s has an invalid replacement: %v", i+1, route.Path, err)
+			}
+		default:
+			logger.Error("rewrite rule match type is not valid", append(fields, zap.String("match", rule.Match))...)
+			return fmt.Errorf("rewrite rule %d of route %s has an unknown match type %s, use %s, %s or %s",
+				i+1, route.Path, rule.Match, RewriteMatchExact, RewriteMatchPrefix, RewriteMatchRegex)
+		}
+
+		for from, to := range rule.Query.Rename {
+			if from == "" || to == "" {
+				logger.Error("rewrite rule query rename must not use empty names", fields...)
+				return fmt.Errorf("rewrite rule %d of route %s renames an empty query parameter", i+1, route.Path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// 替换值中对捕获组的引用，与 regexp.Expand 一样尽可能长地读取名称，因此 $1x 表示 ${1x}
+// References to capture groups in replacements, names are read as long as possible like regexp.Expand does, so $1x
+// means ${1x}
+var replacementReference = regexp.MustCompile(`\$(\{[^}]*\}|\w+)`)
+
+// validateRegexReplacement 检查替换值引用的捕获组是否存在
+// validateRegexReplacement checks that the capture groups referenced by the replacement exist
+func validateRegexReplacement(re *regexp.Regexp, replacement string) error {
+	// $$ 表示字面的 $
+	// $$ stands for a literal $
+	replacement = strings.ReplaceAll(replacement, "$$", "")
+	for _, reference := range replacementReference.FindAllStringSubmatch(replacement, -1) {
+		name := strings.Trim(reference[1], "{}")
+		if index, err := strconv.Atoi(name); err == nil {
+			if index > re.NumSubexp() {
+				return fmt.Errorf("$%s refers to a missing capture group, the expression has %d", reference[1], re.NumSubexp())
+			}
+			continue
+		}
+		if re.SubexpIndex(name) < 0 {
+			return fmt.Errorf("$%s refers to a missing named capture group, use ${n} before letters or digits", reference[1])
+		}
+	}
+	return nil
+}
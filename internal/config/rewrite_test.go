@@ -0,0 +1,149 @@
+package config
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRouteRewrite(t *testing.T) {
+	tests := []struct {
+		name        string
+		route       Route
+		requestPath string
+		query       string
+		wantPath    string
+		wantQuery   string
+		wantSteps   int
+	}{
+		{
+			name:        "strips the route prefix without rules",
+			route:       Route{Path: "/api"},
+			requestPath: "/api/users",
+			query:       "a=1",
+			wantPath:    "/users",
+			wantQuery:   "a=1",
+		},
+		{
+			name:        "request for the route path itself",
+			route:       Route{Path: "/api"},
+			requestPath: "/api",
+			wantPath:    "",
+		},
+		{
+			name:        "rewrite_from and rewrite_to",
+			route:       Route{Path: "/api", RewriteFrom: "/v1", RewriteTo: "/v2"},
+			requestPath: "/api/v1/users",
+			wantPath:    "/v2/users",
+			wantSteps:   1,
+		},
+		{
+			name:        "exact rule matches only the whole path",
+			route:       Route{Path: "/api", Rewrites: []RewriteRule{{Match: RewriteMatchExact, From: "/old", To: "/new"}}},
+			requestPath: "/api/old/child",
+			wantPath:    "/old/child",
+		},
+		{
+			name: "rules apply in order to the previous result",
+			route: Route{Path: "/api", Rewrites: []RewriteRule{
+				{From: "/users", To: "/people"},
+				{Match: RewriteMatchRegex, From: `^/people/(\d+)$`, To: "/person?id=$1"},
+			}},
+			requestPath: "/api/users/42",
+			query:       "a=1",
+			wantPath:    "/person",
+			wantQuery:   "id=42&a=1",
+			wantSteps:   2,
+		},
+		{
+			name: "named capture group",
+			route: Route{Path: "/api", Rewrites: []RewriteRule{
+				{Match: RewriteMatchRegex, From: `^/files/(?P<name>\w+)$`, To: "/f/${name}.txt"},
+			}},
+			requestPath: "/api/files/report",
+			wantPath:    "/f/report.txt",
+			wantSteps:   1,
+		},
+		{
+			name: "last stops later rules",
+			route: Route{Path: "/api", Rewrites: []RewriteRule{
+				{From: "/a", To: "/b", Last: true},
+				{From: "/b", To: "/c"},
+			}},
+			requestPath: "/api/a",
+			wantPath:    "/b",
+			wantSteps:   1,
+		},
+		{
+			name: "query operations",
+			route: Route{Path: "/api", Rewrites: []RewriteRule{{
+				Match: RewriteMatchExact,
+				From:  "/q",
+				Query: QueryOps{
+					Remove: []string{"x"},
+					Rename: map[string]string{"old": "new"},
+					Set:    map[string]string{"s": "1"},
+					Add:    map[string]string{"a": "2"},
+				},
+			}}},
+			requestPath: "/api/q",
+			query:       "x=1&old=v&s=0&a=1",
+			wantPath:    "/q",
+			wantQuery:   "a=1&a=2&new=v&s=1",
+			wantSteps:   1,
+		},
+		{
+			name:        "empty target keeps the path",
+			route:       Route{Path: "/api", Rewrites: []RewriteRule{{From: "/", Query: QueryOps{Set: map[string]string{"k": "v"}}}}},
+			requestPath: "/api/x",
+			wantPath:    "/x",
+			wantQuery:   "k=v",
+			wantSteps:   1,
+		},
+		{
+			name:        "target without leading slash",
+			route:       Route{Path: "/api", Rewrites: []RewriteRule{{Match: RewriteMatchRegex, From: `^/(\w+)$`, To: "$1/index"}}},
+			requestPath: "/api/docs",
+			wantPath:    "/docs/index",
+			wantSteps:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, query, steps := tt.route.Rewrite(tt.requestPath, tt.query)
+			if path != tt.wantPath || query != tt.wantQuery {
+				t.Fatalf("Rewrite = %q, %q, want %q, %q", path, query, tt.wantPath, tt.wantQuery)
+			}
+			if len(steps) != tt.wantSteps {
+				t.Fatalf("Rewrite matched %d steps, want %d", len(steps), tt.wantSteps)
+			}
+		})
+	}
+}
+
+func TestValidateRegexReplacement(t *testing.T) {
+	tests := []struct {
+		name        string
+		expression  string
+		replacement string
+		wantErr     bool
+	}{
+		{name: "numbered group", expression: `^/(\d+)$`, replacement: "/$1"},
+		{name: "braced numbered group", expression: `^/(\d+)$`, replacement: "/${1}x"},
+		{name: "named group", expression: `^/(?P<id>\d+)$`, replacement: "/${id}"},
+		{name: "literal dollar", expression: `^/(\d+)$`, replacement: "/$$2"},
+		{name: "no references", expression: `^/x$`, replacement: "/y"},
+		{name: "missing numbered group", expression: `^/(\d+)$`, replacement: "/$2", wantErr: true},
+		{name: "missing named group", expression: `^/(?P<id>\d+)$`, replacement: "/$idx", wantErr: true},
+		{name: "digits followed by letters", expression: `^/(\d+)$`, replacement: "/$1x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRegexReplacement(regexp.MustCompile(tt.expression), tt.replacement)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateRegexReplacement error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultStickyCookieMaxAge 粘性 Cookie 的默认有效期
+// DefaultStickyCookieMaxAge is the default lifetime of the sticky cookie
+const DefaultStickyCookieMaxAge = 24 * time.Hour
+
+type BackendGroup struct {
+	Name     string            `toml:"name"`     // Group name, e.g. stable or canary / 分组名称，例如 stable 或 canary
+	Backends []string          `toml:"backends"` // Backend URLs of the group / 分组的后端服务URL列表
+	Weight   int               `toml:"weight"`   // Percentage of requests, the weights of all groups add up to 100 / 请求百分比，所有分组的权重之和为100
+	Override map[string]string `toml:"override"` // Header name to value forcing this group, "*" only requires the header / 强制使用该分组的请求头名称到值，"*" 只要求请求头存在
+}
+
+type TrafficSplit struct {
+	StickyCookie string        `toml:"sticky_cookie"`  // Cookie keeping a client on its group, set by the gateway / 让客户端保持在同一分组的 Cookie，由网关设置
+	CookieMaxAge time.Duration `toml:"cookie_max_age"` // Lifetime of the sticky cookie (default 24h) / 粘性 Cookie 的有效期（默认24小时）
+	StickyHeader string        `toml:"sticky_header"`  // Header such as a user ID hashed to a group / 按哈希分配分组的请求头，例如用户 ID
+}
+
+// WithDefaults returns a copy of the traffic split config with defaults filled in
+// 返回填充了默认值的流量拆分配置副本
+func (s TrafficSplit) WithDefaults() TrafficSplit {
+	if s.CookieMaxAge == 0 {
+		s.CookieMaxAge = DefaultStickyCookieMaxAge
+	}
+	return s
+}
+
+// GroupBackends 返回所有分组的后端，按配置顺序去重
+// GroupBackends returns the backends of all groups in config order without duplicates
+func (r Route) GroupBackends() []string {
+	var backends []string
+	for _, group := range r.BackendGroups {
+		for _, backend := range group.Backends {
+			if !slices.Contains(backends, backend) {
+				backends = append(backends, backend)
+			}
+		}
+	}
+	return backends
+}
+
+// BackendGroup 返回给定名称的分组，不存在时返回 nil
+// BackendGroup returns the group with the given name, nil when it does not exist
+func (r Route) BackendGroup(name string) *BackendGroup {
+	for i := range r.BackendGroups {
+		if r.BackendGroups[i].Name == name {
+			return &r.BackendGroups[i]
+		}
+	}
+	return nil
+}
+
+// GroupForBucket 按权重返回 0 到 99 之间的桶所属的分组，权重改变时只有边界附近的桶会换组
+// GroupForBucket returns the group a bucket between 0 and 99 falls into by weight, changing the weights only moves the
+// buckets near the boundaries to another group
+func (r Route) GroupForBucket(bucket int) string {
+	for _, group := range r.BackendGroups {
+		if bucket < group.Weight {
+			return group.Name
+		}
+		bucket -= group.Weight
+	}
+	return ""
+}
+
+// expandBackendGroups 未设置 backends 的路由使用所有分组的后端，使按后端配置的选项对分组后端同样有效
+// expandBackendGroups sets the backends of routes without backends to the backends of all groups, so per-backend
+// options apply to group backends as well
+func expandBackendGroups(routes []Route) {
+	for i := range routes {
+		if len(routes[i].BackendGroups) > 0 && len(routes[i].Backends) == 0 {
+			routes[i].Backends = routes[i].GroupBackends()
+		}
+	}
+}
+
+// validateBackendGroups validates the backend groups and traffic split of a route
+// 验证路由的后端分组和流量拆分配置
+func validateBackendGroups(route Route) error {
+	split := route.Split.WithDefaults()
+	if len(route.BackendGroups) == 0 {
+		if split != (TrafficSplit{}).WithDefaults() {
+			logger.Error("split requires backend groups", zap.String("path", route.Path))
+			return fmt.Errorf("split of route %s requires backend groups", route.ID())
+		}
+		return nil
+	}
+
+	if !slices.Equal(route.Backends, route.GroupBackends()) {
+		logger.Error("route backends must be empty when backend groups are set", zap.String("path", route.Path))
+		return fmt.Errorf("route %s sets both backends and backend groups, list the backends in the groups only", route.ID())
+	}
+
+	total := 0
+	names := make(map[string]bool)
+	owners := make(map[string]string)
+	for _, group := range route.BackendGroups {
+		fields := []zap.Field{zap.String("path", route.Path), zap.String("group", group.Name)}
+		if group.Name == "" || strings.ContainsAny(group.Name, " ;,=\"") {
+			logger.Error("backend group name must be set and must not contain spaces or cookie separators", fields...)
+			return fmt.Errorf("backend group name of route %s must be set and must not contain spaces or cookie separators: %q", route.ID(), group.Name)
+		}
+		if names[group.Name] {
+			logger.Error("backend group name is duplicated", fields...)
+			return fmt.Errorf("backend group %s of route %s is duplicated", group.Name, route.ID())
+		}
+		names[group.Name] = true
+
+		if len(group.Backends) == 0 {
+			logger.Error("backend group has no backends", fields...)
+			return fmt.Errorf("backend group %s of route %s has no backends", group.Name, route.ID())
+		}
+		for _, backend := range group.Backends {
+			if owner, exists := owners[backend]; exists {
+				logger.Error("backend is listed in several backend groups", append(fields, zap.String("backend", backend), zap.String("other", owner))...)
+				return fmt.Errorf("backend %s of route %s is listed in groups %s and %s", backend, route.ID(), owner, group.Name)
+			}
+			owners[backend] = group.Name
+		}
+
+		if group.Weight < 0 || group.Weight > 100 {
+			logger.Error("backend group weight must be between 0 and 100", append(fields, zap.Int("weight", group.Weight))...)
+			return fmt.Errorf("weight of backend group %s of route %s must be between 0 and 100", group.Name, route.ID())
+		}
+		total += group.Weight
+
+		for name, value := range group.Override {
+			if name == "" || strings.ContainsAny(name, " :\r\n") || value == "" {
+				logger.Error("backend group override is not valid", append(fields, zap.String("header", name), zap.String("value", value))...)
+				return fmt.Errorf("override of backend group %s of route %s needs a valid header name and a value, use * to only require the header", group.Name, route.ID())
+			}
+		}
+	}
+
+	if total != 100 {
+		logger.Error("backend group weights must add up to 100", zap.String("path", route.Path), zap.Int("total", total))
+		return fmt.Errorf("backend group weights of route %s must add up to 100, got %d", route.ID(), total)
+	}
+
+	if split.StickyCookie != "" && strings.ContainsAny(split.StickyCookie, " ;,=\"") {
+		logger.Error("sticky cookie name is not valid", zap.String("path", route.Path), zap.String("sticky_cookie", split.StickyCookie))
+		return fmt.Errorf("sticky cookie name of route %s is not valid: %s", route.ID(), split.StickyCookie)
+	}
+	if split.CookieMaxAge < 0 {
+		logger.Error("sticky cookie max age must not be negative", zap.String("path", route.Path), zap.Duration("cookie_max_age", split.CookieMaxAge))
+		return fmt.Errorf("sticky cookie max age of route %s must not be negative", route.ID())
+	}
+	if split.StickyHeader != "" && strings.ContainsAny(split.StickyHeader, " :\r\n") {
+		logger.Error("sticky header name is not valid", zap.String("path", route.Path), zap.String("sticky_header", split.StickyHeader))
+		return fmt.Errorf("sticky header name of route %s is not valid: %s", route.ID(), split.StickyHeader)
+	}
+
+	return nil
+}
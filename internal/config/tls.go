@@ -0,0 +1,370 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TLS 终止的默认值
+// Defaults for TLS termination
+const (
+	DefaultTLSPort       = 443
+	DefaultTLSMinVersion = "1.2"
+)
+
+// 客户端证书认证转发给后端的默认请求头
+// Default headers forwarded to backends by client certificate authentication
+const (
+	DefaultClientCertSubjectHeader     = "X-Client-Cert-Subject"
+	DefaultClientCertFingerprintHeader = "X-Client-Cert-Fingerprint"
+)
+
+// 证书即将过期时发出警告的剩余时间
+// Remaining validity below which certificates are reported as expiring
+const certificateExpiryWarning = 14 * 24 * time.Hour
+
+// TLS 版本名称
+// TLS version names
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+type TLS struct {
+	Enabled      bool             `toml:"enabled"`       // Serve HTTPS / 提供 HTTPS
+	Port         int              `toml:"port"`          // HTTPS port on the same host (default 443) / 同一主机上的 HTTPS 端口（默认 443）
+	RedirectHTTP bool             `toml:"redirect_http"` // Redirect the plain port to HTTPS instead of serving routes / 明文端口重定向到 HTTPS，而不是提供路由
+	MinVersion   string           `toml:"min_version"`   // Minimum TLS version: 1.0, 1.1, 1.2 or 1.3 (default 1.2) / 最低 TLS 版本（默认 1.2）
+	CipherSuites []string         `toml:"cipher_suites"` // TLS 1.2 cipher suite names, Go defaults when empty / TLS 1.2 密码套件名称，为空时使用 Go 默认值
+	Certificates []TLSCertificate `toml:"certificate"`   // Certificates selected by SNI, the first is the default / 按 SNI 选择的证书，第一个为默认证书
+}
+
+type TLSCertificate struct {
+	CertFile string `toml:"cert_file"` // PEM certificate chain, relative to the config file / PEM 证书链，相对于配置文件
+	KeyFile  string `toml:"key_file"`  // PEM private key, relative to the config file / PEM 私钥，相对于配置文件
+}
+
+type UpstreamTLS struct {
+	CAFile             string `toml:"ca_file"`              // PEM CA bundle verifying backends, system roots when empty / 验证后端的 PEM CA 证书，为空时使用系统根证书
+	CertFile           string `toml:"cert_file"`            // PEM client certificate for mutual TLS / 双向 TLS 的 PEM 客户端证书
+	KeyFile            string `toml:"key_file"`             // PEM client private key / PEM 客户端私钥
+	ServerName         string `toml:"server_name"`          // Server name for SNI and verification, the backend host when empty / 用于 SNI 和验证的服务器名称，为空时使用后端主机名
+	InsecureSkipVerify *bool  `toml:"insecure_skip_verify"` // Do not verify backend certificates, for testing only, false in backend_tls verifies again / 不验证后端证书，仅用于测试，在 backend_tls 中设为 false 时重新验证
+}
+
+// SkipVerify 判断是否跳过后端证书验证，未设置时验证
+// SkipVerify reports whether backend certificate verification is skipped, verifying when unset
+func (t UpstreamTLS) SkipVerify() bool {
+	return t.InsecureSkipVerify != nil && *t.InsecureSkipVerify
+}
+
+type ClientCertAuth struct {
+	Enabled           bool     `toml:"enabled"`            // Require a verified client certificate, needs [tls] / 需要经过验证的客户端证书，需要启用 [tls]
+	CAFile            string   `toml:"ca_file"`            // PEM CA bundle verifying client certificates, relative to the config file / 验证客户端证书的 PEM CA 证书，相对于配置文件
+	AllowedSubjects   []string `toml:"allowed_subjects"`   // Allowed common names or RFC 2253 subjects (empty = any) / 允许的通用名称或 RFC 2253 主题（为空时允许任意）
+	AllowedSANs       []string `toml:"allowed_sans"`       // Allowed DNS, email, URI or IP SANs, "*.example.com" matches one label (empty = any) / 允许的 DNS、邮箱、URI 或 IP SAN，"*.example.com" 匹配一级（为空时允许任意）
+	SubjectHeader     string   `toml:"subject_header"`     // Header with the subject sent to the backend (default X-Client-Cert-Subject) / 发送给后端的主题请求头
+	FingerprintHeader string   `toml:"fingerprint_header"` // Header with the SHA-256 fingerprint sent to the backend (default X-Client-Cert-Fingerprint) / 发送给后端的 SHA-256 指纹请求头
+}
+
+// WithDefaults returns a copy of the client certificate auth config with defaults filled in
+// 返回填充了默认值的客户端证书认证配置副本
+func (a ClientCertAuth) WithDefaults() ClientCertAuth {
+	if a.SubjectHeader == "" {
+		a.SubjectHeader = DefaultClientCertSubjectHeader
+	}
+	if a.FingerprintHeader == "" {
+		a.FingerprintHeader = DefaultClientCertFingerprintHeader
+	}
+	return a
+}
+
+// LoadCAPool reads the CA bundle verifying client certificates
+// 读取验证客户端证书的 CA 证书
+func (a ClientCertAuth) LoadCAPool() (*x509.CertPool, error) {
+	return loadCertPool(a.CAFile)
+}
+
+// Merge returns a copy of the upstream TLS config with unset values taken from fallback
+// 返回合并后的上游 TLS 配置副本，未设置的值取自 fallback
+func (t UpstreamTLS) Merge(fallback UpstreamTLS) UpstreamTLS {
+	if t.CAFile == "" {
+		t.CAFile = fallback.CAFile
+	}
+	if t.CertFile == "" && t.KeyFile == "" {
+		t.CertFile, t.KeyFile = fallback.CertFile, fallback.KeyFile
+	}
+	if t.ServerName == "" {
+		t.ServerName = fallback.ServerName
+	}
+	if t.InsecureSkipVerify == nil {
+		t.InsecureSkipVerify = fallback.InsecureSkipVerify
+	}
+	return t
+}
+
+// resolvePaths returns a copy with the files resolved against the directory of the config file
+// 返回文件路径相对于配置文件所在目录解析后的副本
+func (t UpstreamTLS) resolvePaths(configPath string) UpstreamTLS {
+	t.CAFile = resolvePath(configPath, t.CAFile)
+	t.CertFile = resolvePath(configPath, t.CertFile)
+	t.KeyFile = resolvePath(configPath, t.KeyFile)
+	return t
+}
+
+// ClientConfig creates the TLS config used to connect to backends, nil when nothing is configured
+// 创建连接后端使用的 TLS 配置，未配置任何选项时返回 nil
+func (t UpstreamTLS) ClientConfig() (*tls.Config, error) {
+	if t == (UpstreamTLS{}) {
+		return nil, nil
+	}
+
+	clientConfig := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.SkipVerify(),
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if t.CAFile != "" {
+		pool, err := loadCertPool(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		clientConfig.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, fmt.Errorf("cert_file and key_file must be set together")
+		}
+		certificate, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate %s: %w", t.CertFile, err)
+		}
+		clientConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return clientConfig, nil
+}
+
+// loadCertPool 读取 PEM CA 证书文件
+// loadCertPool reads a PEM CA bundle
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", path)
+	}
+	return pool, nil
+}
+
+// WithDefaults returns a copy of the TLS config with defaults filled in
+// 返回填充了默认值的 TLS 配置副本
+func (t TLS) WithDefaults() TLS {
+	if t.Port == 0 {
+		t.Port = DefaultTLSPort
+	}
+	if t.MinVersion == "" {
+		t.MinVersion = DefaultTLSMinVersion
+	}
+	return t
+}
+
+// Version returns the minimum TLS version
+// 返回最低 TLS 版本
+func (t TLS) Version() (uint16, error) {
+	version, exists := tlsVersions[t.MinVersion]
+	if !exists {
+		return 0, fmt.Errorf("unknown TLS version: %s", t.MinVersion)
+	}
+	return version, nil
+}
+
+// CipherSuiteIDs returns the IDs of the configured cipher suites, nil when none are configured
+// 返回配置的密码套件ID，未配置时返回 nil
+func (t TLS) CipherSuiteIDs() ([]uint16, error) {
+	if len(t.CipherSuites) == 0 {
+		return nil, nil
+	}
+
+	ids := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		ids[suite.Name] = suite.ID
+	}
+
+	suites := make([]uint16, 0, len(t.CipherSuites))
+	for _, name := range t.CipherSuites {
+		id, exists := ids[name]
+		if !exists {
+			return nil, fmt.Errorf("unknown or insecure cipher suite: %s", name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
+
+// LoadCertificates loads the certificate pairs and parses their leaf certificates
+// 读取证书对并解析叶子证书
+func (t TLS) LoadCertificates() ([]tls.Certificate, error) {
+	certificates := make([]tls.Certificate, 0, len(t.Certificates))
+	for _, pair := range t.Certificates {
+		certificate, err := tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate %s: %w", pair.CertFile, err)
+		}
+		if certificate.Leaf == nil {
+			certificate.Leaf, err = x509.ParseCertificate(certificate.Certificate[0])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse certificate %s: %w", pair.CertFile, err)
+			}
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates, nil
+}
+
+// validateTLSConfig validates the TLS configuration and loads the certificates
+// 验证 TLS 配置并读取证书
+func validateTLSConfig(config *Config) error {
+	if !config.TLS.Enabled {
+		for _, route := range config.AllRoutes() {
+			if route.ClientCert.Enabled {
+				logger.Error("client certificate authentication requires TLS to be enabled", zap.String("path", route.Path))
+				return fmt.Errorf("client certificate authentication of route %s requires TLS to be enabled", route.Path)
+			}
+		}
+		return nil
+	}
+	tlsConfig := config.EffectiveTLS().WithDefaults()
+
+	if tlsConfig.Port < 1 || tlsConfig.Port > 65535 || tlsConfig.Port == config.Port {
+		logger.Error("TLS port is not valid", zap.Int("port", tlsConfig.Port))
+		return fmt.Errorf("TLS port is not valid, it must differ from the plain port: %d", tlsConfig.Port)
+	}
+
+	if _, err := tlsConfig.Version(); err != nil {
+		logger.Error("TLS min version is not valid", zap.String("min_version", tlsConfig.MinVersion))
+		return err
+	}
+
+	if _, err := tlsConfig.CipherSuiteIDs(); err != nil {
+		logger.Error("TLS cipher suites are not valid", zap.Strings("cipher_suites", tlsConfig.CipherSuites), zap.Error(err))
+		return err
+	}
+	if len(tlsConfig.CipherSuites) > 0 && tlsConfig.MinVersion == "1.3" {
+		logger.Warn("cipher_suites have no effect with TLS 1.3 only, its cipher suites are not configurable")
+	}
+
+	if len(tlsConfig.Certificates) == 0 {
+		logger.Error("TLS is enabled but no certificates are configured")
+		return fmt.Errorf("TLS is enabled but no certificates are configured")
+	}
+
+	certificates, err := tlsConfig.LoadCertificates()
+	if err != nil {
+		logger.Error("failed to load TLS certificates", zap.Error(err))
+		return err
+	}
+	for i, certificate := range certificates {
+		remaining := time.Until(certificate.Leaf.NotAfter)
+		fields := []zap.Field{
+			zap.String("cert_file", tlsConfig.Certificates[i].CertFile),
+			zap.Strings("dnsNames", certificate.Leaf.DNSNames),
+			zap.Time("notAfter", certificate.Leaf.NotAfter),
+		}
+		switch {
+		case remaining <= 0:
+			logger.Warn("TLS certificate has expired", fields...)
+		case remaining < certificateExpiryWarning:
+			logger.Warn("TLS certificate expires soon", fields...)
+		}
+	}
+
+	return nil
+}
+
+// validateUpstreamTLS validates the upstream TLS configs of a route and its backends
+// 验证路由及其后端的上游 TLS 配置
+func validateUpstreamTLS(route Route) error {
+	for backend := range route.BackendTLS {
+		if !slices.Contains(route.Backends, backend) {
+			logger.Error("backend_tls references an unknown backend", zap.String("path", route.Path), zap.String("backend", backend))
+			return fmt.Errorf("backend_tls references an unknown backend: %s", backend)
+		}
+	}
+
+	for _, backend := range route.Backends {
+		upstreamTLS := route.UpstreamTLSFor(backend)
+		if upstreamTLS == (UpstreamTLS{}) {
+			continue
+		}
+
+		if _, err := upstreamTLS.ClientConfig(); err != nil {
+			logger.Error("upstream TLS config is not valid", zap.String("path", route.Path), zap.String("backend", backend), zap.Error(err))
+			return fmt.Errorf("upstream TLS config of backend %s is not valid: %v", backend, err)
+		}
+
+		if !strings.HasPrefix(backend, "https://") && !strings.HasPrefix(backend, "wss://") {
+			logger.Warn("upstream TLS config is ignored for a plain HTTP backend", zap.String("path", route.Path), zap.String("backend", backend))
+			continue
+		}
+
+		if upstreamTLS.SkipVerify() {
+			logger.Warn("insecure_skip_verify disables certificate verification of the backend, do not use it in production",
+				zap.String("path", route.Path),
+				zap.String("backend", backend))
+		}
+	}
+
+	return nil
+}
+
+// validateClientCertAuth validates the client certificate auth config of a route and loads its CA bundle
+// 验证路由的客户端证书认证配置并读取 CA 证书
+func validateClientCertAuth(route Route) error {
+	if !route.ClientCert.Enabled {
+		return nil
+	}
+	auth := route.ClientCert.WithDefaults()
+
+	if auth.CAFile == "" {
+		logger.Error("client certificate authentication requires ca_file", zap.String("path", route.Path))
+		return fmt.Errorf("client certificate authentication of route %s requires ca_file", route.Path)
+	}
+	if _, err := auth.LoadCAPool(); err != nil {
+		logger.Error("failed to load client CA file", zap.String("path", route.Path), zap.String("ca_file", auth.CAFile), zap.Error(err))
+		return err
+	}
+
+	for _, header := range []string{auth.SubjectHeader, auth.FingerprintHeader} {
+		if strings.ContainsAny(header, " :") {
+			logger.Error("client certificate header name is not valid", zap.String("path", route.Path), zap.String("header", header))
+			return fmt.Errorf("client certificate header name is not valid: %s", header)
+		}
+	}
+	if strings.EqualFold(auth.SubjectHeader, auth.FingerprintHeader) {
+		logger.Error("client certificate subject and fingerprint headers must differ", zap.String("path", route.Path))
+		return fmt.Errorf("client certificate subject and fingerprint headers must differ")
+	}
+
+	for _, san := range auth.AllowedSANs {
+		if san == "*." || strings.Contains(strings.TrimPrefix(san, "*."), "*") {
+			logger.Error("allowed SAN wildcard is not valid", zap.String("path", route.Path), zap.String("san", san))
+			return fmt.Errorf("allowed SAN wildcard is not valid, only a leading \"*.\" is supported: %s", san)
+		}
+	}
+
+	return nil
+}
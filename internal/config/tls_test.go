@@ -0,0 +1,42 @@
+package config
+
+import "testing"
+
+func TestUpstreamTLSMergeSkipVerify(t *testing.T) {
+	skip, verify := true, false
+
+	tests := []struct {
+		name     string
+		backend  *bool
+		route    *bool
+		wantSkip bool
+	}{
+		{name: "both unset", wantSkip: false},
+		{name: "route skips", route: &skip, wantSkip: true},
+		{name: "backend skips", backend: &skip, wantSkip: true},
+		{name: "backend verifies again", backend: &verify, route: &skip, wantSkip: false},
+		{name: "backend skips over route", backend: &skip, route: &verify, wantSkip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := Route{
+				Backends:    []string{"https://backend"},
+				UpstreamTLS: UpstreamTLS{InsecureSkipVerify: tt.route},
+				BackendTLS:  map[string]UpstreamTLS{"https://backend": {InsecureSkipVerify: tt.backend}},
+			}
+			upstreamTLS := route.UpstreamTLSFor("https://backend")
+			if got := upstreamTLS.SkipVerify(); got != tt.wantSkip {
+				t.Fatalf("SkipVerify = %v, want %v", got, tt.wantSkip)
+			}
+
+			clientConfig, err := upstreamTLS.ClientConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clientConfig != nil && clientConfig.InsecureSkipVerify != tt.wantSkip {
+				t.Fatalf("ClientConfig InsecureSkipVerify = %v, want %v", clientConfig.InsecureSkipVerify, tt.wantSkip)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type VHost struct {
+	Name         string            `toml:"name"`           // Unique name, prefixes the IDs of its routes / 唯一名称，作为其路由标识的前缀
+	Hosts        []string          `toml:"hosts"`          // Host names, "*.example.com" matches any subdomain / 主机名，"*.example.com" 匹配任意子域名
+	Default      bool              `toml:"default"`        // Serve requests whose host matches no vhost / 处理主机名没有匹配任何虚拟主机的请求
+	Certificates []TLSCertificate  `toml:"certificate"`    // Certificates added to the [tls] SNI selection / 加入 [tls] SNI 选择的证书
+	CachePrefix  string            `toml:"cache_prefix"`   // Prefix of the cache keys of its routes / 其路由缓存键的前缀
+	UaClient     string            `toml:"ua_client"`      // Default User-Agent of its routes / 其路由的默认用户代理
+	Timeouts     Timeouts          `toml:"timeouts"`       // Default backend timeouts of its routes, override [timeouts] / 其路由的默认后端超时，覆盖全局 [timeouts]
+	Headers      map[string]string `toml:"custom_headers"` // Default custom headers of its routes / 其路由的默认自定义头部
+	Routes       []Route           `toml:"route"`          // Routes of the vhost / 虚拟主机的路由
+}
+
+// MatchesHost 判断请求的 Host 是否属于该虚拟主机，exact 为 true 时只比较精确的主机名，否则只比较通配主机名
+// MatchesHost reports whether the request Host belongs to the vhost, comparing only exact host names when exact is
+// true and only wildcard host names otherwise
+func (v VHost) MatchesHost(host string, exact bool) bool {
+	host = requestHostName(host)
+	return slices.ContainsFunc(v.Hosts, func(pattern string) bool {
+		return strings.HasPrefix(pattern, "*.") != exact && matchHost(pattern, host)
+	})
+}
+
+// OrderedRoutes 返回按匹配顺序排列的路由：优先级高的在前，优先级相同时保持配置顺序
+// OrderedRoutes returns the routes in match order: higher priority first, config order for equal priorities
+func (v VHost) OrderedRoutes() []Route {
+	routes := slices.Clone(v.Routes)
+	slices.SortStableFunc(routes, func(a, b Route) int { return b.Priority - a.Priority })
+	return routes
+}
+
+// CandidateRoutes 按匹配顺序返回路径匹配的路由，到第一个没有匹配条件的路由为止，之后的路由不会被匹配到
+// CandidateRoutes returns the routes whose path matches in match order, up to the first route without match
+// conditions since later routes can never be reached
+func (v VHost) CandidateRoutes(path string) []Route {
+	var candidates []Route
+	for _, route := range v.OrderedRoutes() {
+		if !route.MatchesPath(path) {
+			continue
+		}
+		candidates = append(candidates, route)
+		if route.Match.IsEmpty() {
+			break
+		}
+	}
+	return candidates
+}
+
+// VirtualHosts 返回所有虚拟主机，顶层路由组成没有名称的默认虚拟主机
+// VirtualHosts returns all vhosts, the top-level routes form an unnamed default vhost
+func (c *Config) VirtualHosts() []VHost {
+	vhosts := slices.Clone(c.VHosts)
+	if len(c.Routes) > 0 {
+		vhosts = append(vhosts, VHost{Default: true, Routes: c.Routes})
+	}
+	return vhosts
+}
+
+// AllRoutes 返回顶层路由和所有虚拟主机的路由
+// AllRoutes returns the top-level routes and the routes of every vhost
+func (c *Config) AllRoutes() []Route {
+	routes := slices.Clone(c.Routes)
+	for _, vhost := range c.VHosts {
+		routes = append(routes, vhost.Routes...)
+	}
+	return routes
+}
+
+// EffectiveTLS 返回加入了虚拟主机证书的 TLS 配置，[tls] 中的第一个证书仍是默认证书
+// EffectiveTLS returns the TLS config with the vhost certificates added, the first [tls] certificate stays the default
+func (c *Config) EffectiveTLS() TLS {
+	tls := c.TLS
+	tls.Certificates = slices.Clone(tls.Certificates)
+	for _, vhost := range c.VHosts {
+		tls.Certificates = append(tls.Certificates, vhost.Certificates...)
+	}
+	return tls
+}
+
+// SelectVHost 返回处理该 Host 的虚拟主机的下标：先匹配精确主机名，再按配置顺序匹配通配主机名，最后使用默认虚拟主机。
+// 没有虚拟主机可以处理时返回 -1
+// SelectVHost returns the index of the vhost serving the Host: exact host names first, then wildcard host names in
+// config order, then the default vhost. It returns -1 when no vhost serves the Host
+func SelectVHost(vhosts []VHost, host string) int {
+	for _, exact := range []bool{true, false} {
+		for i := range vhosts {
+			if vhosts[i].MatchesHost(host, exact) {
+				return i
+			}
+		}
+	}
+	return slices.IndexFunc(vhosts, func(vhost VHost) bool { return vhost.Default })
+}
+
+// CandidateRoutes 返回处理该 Host 的虚拟主机以及请求路径可能匹配的路由
+// CandidateRoutes returns the vhost serving the Host and the routes the request path may match
+func (c *Config) CandidateRoutes(host, path string) (*VHost, []Route) {
+	vhosts := c.VirtualHosts()
+	i := SelectVHost(vhosts, host)
+	if i < 0 {
+		return nil, nil
+	}
+	return &vhosts[i], vhosts[i].CandidateRoutes(path)
+}
+
+// applyVHostDefaults 将虚拟主机名称、缓存前缀和默认值应用到其路由
+// applyVHostDefaults applies the vhost name, cache prefix and defaults to its routes
+func (v *VHost) applyVHostDefaults() {
+	for i := range v.Routes {
+		route := &v.Routes[i]
+		route.VHost = v.Name
+		route.CachePrefix = v.CachePrefix
+		if route.UaClient == "" {
+			route.UaClient = v.UaClient
+		}
+		route.Timeouts = route.Timeouts.Merge(v.Timeouts)
+		if len(v.Headers) > 0 {
+			headers := maps.Clone(v.Headers)
+			maps.Copy(headers, route.CustomHeaders)
+			route.CustomHeaders = headers
+		}
+	}
+}
+
+// validateVHosts validates the virtual hosts
+// 验证虚拟主机配置
+func validateVHosts(config *Config) error {
+	names := make(map[string]bool)
+	hosts := make(map[string]string)
+	defaultVHost := ""
+	if len(config.Routes) > 0 {
+		defaultVHost = "top-level routes"
+	}
+
+	for _, vhost := range config.VHosts {
+		if vhost.Name == "" || strings.ContainsAny(vhost.Name, " :/") {
+			logger.Error("vhost name must be set and must not contain spaces, : or /", zap.String("name", vhost.Name))
+			return fmt.Errorf("vhost name must be set and must not contain spaces, : or /: %q", vhost.Name)
+		}
+		if names[vhost.Name] {
+			logger.Error("vhost name is duplicated", zap.String("name", vhost.Name))
+			return fmt.Errorf("vhost name is duplicated: %s", vhost.Name)
+		}
+		names[vhost.Name] = true
+
+		if len(vhost.Hosts) == 0 && !vhost.Default {
+			logger.Error("vhost has no hosts and is not the default", zap.String("name", vhost.Name))
+			return fmt.Errorf("vhost %s has no hosts and is not the default", vhost.Name)
+		}
+		for _, host := range vhost.Hosts {
+			host = strings.ToLower(host)
+			if !matchHostPattern.MatchString(host) {
+				logger.Error("vhost host is not valid", zap.String("name", vhost.Name), zap.String("host", host))
+				return fmt.Errorf("host of vhost %s is not valid, use a host name without port or *.domain: %s", vhost.Name, host)
+			}
+			if other, exists := hosts[host]; exists {
+				logger.Error("vhost host is used by another vhost", zap.String("name", vhost.Name), zap.String("host", host), zap.String("other", other))
+				return fmt.Errorf("host %s of vhost %s is already used by vhost %s", host, vhost.Name, other)
+			}
+			hosts[host] = vhost.Name
+		}
+
+		if vhost.Default {
+			if defaultVHost != "" {
+				logger.Error("only one default vhost is allowed", zap.String("name", vhost.Name), zap.String("other", defaultVHost))
+				return fmt.Errorf("vhost %s cannot be the default, unmatched hosts are already served by %s", vhost.Name, defaultVHost)
+			}
+			defaultVHost = "vhost " + vhost.Name
+		}
+
+		if len(vhost.Routes) == 0 {
+			logger.Error("vhost has no routes", zap.String("name", vhost.Name))
+			return fmt.Errorf("vhost %s has no routes", vhost.Name)
+		}
+		if len(vhost.Certificates) > 0 && !config.TLS.Enabled {
+			logger.Warn("vhost certificates have no effect without [tls]", zap.String("name", vhost.Name))
+		}
+		if err := validateTimeouts("vhost "+vhost.Name, vhost.Timeouts); err != nil {
+			return err
+		}
+	}
+
+	if defaultVHost == "" && len(config.VHosts) > 0 {
+		logger.Warn("no default vhost, requests for unknown hosts get 404")
+	}
+
+	return nil
+}
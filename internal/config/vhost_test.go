@@ -0,0 +1,120 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSelectVHost(t *testing.T) {
+	vhosts := []VHost{
+		{Name: "wildcard", Hosts: []string{"*.example.com"}},
+		{Name: "api", Hosts: []string{"api.example.com"}},
+		{Name: "nested", Hosts: []string{"*.eu.example.com"}},
+		{Name: "fallback", Default: true},
+	}
+
+	tests := []struct {
+		name string
+		host string
+		want string
+	}{
+		{name: "exact beats an earlier wildcard", host: "api.example.com", want: "api"},
+		{name: "exact with port and case", host: "API.Example.com:8443", want: "api"},
+		{name: "wildcards in config order", host: "shop.eu.example.com", want: "wildcard"},
+		{name: "wildcard", host: "www.example.com", want: "wildcard"},
+		{name: "default for unknown hosts", host: "other.org", want: "fallback"},
+		{name: "default for the wildcard domain itself", host: "example.com", want: "fallback"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := SelectVHost(vhosts, tt.host)
+			if i < 0 || vhosts[i].Name != tt.want {
+				t.Fatalf("SelectVHost = %d, want %s", i, tt.want)
+			}
+		})
+	}
+
+	if i := SelectVHost(vhosts[:3], "other.org"); i != -1 {
+		t.Fatalf("SelectVHost without a default vhost = %d, want -1", i)
+	}
+}
+
+func TestConfigCandidateRoutes(t *testing.T) {
+	config := Config{
+		Routes: []Route{
+			{Path: "/api", Name: "top-api"},
+		},
+		VHosts: []VHost{
+			{
+				Name:  "shop",
+				Hosts: []string{"shop.example.com"},
+				Routes: []Route{
+					{Path: "/api", Name: "api"},
+					{Path: "/api/v2", Name: "v2", Match: RouteMatch{Methods: []string{"POST"}}},
+					{Path: "/api", Name: "beta", Priority: 10, Match: RouteMatch{Query: map[string]string{"beta": "1"}}},
+					{Path: "/api/v2", Name: "v2-fallback", Priority: 5},
+					{Path: "/static", Name: "static"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		host      string
+		path      string
+		wantVHost string
+		want      []string
+	}{
+		{
+			name:      "priority first, stopping at the first route without conditions",
+			host:      "shop.example.com",
+			path:      "/api/v2/orders",
+			wantVHost: "shop",
+			want:      []string{"beta", "v2-fallback"},
+		},
+		{
+			name:      "config order for equal priorities",
+			host:      "shop.example.com",
+			path:      "/api/orders",
+			wantVHost: "shop",
+			want:      []string{"beta", "api"},
+		},
+		{
+			name:      "no matching path",
+			host:      "shop.example.com",
+			path:      "/other",
+			wantVHost: "shop",
+		},
+		{
+			name: "top-level routes serve unknown hosts",
+			host: "other.org",
+			path: "/api/orders",
+			want: []string{"top-api"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vhost, routes := config.CandidateRoutes(tt.host, tt.path)
+			if vhost == nil || vhost.Name != tt.wantVHost {
+				t.Fatalf("CandidateRoutes vhost = %v, want %q", vhost, tt.wantVHost)
+			}
+			var names []string
+			for _, route := range routes {
+				names = append(names, route.Name)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Fatalf("CandidateRoutes = %v, want %v", names, tt.want)
+			}
+		})
+	}
+
+	// 没有顶层路由也没有默认虚拟主机时，未知主机没有虚拟主机处理
+	// Without top-level routes or a default vhost, unknown hosts are served by no vhost
+	config.Routes = nil
+	if vhost, routes := config.CandidateRoutes("other.org", "/api"); vhost != nil || routes != nil {
+		t.Fatalf("CandidateRoutes = %v, %v, want no vhost", vhost, routes)
+	}
+}
@@ -0,0 +1,148 @@
+package htpasswd
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 缓存验证成功结果的最大条目数，超过后清空
+// Maximum number of cached successful verifications, the cache is cleared when exceeded
+const maxCachedLogins = 1024
+
+// dummyHash 用户不存在时用于比较的 bcrypt 哈希，使未知用户的验证耗时与已知用户相同，无法据此枚举用户名
+// dummyHash is the bcrypt hash compared against for unknown users, so verifying them takes as long as for known users
+// and user names cannot be enumerated from response times
+const dummyHash = "$2a$10$n/RwBq0nEs9ZAOObzd39C.6i3usHYb0CPu2g.0jCWZ5M/JTNnM26q"
+
+// File htpasswd 文件中的用户和密码哈希
+// File holds the users and password hashes of an htpasswd file
+type File struct {
+	users map[string]string
+
+	// bcrypt 验证很慢，缓存验证成功的用户名和密码摘要，文件重新加载时随之丢弃
+	// bcrypt is slow, so digests of verified user names and passwords are cached and dropped with the file on reload
+	mu       sync.RWMutex
+	verified map[[sha256.Size]byte]bool
+}
+
+// Load 读取 htpasswd 文件。支持 bcrypt（$2y$、$2a$、$2b$）、{SHA} 以及 SHA-256/SHA-512 crypt（$5$、$6$）
+// Load reads an htpasswd file. Supported hashes are bcrypt ($2y$, $2a$, $2b$), {SHA} and SHA-256/SHA-512 crypt
+// ($5$, $6$)
+func Load(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open htpasswd file: %w", err)
+	}
+	defer f.Close()
+
+	file := &File{users: make(map[string]string), verified: make(map[[sha256.Size]byte]bool)}
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		user, hash, found := strings.Cut(line, ":")
+		if !found || user == "" || hash == "" {
+			return nil, fmt.Errorf("invalid entry on line %d of %s", lineNumber, path)
+		}
+		if !supported(hash) {
+			return nil, fmt.Errorf("unsupported password hash for user %s in %s, use bcrypt, {SHA}, $5$ or $6$", user, path)
+		}
+		if _, exists := file.users[user]; exists {
+			return nil, fmt.Errorf("user %s is duplicated in %s", user, path)
+		}
+		file.users[user] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read htpasswd file: %w", err)
+	}
+
+	return file, nil
+}
+
+// Len 返回用户数量
+// Len returns the number of users
+func (f *File) Len() int {
+	return len(f.users)
+}
+
+// Verify 检查用户名和密码是否匹配
+// Verify checks whether the user name and password match
+func (f *File) Verify(user, password string) bool {
+	hash, exists := f.users[user]
+	if !exists {
+		matches(dummyHash, password)
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(user + "\x00" + password + "\x00" + hash))
+	f.mu.RLock()
+	cached := f.verified[digest]
+	f.mu.RUnlock()
+	if cached {
+		return true
+	}
+
+	if !matches(hash, password) {
+		return false
+	}
+
+	f.mu.Lock()
+	if len(f.verified) >= maxCachedLogins {
+		clear(f.verified)
+	}
+	f.verified[digest] = true
+	f.mu.Unlock()
+	return true
+}
+
+// supported 判断是否支持该密码哈希格式
+// supported reports whether the password hash format is supported
+func supported(hash string) bool {
+	switch {
+	case isBcrypt(hash):
+		_, err := bcrypt.Cost([]byte(hash))
+		return err == nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(hash, "{SHA}"))
+		return err == nil && len(decoded) == sha1.Size
+	case strings.HasPrefix(hash, "$5$"), strings.HasPrefix(hash, "$6$"):
+		_, _, _, err := parseSHACrypt(hash)
+		return err == nil
+	}
+	return false
+}
+
+// matches 使用哈希对应的算法验证密码
+// matches verifies the password with the algorithm of the hash
+func matches(hash, password string) bool {
+	switch {
+	case isBcrypt(hash):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		expected := "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(expected)) == 1
+	case strings.HasPrefix(hash, "$5$"), strings.HasPrefix(hash, "$6$"):
+		expected, err := shaCrypt(hash, password)
+		return err == nil && subtle.ConstantTimeCompare([]byte(hash), []byte(expected)) == 1
+	}
+	return false
+}
+
+// isBcrypt 判断是否为 bcrypt 哈希
+// isBcrypt reports whether the hash is a bcrypt hash
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2y$") || strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$")
+}
@@ -0,0 +1,47 @@
+package htpasswd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	content := "alice:$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		want     bool
+	}{
+		{name: "correct password", user: "alice", password: "Hello world!", want: true},
+		{name: "cached correct password", user: "alice", password: "Hello world!", want: true},
+		{name: "wrong password", user: "alice", password: "hello world!", want: false},
+		{name: "unknown user", user: "bob", password: "Hello world!", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := file.Verify(tt.user, tt.password); got != tt.want {
+				t.Fatalf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDummyHashIsSupported(t *testing.T) {
+	// 未知用户的比较必须真正执行 bcrypt，否则无法掩盖耗时差异
+	// Comparing unknown users must really run bcrypt, otherwise the timing difference is not hidden
+	if !isBcrypt(dummyHash) || !supported(dummyHash) {
+		t.Fatal("dummyHash is not a valid bcrypt hash")
+	}
+}
@@ -0,0 +1,180 @@
+package htpasswd
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// SHA-crypt 的参数，见 https://www.akkadia.org/drepper/SHA-crypt.txt
+// SHA-crypt parameters, see https://www.akkadia.org/drepper/SHA-crypt.txt
+const (
+	shaCryptDefaultRounds = 5000
+	shaCryptMinRounds     = 1000
+	shaCryptMaxRounds     = 999999999
+	shaCryptMaxSaltLength = 16
+	shaCryptAlphabet      = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// 摘要字节在编码时的排列顺序，每组三个字节编码为四个字符
+// Order of the digest bytes in the encoding, each group of three bytes is encoded as four characters
+var (
+	sha256CryptOrder = [][3]int{
+		{0, 10, 20}, {21, 1, 11}, {12, 22, 2}, {3, 13, 23}, {24, 4, 14},
+		{15, 25, 5}, {6, 16, 26}, {27, 7, 17}, {18, 28, 8}, {9, 19, 29},
+	}
+	sha512CryptOrder = [][3]int{
+		{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4}, {47, 5, 26}, {6, 27, 48},
+		{28, 49, 7}, {50, 8, 29}, {9, 30, 51}, {31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13},
+		{56, 14, 35}, {15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19}, {62, 20, 41},
+	}
+)
+
+// parseSHACrypt 解析 $5$ 或 $6$ 哈希，返回前缀（包含 rounds 参数）、轮数和盐
+// parseSHACrypt parses a $5$ or $6$ hash and returns the prefix including the rounds parameter, the rounds and the salt
+func parseSHACrypt(hashed string) (prefix string, rounds int, salt string, err error) {
+	parts := strings.Split(hashed, "$")
+	// "$5$salt$hash" 或 "$5$rounds=N$salt$hash"
+	// "$5$salt$hash" or "$5$rounds=N$salt$hash"
+	if len(parts) != 4 && len(parts) != 5 {
+		return "", 0, "", fmt.Errorf("invalid SHA-crypt hash")
+	}
+
+	prefix = "$" + parts[1] + "$"
+	rounds = shaCryptDefaultRounds
+	salt = parts[2]
+	if len(parts) == 5 {
+		value, found := strings.CutPrefix(parts[2], "rounds=")
+		if !found {
+			return "", 0, "", fmt.Errorf("invalid SHA-crypt hash")
+		}
+		rounds, err = strconv.Atoi(value)
+		if err != nil {
+			return "", 0, "", fmt.Errorf("invalid SHA-crypt rounds: %s", value)
+		}
+		rounds = min(max(rounds, shaCryptMinRounds), shaCryptMaxRounds)
+		prefix += parts[2] + "$"
+		salt = parts[3]
+	}
+	if len(salt) > shaCryptMaxSaltLength {
+		salt = salt[:shaCryptMaxSaltLength]
+	}
+
+	return prefix, rounds, salt, nil
+}
+
+// shaCrypt 使用 hashed 中的参数计算密码的 SHA-crypt 哈希
+// shaCrypt computes the SHA-crypt hash of the password with the parameters of hashed
+func shaCrypt(hashed, password string) (string, error) {
+	prefix, rounds, salt, err := parseSHACrypt(hashed)
+	if err != nil {
+		return "", err
+	}
+
+	isSHA512 := strings.HasPrefix(prefix, "$6$")
+	newHash, order := sha256.New, sha256CryptOrder
+	if isSHA512 {
+		newHash, order = sha512.New, sha512CryptOrder
+	}
+
+	digest := shaCryptDigest(newHash, []byte(password), []byte(salt), rounds)
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	b.WriteString(salt)
+	b.WriteByte('$')
+	for _, group := range order {
+		encode24(&b, digest[group[0]], digest[group[1]], digest[group[2]], 4)
+	}
+	// 剩余的字节
+	// The remaining bytes
+	if isSHA512 {
+		encode24(&b, 0, 0, digest[63], 2)
+	} else {
+		encode24(&b, 0, digest[31], digest[30], 3)
+	}
+	return b.String(), nil
+}
+
+// shaCryptDigest 按 SHA-crypt 规范计算最终摘要
+// shaCryptDigest computes the final digest as specified by SHA-crypt
+func shaCryptDigest(newHash func() hash.Hash, password, salt []byte, rounds int) []byte {
+	h := newHash()
+	size := h.Size()
+
+	h.Write(password)
+	h.Write(salt)
+	h.Write(password)
+	alternate := h.Sum(nil)
+
+	h = newHash()
+	h.Write(password)
+	h.Write(salt)
+	h.Write(repeat(alternate, len(password)))
+	for n := len(password); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write(alternate)
+		} else {
+			h.Write(password)
+		}
+	}
+	digest := h.Sum(nil)
+
+	h = newHash()
+	for range password {
+		h.Write(password)
+	}
+	passwordSequence := repeat(h.Sum(nil), len(password))
+
+	h = newHash()
+	for i := 0; i < 16+int(digest[0]); i++ {
+		h.Write(salt)
+	}
+	saltSequence := repeat(h.Sum(nil), len(salt))
+
+	for i := 0; i < rounds; i++ {
+		h = newHash()
+		if i&1 != 0 {
+			h.Write(passwordSequence)
+		} else {
+			h.Write(digest[:size])
+		}
+		if i%3 != 0 {
+			h.Write(saltSequence)
+		}
+		if i%7 != 0 {
+			h.Write(passwordSequence)
+		}
+		if i&1 != 0 {
+			h.Write(digest[:size])
+		} else {
+			h.Write(passwordSequence)
+		}
+		digest = h.Sum(digest[:0])
+	}
+
+	return digest
+}
+
+// repeat 重复 data 直到长度为 n
+// repeat repeats data up to a length of n
+func repeat(data []byte, n int) []byte {
+	result := make([]byte, 0, n)
+	for len(result) < n {
+		result = append(result, data[:min(len(data), n-len(result))]...)
+	}
+	return result
+}
+
+// encode24 将三个字节编码为 n 个字符，低位在前
+// encode24 encodes three bytes as n characters, least significant bits first
+func encode24(b *strings.Builder, b2, b1, b0 byte, n int) {
+	w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for i := 0; i < n; i++ {
+		b.WriteByte(shaCryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
@@ -0,0 +1,297 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 支持的签名算法
+// Supported signing algorithms
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+// 令牌验证失败的原因
+// Reasons a token fails verification
+var (
+	ErrMalformed     = errors.New("token is malformed")
+	ErrAlgorithm     = errors.New("token algorithm is not allowed")
+	ErrSignature     = errors.New("token signature is invalid")
+	ErrExpired       = errors.New("token is expired")
+	ErrNotYetValid   = errors.New("token is not valid yet")
+	ErrIssuer        = errors.New("token issuer is not accepted")
+	ErrAudience      = errors.New("token audience is not accepted")
+	ErrMissingClaim  = errors.New("token is missing a required claim")
+	ErrNoMatchingKey = errors.New("no key matches the token")
+)
+
+// Claims 令牌中的声明
+// Claims are the claims of a token
+type Claims map[string]any
+
+// Subject 返回 sub 声明，不存在时返回空字符串
+// Subject returns the sub claim, or an empty string when it is missing
+func (c Claims) Subject() string {
+	subject, _ := c["sub"].(string)
+	return subject
+}
+
+// String 返回声明的字符串形式：字符串原样返回，数组以逗号连接，其他值使用 JSON
+// String returns a claim as a string: strings as is, arrays joined with commas, other values as JSON
+func (c Claims) String(name string) (string, bool) {
+	value, exists := c[name]
+	if !exists || value == nil {
+		return "", false
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				parts = append(parts, s)
+			} else {
+				data, _ := json.Marshal(item)
+				parts = append(parts, string(data))
+			}
+		}
+		return strings.Join(parts, ","), true
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	}
+}
+
+// Config 令牌验证配置
+// Config is the token verification configuration
+type Config struct {
+	Algorithms     []string      // 允许的算法，为空时允许密钥支持的所有算法 / Allowed algorithms, all supported by the keys when empty
+	Issuer         string        // 要求的 iss，为空时不检查 / Required iss, not checked when empty
+	Audience       []string      // 可接受的 aud，为空时不检查 / Accepted aud values, not checked when empty
+	RequiredClaims []string      // 必须存在的声明 / Claims that must be present
+	Leeway         time.Duration // exp 和 nbf 允许的时钟偏差 / Clock skew allowed for exp and nbf
+}
+
+// Verifier 验证令牌的签名和声明
+// Verifier verifies the signature and claims of tokens
+type Verifier struct {
+	config Config
+	keys   []Key
+}
+
+// NewVerifier 创建令牌验证器
+// NewVerifier creates a token verifier
+func NewVerifier(config Config, keys []Key) *Verifier {
+	return &Verifier{config: config, keys: keys}
+}
+
+// header 令牌头部
+// header is the token header
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// Verify 验证令牌并返回其声明
+// Verify verifies the token and returns its claims
+func (v *Verifier) Verify(token string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrMalformed
+	}
+	if len(v.config.Algorithms) > 0 && !slices.Contains(v.config.Algorithms, h.Algorithm) {
+		return nil, ErrAlgorithm
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if err := v.verifySignature(h, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformed
+	}
+	if err := v.verifyClaims(claims, now); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verifySignature 使用与算法匹配的密钥验证签名。令牌带有 kid 时只使用 ID 相同的密钥
+// verifySignature verifies the signature with the keys matching the algorithm. When the token has a kid only keys with
+// that ID are used
+func (v *Verifier) verifySignature(h header, signed string, signature []byte) error {
+	matched := false
+	for _, key := range v.keys {
+		if !key.supports(h.Algorithm) || (h.KeyID != "" && key.ID != "" && key.ID != h.KeyID) {
+			continue
+		}
+		matched = true
+		if key.verify(h.Algorithm, []byte(signed), signature) {
+			return nil
+		}
+	}
+
+	if !matched {
+		if !slices.Contains([]string{HS256, RS256, ES256}, h.Algorithm) {
+			return ErrAlgorithm
+		}
+		return ErrNoMatchingKey
+	}
+	return ErrSignature
+}
+
+// verifyClaims 检查 exp、nbf、iss、aud 和必需的声明
+// verifyClaims checks exp, nbf, iss, aud and the required claims
+func (v *Verifier) verifyClaims(claims Claims, now time.Time) error {
+	if exp, exists := claims["exp"]; exists {
+		expiresAt, ok := numericDate(exp)
+		if !ok {
+			return ErrMalformed
+		}
+		if !now.Before(expiresAt.Add(v.config.Leeway)) {
+			return ErrExpired
+		}
+	}
+
+	if nbf, exists := claims["nbf"]; exists {
+		notBefore, ok := numericDate(nbf)
+		if !ok {
+			return ErrMalformed
+		}
+		if now.Add(v.config.Leeway).Before(notBefore) {
+			return ErrNotYetValid
+		}
+	}
+
+	if v.config.Issuer != "" {
+		if issuer, _ := claims["iss"].(string); issuer != v.config.Issuer {
+			return ErrIssuer
+		}
+	}
+
+	if len(v.config.Audience) > 0 && !audienceAccepted(claims["aud"], v.config.Audience) {
+		return ErrAudience
+	}
+
+	for _, name := range v.config.RequiredClaims {
+		if value, exists := claims[name]; !exists || value == nil {
+			return fmt.Errorf("%w: %s", ErrMissingClaim, name)
+		}
+	}
+
+	return nil
+}
+
+// audienceAccepted 判断 aud 声明（字符串或字符串数组）是否包含可接受的受众
+// audienceAccepted reports whether the aud claim, a string or an array of strings, contains an accepted audience
+func audienceAccepted(aud any, accepted []string) bool {
+	switch v := aud.(type) {
+	case string:
+		return slices.Contains(accepted, v)
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok && slices.Contains(accepted, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// numericDate 将 NumericDate 声明转换为时间
+// numericDate converts a NumericDate claim to a time
+func numericDate(value any) (time.Time, bool) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
+
+// decodeSegment 解码 base64url 编码的 JSON 段，数字保留为 json.Number
+// decodeSegment decodes a base64url encoded JSON segment, keeping numbers as json.Number
+func decodeSegment(segment string, target any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	return decoder.Decode(target)
+}
+
+// supports 判断密钥能否验证该算法的签名，避免算法混淆攻击
+// supports reports whether the key can verify signatures of the algorithm, preventing algorithm confusion
+func (k Key) supports(algorithm string) bool {
+	if k.Algorithm != "" && k.Algorithm != algorithm {
+		return false
+	}
+	switch key := k.key.(type) {
+	case []byte:
+		return algorithm == HS256
+	case *rsa.PublicKey:
+		return algorithm == RS256
+	case *ecdsa.PublicKey:
+		return algorithm == ES256 && key.Curve.Params().Name == "P-256"
+	}
+	return false
+}
+
+// verify 验证签名
+// verify verifies the signature
+func (k Key) verify(algorithm string, signed, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+
+	switch key := k.key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		// ES256 签名为 r 和 s 各 32 字节拼接
+		// An ES256 signature is r and s concatenated, 32 bytes each
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key, digest[:], r, s)
+	}
+	return false
+}
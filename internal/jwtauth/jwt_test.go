@@ -0,0 +1,291 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// sign 创建令牌，signer 为 nil 时签名为空
+// sign creates a token, with an empty signature when signer is nil
+func sign(t *testing.T, h map[string]any, claims map[string]any, signer func(signed []byte) []byte) string {
+	t.Helper()
+
+	encode := func(value any) string {
+		data, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	signed := encode(h) + "." + encode(claims)
+	var signature []byte
+	if signer != nil {
+		signature = signer([]byte(signed))
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func hmacSigner(secret []byte) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+}
+
+func rsaSigner(t *testing.T, key *rsa.PrivateKey) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+}
+
+func ecdsaSigner(t *testing.T, key *ecdsa.PrivateKey) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature
+	}
+}
+
+func TestVerifyAlgorithms(t *testing.T) {
+	secret := []byte("shared-secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPublicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hmacKey := Key{Algorithm: HS256, key: secret}
+	rsaPublic := Key{key: &rsaKey.PublicKey}
+	ecPublic := Key{key: &ecKey.PublicKey}
+	claims := map[string]any{"sub": "alice"}
+
+	tests := []struct {
+		name    string
+		config  Config
+		keys    []Key
+		token   string
+		wantErr error
+	}{
+		{
+			name:  "HS256",
+			keys:  []Key{hmacKey},
+			token: sign(t, map[string]any{"alg": HS256}, claims, hmacSigner(secret)),
+		},
+		{
+			name:  "RS256",
+			keys:  []Key{rsaPublic},
+			token: sign(t, map[string]any{"alg": RS256}, claims, rsaSigner(t, rsaKey)),
+		},
+		{
+			name:  "ES256",
+			keys:  []Key{ecPublic},
+			token: sign(t, map[string]any{"alg": ES256}, claims, ecdsaSigner(t, ecKey)),
+		},
+		{
+			name:    "alg none without signature",
+			keys:    []Key{hmacKey, rsaPublic},
+			token:   sign(t, map[string]any{"alg": "none"}, claims, nil),
+			wantErr: ErrAlgorithm,
+		},
+		{
+			name:    "alg none with allowed algorithms",
+			config:  Config{Algorithms: []string{RS256}},
+			keys:    []Key{rsaPublic},
+			token:   sign(t, map[string]any{"alg": "none"}, claims, nil),
+			wantErr: ErrAlgorithm,
+		},
+		{
+			name:    "HS256 signed with the RSA public key",
+			keys:    []Key{rsaPublic},
+			token:   sign(t, map[string]any{"alg": HS256}, claims, hmacSigner(rsaPublicDER)),
+			wantErr: ErrNoMatchingKey,
+		},
+		{
+			name:    "RS256 header for an HMAC key",
+			keys:    []Key{hmacKey},
+			token:   sign(t, map[string]any{"alg": RS256}, claims, hmacSigner(secret)),
+			wantErr: ErrNoMatchingKey,
+		},
+		{
+			name:    "ES256 header for an RSA key",
+			keys:    []Key{rsaPublic},
+			token:   sign(t, map[string]any{"alg": ES256}, claims, ecdsaSigner(t, ecKey)),
+			wantErr: ErrNoMatchingKey,
+		},
+		{
+			name:    "key restricted to another algorithm",
+			keys:    []Key{{Algorithm: RS256, key: secret}},
+			token:   sign(t, map[string]any{"alg": HS256}, claims, hmacSigner(secret)),
+			wantErr: ErrNoMatchingKey,
+		},
+		{
+			name:    "algorithm not allowed by config",
+			config:  Config{Algorithms: []string{RS256}},
+			keys:    []Key{hmacKey, rsaPublic},
+			token:   sign(t, map[string]any{"alg": HS256}, claims, hmacSigner(secret)),
+			wantErr: ErrAlgorithm,
+		},
+		{
+			name:    "wrong secret",
+			keys:    []Key{hmacKey},
+			token:   sign(t, map[string]any{"alg": HS256}, claims, hmacSigner([]byte("other-secret"))),
+			wantErr: ErrSignature,
+		},
+		{
+			name:    "malformed",
+			keys:    []Key{hmacKey},
+			token:   "not.a-token",
+			wantErr: ErrMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewVerifier(tt.config, tt.keys).Verify(tt.token, time.Now())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.Subject() != "alice" {
+				t.Fatalf("Subject = %q, want alice", got.Subject())
+			}
+		})
+	}
+}
+
+func TestVerifyTimeClaims(t *testing.T) {
+	secret := []byte("shared-secret")
+	keys := []Key{{Algorithm: HS256, key: secret}}
+	now := time.Unix(1_700_000_000, 0)
+	at := func(offset time.Duration) int64 { return now.Add(offset).Unix() }
+
+	tests := []struct {
+		name    string
+		leeway  time.Duration
+		claims  map[string]any
+		wantErr error
+	}{
+		{name: "valid", claims: map[string]any{"exp": at(time.Minute), "nbf": at(-time.Minute)}},
+		{name: "expired", claims: map[string]any{"exp": at(-time.Second)}, wantErr: ErrExpired},
+		{name: "expires now", claims: map[string]any{"exp": at(0)}, wantErr: ErrExpired},
+		{name: "expired within leeway", leeway: time.Minute, claims: map[string]any{"exp": at(-30 * time.Second)}},
+		{name: "expired beyond leeway", leeway: time.Minute, claims: map[string]any{"exp": at(-2 * time.Minute)}, wantErr: ErrExpired},
+		{name: "not valid yet", claims: map[string]any{"nbf": at(time.Second)}, wantErr: ErrNotYetValid},
+		{name: "not valid yet within leeway", leeway: time.Minute, claims: map[string]any{"nbf": at(30 * time.Second)}},
+		{name: "fractional exp", claims: map[string]any{"exp": float64(now.Unix()) + 0.5}},
+		{name: "exp not a number", claims: map[string]any{"exp": "tomorrow"}, wantErr: ErrMalformed},
+		{name: "nbf not a number", claims: map[string]any{"nbf": true}, wantErr: ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := sign(t, map[string]any{"alg": HS256}, tt.claims, hmacSigner(secret))
+			_, err := NewVerifier(Config{Leeway: tt.leeway}, keys).Verify(token, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	oldSecret, newSecret := []byte("old-secret"), []byte("new-secret")
+	keys := []Key{
+		{ID: "2023", Algorithm: HS256, key: oldSecret},
+		{ID: "2024", Algorithm: HS256, key: newSecret},
+	}
+	verifier := NewVerifier(Config{}, keys)
+	claims := map[string]any{"sub": "alice"}
+
+	tests := []struct {
+		name    string
+		kid     string
+		secret  []byte
+		wantErr error
+	}{
+		{name: "new key", kid: "2024", secret: newSecret},
+		{name: "old key still accepted", kid: "2023", secret: oldSecret},
+		{name: "no kid tries every key", secret: oldSecret},
+		{name: "kid of another key", kid: "2024", secret: oldSecret, wantErr: ErrSignature},
+		{name: "unknown kid", kid: "2025", secret: newSecret, wantErr: ErrNoMatchingKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := map[string]any{"alg": HS256}
+			if tt.kid != "" {
+				h["kid"] = tt.kid
+			}
+			_, err := verifier.Verify(sign(t, h, claims, hmacSigner(tt.secret)), time.Now())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// 移除旧密钥后，用旧密钥签名的令牌不再被接受
+	// Once the old key is removed, tokens signed with it are no longer accepted
+	rotated := NewVerifier(Config{}, keys[1:])
+	_, err := rotated.Verify(sign(t, map[string]any{"alg": HS256, "kid": "2023"}, claims, hmacSigner(oldSecret)), time.Now())
+	if !errors.Is(err, ErrNoMatchingKey) {
+		t.Fatalf("Verify error = %v, want %v", err, ErrNoMatchingKey)
+	}
+}
+
+func TestVerifyClaims(t *testing.T) {
+	secret := []byte("shared-secret")
+	keys := []Key{{Algorithm: HS256, key: secret}}
+	config := Config{Issuer: "https://issuer", Audience: []string{"gateway"}, RequiredClaims: []string{"scope"}}
+
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		wantErr error
+	}{
+		{name: "valid", claims: map[string]any{"iss": "https://issuer", "aud": "gateway", "scope": "read"}},
+		{name: "audience array", claims: map[string]any{"iss": "https://issuer", "aud": []string{"other", "gateway"}, "scope": "read"}},
+		{name: "wrong issuer", claims: map[string]any{"iss": "https://other", "aud": "gateway", "scope": "read"}, wantErr: ErrIssuer},
+		{name: "wrong audience", claims: map[string]any{"iss": "https://issuer", "aud": "other", "scope": "read"}, wantErr: ErrAudience},
+		{name: "missing claim", claims: map[string]any{"iss": "https://issuer", "aud": "gateway"}, wantErr: ErrMissingClaim},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := sign(t, map[string]any{"alg": HS256}, tt.claims, hmacSigner(secret))
+			_, err := NewVerifier(config, keys).Verify(token, time.Now())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
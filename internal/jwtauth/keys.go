@@ -0,0 +1,208 @@
+package jwtauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// Key 验证签名使用的密钥
+// Key is a key used to verify signatures
+type Key struct {
+	ID        string // 密钥ID，对应令牌头部的 kid / Key ID, matched against the kid of the token header
+	Algorithm string // 密钥限定的算法，为空时由密钥类型决定 / Algorithm the key is restricted to, from the key type when empty
+	key       any    // []byte、*rsa.PublicKey 或 *ecdsa.PublicKey / []byte, *rsa.PublicKey or *ecdsa.PublicKey
+}
+
+// KeySources 密钥的来源，可以同时使用多个来源
+// KeySources are the sources of keys, several can be used together
+type KeySources struct {
+	Secret        string // HS256 共享密钥 / HS256 shared secret
+	SecretFile    string // 包含 HS256 共享密钥的文件 / File containing the HS256 shared secret
+	PublicKeyFile string // PEM 格式的 RSA 或 EC 公钥或证书 / RSA or EC public key or certificate in PEM format
+	JWKSFile      string // 本地 JWKS 文件 / Local JWKS file
+}
+
+// LoadKeys 从所有配置的来源读取密钥
+// LoadKeys reads the keys from all configured sources
+func LoadKeys(sources KeySources) ([]Key, error) {
+	var keys []Key
+
+	if sources.Secret != "" {
+		keys = append(keys, Key{Algorithm: HS256, key: []byte(sources.Secret)})
+	}
+
+	if sources.SecretFile != "" {
+		data, err := os.ReadFile(sources.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret file: %w", err)
+		}
+		secret := strings.TrimRight(string(data), "\r\n")
+		if secret == "" {
+			return nil, fmt.Errorf("secret file is empty: %s", sources.SecretFile)
+		}
+		keys = append(keys, Key{Algorithm: HS256, key: []byte(secret)})
+	}
+
+	if sources.PublicKeyFile != "" {
+		publicKeys, err := loadPEMFile(sources.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, publicKeys...)
+	}
+
+	if sources.JWKSFile != "" {
+		jwksKeys, err := loadJWKSFile(sources.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, jwksKeys...)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys configured")
+	}
+	return keys, nil
+}
+
+// loadPEMFile 读取 PEM 文件中的所有公钥和证书
+// loadPEMFile reads all public keys and certificates of a PEM file
+func loadPEMFile(path string) ([]Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key file: %w", err)
+	}
+
+	var keys []Key
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var publicKey any
+		switch block.Type {
+		case "PUBLIC KEY":
+			publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			publicKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var certificate *x509.Certificate
+			certificate, err = x509.ParseCertificate(block.Bytes)
+			if err == nil {
+				publicKey = certificate.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s in %s: %w", strings.ToLower(block.Type), path, err)
+		}
+
+		switch publicKey.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			keys = append(keys, Key{key: publicKey})
+		default:
+			return nil, fmt.Errorf("unsupported public key type in %s: %T", path, publicKey)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public key found in %s", path)
+	}
+	return keys, nil
+}
+
+// jwk JWKS 中的单个密钥
+// jwk is a single key of a JWKS
+type jwk struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	K         string `json:"k"`
+}
+
+// loadJWKSFile 读取本地 JWKS 文件，跳过不用于签名的密钥
+// loadJWKSFile reads a local JWKS file, skipping keys not meant for signatures
+func loadJWKSFile(path string) ([]Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS file %s: %w", path, err)
+	}
+
+	var keys []Key
+	for i, entry := range set.Keys {
+		if entry.Use != "" && entry.Use != "sig" {
+			continue
+		}
+		key, err := entry.parse()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key %d in %s: %w", i, path, err)
+		}
+		keys = append(keys, Key{ID: entry.KeyID, Algorithm: entry.Algorithm, key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing key found in %s", path)
+	}
+	return keys, nil
+}
+
+// parse 将 JWK 转换为密钥
+// parse converts the JWK to a key
+func (k jwk) parse() (any, error) {
+	switch k.KeyType {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf("invalid oct key")
+		}
+		return secret, nil
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Curve != "P-256" {
+			return nil, fmt.Errorf("unsupported EC curve: %s", k.Curve)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("invalid EC key")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("EC key is not on curve P-256")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.KeyType)
+	}
+}
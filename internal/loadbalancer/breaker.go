@@ -0,0 +1,217 @@
+package loadbalancer
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 熔断器状态
+// Circuit breaker states
+const (
+	// CircuitClosed 后端正常接收流量，统计滚动窗口内的错误率
+	// CircuitClosed means the backend receives traffic normally while the error rate over the rolling window is tracked
+	CircuitClosed = "closed"
+	// CircuitOpen 后端不接收流量，直到打开时长结束
+	// CircuitOpen means the backend receives no traffic until the open duration has passed
+	CircuitOpen = "open"
+	// CircuitHalfOpen 只允许有限数量的探测请求，全部成功后关闭，任何失败都会重新打开
+	// CircuitHalfOpen allows a limited number of probe requests, closing once all succeed and reopening on any failure
+	CircuitHalfOpen = "half_open"
+)
+
+// 滚动窗口划分的桶数
+// Number of buckets the rolling window is divided into
+const breakerBuckets = 10
+
+// CircuitBreakerConfig 每个后端的熔断器配置
+// CircuitBreakerConfig is the per-backend circuit breaker configuration
+type CircuitBreakerConfig struct {
+	Window           time.Duration // 统计错误率的滚动窗口 / Rolling window the error rate is computed over
+	MinRequests      int           // 窗口内至少多少个请求才会打开 / Requests in the window before the circuit may open
+	ErrorRate        float64       // 打开熔断器的错误率百分比 / Error rate percentage opening the circuit
+	OpenDuration     time.Duration // 打开后多久进入半开状态 / Time the circuit stays open before half-opening
+	HalfOpenRequests int           // 半开状态允许的探测请求数 / Probe requests allowed while half-open
+}
+
+// CircuitBreakerTarget 可以为每个后端启用熔断器的负载均衡器
+// CircuitBreakerTarget is a load balancer that can enable a circuit breaker for each backend
+type CircuitBreakerTarget interface {
+	// SetCircuitBreaker 为所有后端启用熔断器，config 为 nil 时停用。启用后失败不再按连续失败次数标记后端不健康
+	// SetCircuitBreaker enables a circuit breaker for every backend, disabling it when config is nil. Once enabled,
+	// failures no longer mark backends unhealthy by consecutive count
+	SetCircuitBreaker(config *CircuitBreakerConfig)
+}
+
+// breakerBucket 滚动窗口中一个时间段的请求统计
+// breakerBucket counts the requests of one time slice of the rolling window
+type breakerBucket struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+// circuitBreaker 单个后端的熔断器，调用方需持有后端的锁
+// circuitBreaker is the circuit breaker of a single backend, the caller must hold the backend's lock
+type circuitBreaker struct {
+	config    CircuitBreakerConfig
+	state     string
+	openedAt  time.Time
+	buckets   [breakerBuckets]breakerBucket
+	probes    int // 半开状态下正在进行的探测请求 / Probe requests in flight while half-open
+	successes int // 半开状态下成功的探测请求 / Successful probe requests while half-open
+}
+
+// newCircuitBreaker 创建处于关闭状态的熔断器
+// newCircuitBreaker creates a circuit breaker in the closed state
+func newCircuitBreaker(config CircuitBreakerConfig) *circuitBreaker {
+	return &circuitBreaker{config: config, state: CircuitClosed}
+}
+
+// allows 判断后端现在能否接收请求，nil 熔断器总是允许。只用于预先筛选候选后端，半开状态的名额由 acquire 预留
+// allows reports whether the backend may receive a request now, a nil breaker always allows. It only pre-filters
+// candidates, half-open slots are reserved by acquire
+func (b *circuitBreaker) allows(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	switch b.state {
+	case CircuitOpen:
+		return now.Sub(b.openedAt) >= b.config.OpenDuration && b.config.HalfOpenRequests > 0
+	case CircuitHalfOpen:
+		return b.probes < b.config.HalfOpenRequests
+	default:
+		return true
+	}
+}
+
+// acquire 为发往后端的请求预留名额，不允许时返回 false。打开时长结束后进入半开状态，
+// 半开状态下最多预留 HalfOpenRequests 个探测请求
+// acquire reserves a slot for a request sent to the backend and returns false when it is not allowed. The circuit
+// half-opens once the open duration has passed, and at most HalfOpenRequests probes are reserved while half-open
+func (b *circuitBreaker) acquire(backend string, now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.openedAt) < b.config.OpenDuration || b.config.HalfOpenRequests <= 0 {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probes = 1
+		b.successes = 0
+		logger.Info("Circuit half-open, probing backend", zap.String("backend", backend))
+		return true
+	case CircuitHalfOpen:
+		if b.probes >= b.config.HalfOpenRequests {
+			return false
+		}
+		b.probes++
+		return true
+	default:
+		return true
+	}
+}
+
+// recordSuccess 记录一次成功，半开状态下所有探测请求都成功后关闭熔断器
+// recordSuccess records a success, closing the circuit once all probes succeeded while half-open
+func (b *circuitBreaker) recordSuccess(backend string, now time.Time) {
+	if b == nil {
+		return
+	}
+	switch b.state {
+	case CircuitClosed:
+		b.bucket(now).requests++
+	case CircuitHalfOpen:
+		b.probes = max(b.probes-1, 0)
+		b.successes++
+		if b.successes >= b.config.HalfOpenRequests {
+			b.state = CircuitClosed
+			b.buckets = [breakerBuckets]breakerBucket{}
+			logger.Info("Circuit closed, backend recovered", zap.String("backend", backend))
+		}
+	}
+}
+
+// release 释放预留的名额但不记录结果，用于请求未发送到后端的情况
+// release frees a reserved slot without recording an outcome, for requests that never reached the backend
+func (b *circuitBreaker) release() {
+	if b == nil {
+		return
+	}
+	if b.state == CircuitHalfOpen {
+		b.probes = max(b.probes-1, 0)
+	}
+}
+
+// recordFailure 记录一次失败，错误率达到阈值或半开状态下的探测失败时打开熔断器
+// recordFailure records a failure, opening the circuit when the error rate reaches the threshold or a probe fails
+// while half-open
+func (b *circuitBreaker) recordFailure(backend string, now time.Time) {
+	if b == nil {
+		return
+	}
+	switch b.state {
+	case CircuitClosed:
+		bucket := b.bucket(now)
+		bucket.requests++
+		bucket.failures++
+
+		requests, failures := b.counts(now)
+		errorRate := float64(failures) * 100 / float64(requests)
+		if requests >= b.config.MinRequests && errorRate >= b.config.ErrorRate {
+			b.open(now)
+			logger.Warn("Circuit opened, error rate over threshold",
+				zap.String("backend", backend),
+				zap.Int("requests", requests),
+				zap.Int("failures", failures),
+				zap.Float64("errorRate", errorRate))
+		}
+	case CircuitHalfOpen:
+		b.open(now)
+		logger.Warn("Circuit reopened, probe request failed", zap.String("backend", backend))
+	}
+}
+
+// open 打开熔断器
+// open opens the circuit
+func (b *circuitBreaker) open(now time.Time) {
+	b.state = CircuitOpen
+	b.openedAt = now
+	b.probes = 0
+	b.successes = 0
+}
+
+// bucket 返回当前时间所在的桶，过期的桶会被清空后复用
+// bucket returns the bucket of the current time, reusing expired buckets after clearing them
+func (b *circuitBreaker) bucket(now time.Time) *breakerBucket {
+	width := max(b.config.Window/breakerBuckets, time.Millisecond)
+	start := now.Truncate(width)
+	bucket := &b.buckets[(start.UnixNano()/int64(width))%breakerBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = breakerBucket{start: start}
+	}
+	return bucket
+}
+
+// counts 返回滚动窗口内的请求数和失败数
+// counts returns the requests and failures within the rolling window
+func (b *circuitBreaker) counts(now time.Time) (requests, failures int) {
+	for _, bucket := range b.buckets {
+		if now.Sub(bucket.start) < b.config.Window {
+			requests += bucket.requests
+			failures += bucket.failures
+		}
+	}
+	return requests, failures
+}
+
+// circuitState 返回熔断器状态，没有熔断器时返回空字符串
+// circuitState returns the circuit state, an empty string without a breaker
+func (b *circuitBreaker) circuitState() string {
+	if b == nil {
+		return ""
+	}
+	return b.state
+}
@@ -0,0 +1,236 @@
+package loadbalancer
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	config := CircuitBreakerConfig{
+		Window:           10 * time.Second,
+		MinRequests:      2,
+		ErrorRate:        50,
+		OpenDuration:     time.Second,
+		HalfOpenRequests: 2,
+	}
+	start := time.Unix(1000, 0)
+
+	// 每一步在 start 之后的 at 时刻执行一个操作，并检查返回值和之后的状态
+	// Every step runs one operation at start+at and checks its result and the state afterwards
+	type step struct {
+		op      string
+		at      time.Duration
+		allowed bool // 仅用于 acquire / Only used by acquire
+		state   string
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "stays closed below min requests",
+			steps: []step{
+				{op: "failure", state: CircuitClosed},
+				{op: "acquire", allowed: true, state: CircuitClosed},
+			},
+		},
+		{
+			name: "stays closed below error rate",
+			steps: []step{
+				{op: "success", state: CircuitClosed},
+				{op: "success", state: CircuitClosed},
+				{op: "failure", state: CircuitClosed},
+			},
+		},
+		{
+			name: "opens at error rate and refuses until open duration",
+			steps: []step{
+				{op: "success", state: CircuitClosed},
+				{op: "failure", state: CircuitOpen},
+				{op: "acquire", at: 500 * time.Millisecond, allowed: false, state: CircuitOpen},
+			},
+		},
+		{
+			name: "ignores failures outside the window",
+			steps: []step{
+				{op: "failure", state: CircuitClosed},
+				{op: "failure", at: 11 * time.Second, state: CircuitClosed},
+			},
+		},
+		{
+			name: "half-opens and closes after all probes succeed",
+			steps: []step{
+				{op: "failure", state: CircuitClosed},
+				{op: "failure", state: CircuitOpen},
+				{op: "acquire", at: time.Second, allowed: true, state: CircuitHalfOpen},
+				{op: "acquire", at: time.Second, allowed: true, state: CircuitHalfOpen},
+				{op: "acquire", at: time.Second, allowed: false, state: CircuitHalfOpen},
+				{op: "success", at: time.Second, state: CircuitHalfOpen},
+				{op: "success", at: time.Second, state: CircuitClosed},
+				{op: "acquire", at: time.Second, allowed: true, state: CircuitClosed},
+			},
+		},
+		{
+			name: "reopens when a probe fails",
+			steps: []step{
+				{op: "failure", state: CircuitClosed},
+				{op: "failure", state: CircuitOpen},
+				{op: "acquire", at: time.Second, allowed: true, state: CircuitHalfOpen},
+				{op: "failure", at: time.Second, state: CircuitOpen},
+				{op: "acquire", at: 1500 * time.Millisecond, allowed: false, state: CircuitOpen},
+				{op: "acquire", at: 2 * time.Second, allowed: true, state: CircuitHalfOpen},
+			},
+		},
+		{
+			name: "releases probe slots when probes complete",
+			steps: []step{
+				{op: "failure", state: CircuitClosed},
+				{op: "failure", state: CircuitOpen},
+				{op: "acquire", at: time.Second, allowed: true, state: CircuitHalfOpen},
+				{op: "acquire", at: time.Second, allowed: true, state: CircuitHalfOpen},
+				{op: "success", at: time.Second, state: CircuitHalfOpen},
+				{op: "acquire", at: time.Second, allowed: true, state: CircuitHalfOpen},
+				{op: "acquire", at: time.Second, allowed: false, state: CircuitHalfOpen},
+			},
+		},
+		{
+			name: "release frees a probe slot without closing",
+			steps: []step{
+				{op: "failure", state: CircuitClosed},
+				{op: "failure", state: CircuitOpen},
+				{op: "acquire", at: time.Second, allowed: true, state: CircuitHalfOpen},
+				{op: "acquire", at: time.Second, allowed: true, state: CircuitHalfOpen},
+				{op: "acquire", at: time.Second, allowed: false, state: CircuitHalfOpen},
+				{op: "release", at: time.Second, state: CircuitHalfOpen},
+				{op: "acquire", at: time.Second, allowed: true, state: CircuitHalfOpen},
+				{op: "success", at: time.Second, state: CircuitHalfOpen},
+				{op: "success", at: time.Second, state: CircuitClosed},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := newCircuitBreaker(config)
+			for i, step := range tt.steps {
+				now := start.Add(step.at)
+				switch step.op {
+				case "acquire":
+					if allowed := breaker.acquire("backend", now); allowed != step.allowed {
+						t.Fatalf("step %d: acquire = %v, want %v", i, allowed, step.allowed)
+					}
+				case "success":
+					breaker.recordSuccess("backend", now)
+				case "failure":
+					breaker.recordFailure("backend", now)
+				case "release":
+					breaker.release()
+				}
+				if breaker.state != step.state {
+					t.Fatalf("step %d: state = %s, want %s", i, breaker.state, step.state)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerNilAllows(t *testing.T) {
+	var breaker *circuitBreaker
+	if !breaker.allows(time.Now()) || !breaker.acquire("backend", time.Now()) {
+		t.Fatal("nil breaker must allow every request")
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbeLimit(t *testing.T) {
+	const halfOpenRequests = 2
+
+	lb := NewRoundRobinLoadBalancer([]string{"http://backend"})
+	lb.SetCircuitBreaker(&CircuitBreakerConfig{
+		Window:           time.Minute,
+		MinRequests:      1,
+		ErrorRate:        50,
+		OpenDuration:     10 * time.Millisecond,
+		HalfOpenRequests: halfOpenRequests,
+	})
+
+	if backend := lb.NextBackend(); backend == "" {
+		t.Fatal("closed circuit refused the request")
+	}
+	lb.ReportFailure("http://backend")
+	if backend := lb.NextBackend(); backend != "" {
+		t.Fatalf("open circuit returned backend %s", backend)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	var acquired atomic.Int32
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if lb.NextBackend() != "" {
+				acquired.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := acquired.Load(); got != halfOpenRequests {
+		t.Fatalf("acquired %d probes, want %d", got, halfOpenRequests)
+	}
+}
+
+func TestCircuitBreakerRefusedBackendIsSkipped(t *testing.T) {
+	lb := NewRoundRobinLoadBalancer([]string{"http://a", "http://b"})
+	lb.SetCircuitBreaker(&CircuitBreakerConfig{
+		Window:           time.Minute,
+		MinRequests:      1,
+		ErrorRate:        50,
+		OpenDuration:     10 * time.Millisecond,
+		HalfOpenRequests: 1,
+	})
+
+	// 打开 a 的熔断器，等待进入半开状态后占用唯一的探测名额
+	// Open the circuit of a, then take its only probe slot once it may half-open
+	lb.withBackend("http://a", func(status *BackendStatus) {
+		status.breaker.recordFailure("http://a", time.Now())
+	})
+	time.Sleep(20 * time.Millisecond)
+	if lb.acquire("http://a") == "" {
+		t.Fatal("half-open circuit refused the first probe")
+	}
+
+	for range 10 {
+		if backend := lb.NextBackend(); backend != "http://b" {
+			t.Fatalf("NextBackend = %q, want http://b", backend)
+		}
+	}
+}
+
+func TestCircuitBreakerFailOpenKeepsCircuitsOpen(t *testing.T) {
+	lb := NewRoundRobinLoadBalancer([]string{"http://a", "http://b"})
+	lb.SetPassiveHealth(PassiveHealthConfig{MaxFails: 1, FailTimeout: time.Minute, FailOpen: true})
+	lb.SetCircuitBreaker(&CircuitBreakerConfig{
+		Window:           time.Minute,
+		MinRequests:      1,
+		ErrorRate:        50,
+		OpenDuration:     time.Minute,
+		HalfOpenRequests: 1,
+	})
+
+	lb.ReportFailure("http://a")
+	for range 10 {
+		if backend := lb.NextBackend(); backend != "http://b" {
+			t.Fatalf("NextBackend = %q, want http://b", backend)
+		}
+	}
+
+	lb.ReportFailure("http://b")
+	if backend := lb.NextBackend(); backend != "" {
+		t.Fatalf("fail open returned backend %s while every circuit is open", backend)
+	}
+}
@@ -0,0 +1,163 @@
+package loadbalancer
+
+import (
+	"slices"
+	"time"
+)
+
+// GroupLoadBalancer 由多个命名分组组成的负载均衡器，每个分组有自己的负载均衡器。
+// 按后端的操作交给后端所属的分组，选择后端时通过 Group 在某个分组中选择
+// GroupLoadBalancer is a load balancer made of named groups, each with its own load balancer. Per-backend operations
+// go to the group owning the backend, backends are picked within a group obtained through Group
+type GroupLoadBalancer struct {
+	names  []string
+	groups []LoadBalancer
+}
+
+// NewGroupLoadBalancer 创建分组负载均衡器，names 和 groups 一一对应
+// NewGroupLoadBalancer creates a group load balancer, names and groups correspond one to one
+func NewGroupLoadBalancer(names []string, groups []LoadBalancer) *GroupLoadBalancer {
+	return &GroupLoadBalancer{names: names, groups: groups}
+}
+
+// Group 返回给定名称的分组的负载均衡器，不存在时返回 nil
+// Group returns the load balancer of the group with the given name, nil when it does not exist
+func (g *GroupLoadBalancer) Group(name string) LoadBalancer {
+	if i := slices.Index(g.names, name); i >= 0 {
+		return g.groups[i]
+	}
+	return nil
+}
+
+// Names 返回所有分组的名称
+// Names returns the names of all groups
+func (g *GroupLoadBalancer) Names() []string {
+	return g.names
+}
+
+// owner 返回后端所属分组的负载均衡器，不存在时返回 nil
+// owner returns the load balancer of the group owning the backend, nil when it does not exist
+func (g *GroupLoadBalancer) owner(backend string) LoadBalancer {
+	for _, group := range g.groups {
+		if slices.Contains(group.GetBackends(), backend) {
+			return group
+		}
+	}
+	return nil
+}
+
+// NextBackend 从第一个有可用后端的分组中选择后端
+// NextBackend picks a backend from the first group with an available backend
+func (g *GroupLoadBalancer) NextBackend() string {
+	return g.NextBackendExcluding(nil)
+}
+
+// NextBackendExcluding 从第一个有可用后端的分组中选择不在 exclude 中的后端
+// NextBackendExcluding picks a backend not in exclude from the first group with an available backend
+func (g *GroupLoadBalancer) NextBackendExcluding(exclude []string) string {
+	for _, group := range g.groups {
+		if backend := group.NextBackendExcluding(exclude); backend != "" {
+			return backend
+		}
+	}
+	return ""
+}
+
+// ReportSuccess 报告后端服务请求成功
+// ReportSuccess reports a successful request to the backend
+func (g *GroupLoadBalancer) ReportSuccess(backend string, responseTime time.Duration) {
+	if group := g.owner(backend); group != nil {
+		group.ReportSuccess(backend, responseTime)
+	}
+}
+
+// ReportFailure 报告后端服务请求失败
+// ReportFailure reports a failed request to the backend
+func (g *GroupLoadBalancer) ReportFailure(backend string) {
+	if group := g.owner(backend); group != nil {
+		group.ReportFailure(backend)
+	}
+}
+
+// Release 释放选择后端时记录的请求
+// Release releases the request recorded when the backend was picked
+func (g *GroupLoadBalancer) Release(backend string) {
+	if group := g.owner(backend); group != nil {
+		group.Release(backend)
+	}
+}
+
+// GetBackends 获取所有分组的后端服务
+// GetBackends returns the backends of all groups
+func (g *GroupLoadBalancer) GetBackends() []string {
+	var backends []string
+	for _, group := range g.groups {
+		backends = append(backends, group.GetBackends()...)
+	}
+	return backends
+}
+
+// GetHealthyBackends 获取所有分组中健康且启用的后端服务
+// GetHealthyBackends returns the healthy and enabled backends of all groups
+func (g *GroupLoadBalancer) GetHealthyBackends() []string {
+	var backends []string
+	for _, group := range g.groups {
+		backends = append(backends, group.GetHealthyBackends()...)
+	}
+	return backends
+}
+
+// GetBackendStatuses 获取所有分组后端服务状态的副本
+// GetBackendStatuses returns copies of the backend statuses of all groups
+func (g *GroupLoadBalancer) GetBackendStatuses() []BackendStatus {
+	var statuses []BackendStatus
+	for _, group := range g.groups {
+		statuses = append(statuses, group.GetBackendStatuses()...)
+	}
+	return statuses
+}
+
+// SetBackendAdminState 设置后端的管理状态；后端不存在时返回 false
+// SetBackendAdminState sets the administrative state of a backend; it returns false when the backend does not exist
+func (g *GroupLoadBalancer) SetBackendAdminState(backend, state string) bool {
+	group := g.owner(backend)
+	return group != nil && group.SetBackendAdminState(backend, state)
+}
+
+// SetActiveHealthCheck 对所有分组启用或停用主动健康检查
+// SetActiveHealthCheck enables or disables active health checks for all groups
+func (g *GroupLoadBalancer) SetActiveHealthCheck(enabled bool) {
+	for _, group := range g.groups {
+		if target, ok := group.(HealthTarget); ok {
+			target.SetActiveHealthCheck(enabled)
+		}
+	}
+}
+
+// SetPassiveHealth 设置所有分组的被动健康检查阈值
+// SetPassiveHealth sets the passive health thresholds of all groups
+func (g *GroupLoadBalancer) SetPassiveHealth(config PassiveHealthConfig) {
+	for _, group := range g.groups {
+		if target, ok := group.(PassiveHealthTarget); ok {
+			target.SetPassiveHealth(config)
+		}
+	}
+}
+
+// SetCircuitBreaker 对所有分组启用或停用熔断器
+// SetCircuitBreaker enables or disables circuit breakers for all groups
+func (g *GroupLoadBalancer) SetCircuitBreaker(config *CircuitBreakerConfig) {
+	for _, group := range g.groups {
+		if target, ok := group.(CircuitBreakerTarget); ok {
+			target.SetCircuitBreaker(config)
+		}
+	}
+}
+
+// SetBackendHealth 设置后端服务的健康状态
+// SetBackendHealth sets the health of a backend
+func (g *GroupLoadBalancer) SetBackendHealth(backend string, healthy bool) {
+	if target, ok := g.owner(backend).(HealthTarget); ok {
+		target.SetBackendHealth(backend, healthy)
+	}
+}
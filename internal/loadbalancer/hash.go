@@ -0,0 +1,95 @@
+package loadbalancer
+
+import (
+	"hash/crc32"
+	"slices"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+// 每个后端在哈希环上的虚拟节点数
+// Number of virtual nodes per backend on the hash ring
+const virtualNodesPerBackend = 100
+
+// ringNode 哈希环上的一个虚拟节点
+// ringNode is a virtual node on the hash ring
+type ringNode struct {
+	hash    uint32
+	backend string
+}
+
+// ConsistentHashLoadBalancer 按请求键（客户端IP、头部或Cookie）一致性哈希选择后端，
+// 后端不健康时顺延到环上的下一个健康后端
+// ConsistentHashLoadBalancer picks a backend by consistent hashing of a request key (client IP, header or cookie),
+// moving on to the next healthy backend on the ring when the owner is unhealthy
+type ConsistentHashLoadBalancer struct {
+	*backendPool
+	ring    []ringNode // 按哈希值排序的虚拟节点 / Virtual nodes sorted by hash
+	current uint32     // 没有请求键时的轮询索引 / Round-robin index when there is no request key
+}
+
+// NewConsistentHashLoadBalancer 创建一个新的一致性哈希负载均衡器
+// NewConsistentHashLoadBalancer creates a new consistent-hash load balancer
+func NewConsistentHashLoadBalancer(backends []string) *ConsistentHashLoadBalancer {
+	lb := &ConsistentHashLoadBalancer{
+		backendPool: newBackendPool(backends),
+		ring:        make([]ringNode, 0, len(backends)*virtualNodesPerBackend),
+	}
+
+	for _, backend := range backends {
+		for i := 0; i < virtualNodesPerBackend; i++ {
+			lb.ring = append(lb.ring, ringNode{
+				hash:    crc32.ChecksumIEEE([]byte(backend + "#" + strconv.Itoa(i))),
+				backend: backend,
+			})
+		}
+	}
+	sort.Slice(lb.ring, func(i, j int) bool {
+		return lb.ring[i].hash < lb.ring[j].hash
+	})
+
+	return lb
+}
+
+// NextBackend 没有请求键时按轮询返回后端
+// NextBackend returns backends round-robin when there is no request key
+func (lb *ConsistentHashLoadBalancer) NextBackend() string {
+	return lb.NextBackendExcluding(nil)
+}
+
+// NextBackendExcluding 返回下一个不在 exclude 中的后端服务
+// NextBackendExcluding returns the next backend not in exclude
+func (lb *ConsistentHashLoadBalancer) NextBackendExcluding(exclude []string) string {
+	return lb.pick(exclude, func(candidates []string) string {
+		current := atomic.AddUint32(&lb.current, 1) % uint32(len(candidates))
+		return candidates[current]
+	})
+}
+
+// NextBackendForKey 返回给定键在哈希环上对应的健康后端，exclude 中的后端会被跳过
+// NextBackendForKey returns the healthy backend that owns the given key on the hash ring, skipping backends in exclude
+func (lb *ConsistentHashLoadBalancer) NextBackendForKey(key string, exclude []string) string {
+	if key == "" {
+		return lb.NextBackendExcluding(exclude)
+	}
+
+	if len(lb.ring) == 0 {
+		return ""
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(lb.ring), func(i int) bool {
+		return lb.ring[i].hash >= hash
+	})
+
+	return lb.pick(exclude, func(candidates []string) string {
+		for n := 0; n < len(lb.ring); n++ {
+			node := lb.ring[(start+n)%len(lb.ring)]
+			if slices.Contains(candidates, node.backend) {
+				return node.backend
+			}
+		}
+		return ""
+	})
+}
@@ -0,0 +1,232 @@
+package loadbalancer
+
+import (
+	"crypto/tls"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+// HealthTarget 可以由主动健康检查设置后端健康状态的负载均衡器
+// HealthTarget is a load balancer whose backend health can be set by an active health checker
+type HealthTarget interface {
+	// GetBackends 获取所有后端服务
+	// GetBackends returns all backends
+	GetBackends() []string
+
+	// SetActiveHealthCheck 启用后，后端只能由健康检查恢复，不再在失败超时后盲目恢复
+	// SetActiveHealthCheck makes backends recover only through health checks instead of blindly after the failure timeout
+	SetActiveHealthCheck(enabled bool)
+
+	// SetBackendHealth 设置后端服务的健康状态
+	// SetBackendHealth sets the health of a backend
+	SetBackendHealth(backend string, healthy bool)
+}
+
+// HealthCheckConfig 主动健康检查配置
+// HealthCheckConfig is the active health check configuration
+type HealthCheckConfig struct {
+	Path           string        // 探测路径 / Probe path
+	Method         string        // 探测方法 / Probe method
+	ExpectedStatus []int         // 期望的状态码，为空时接受 2xx / Expected status codes, any 2xx when empty
+	Interval       time.Duration // 探测间隔 / Probe interval
+	Timeout        time.Duration // 探测超时 / Probe timeout
+	Rise           int           // 连续成功多少次后标记为健康 / Consecutive successes before marking healthy
+	Fall           int           // 连续失败多少次后标记为不健康 / Consecutive failures before marking unhealthy
+
+	// TLSConfig 返回探测 HTTPS 后端使用的 TLS 配置，返回 nil 时使用默认设置
+	// TLSConfig returns the TLS config used to probe an HTTPS backend, the default settings are used when it returns nil
+	TLSConfig func(backend string) *tls.Config
+}
+
+// probeState 记录单个后端的连续探测结果
+// probeState tracks consecutive probe results of a single backend
+type probeState struct {
+	successes int
+	failures  int
+}
+
+// HealthChecker 定期探测后端并更新负载均衡器中的健康状态
+// HealthChecker periodically probes backends and updates their health in the load balancer
+type HealthChecker struct {
+	target   HealthTarget
+	config   HealthCheckConfig
+	client   *fasthttp.Client
+	states   map[string]*probeState
+	stopChan chan struct{}
+	stopOnce sync.Once
+
+	// 配置了 TLS 的后端的客户端，TLS 配置重新加载后重新创建
+	// Clients of backends with a TLS config, recreated when the TLS config is reloaded
+	tlsClients      map[string]tlsClient
+	tlsClientsMutex sync.Mutex
+}
+
+// tlsClient 使用特定 TLS 配置的探测客户端
+// tlsClient is a probe client using a specific TLS config
+type tlsClient struct {
+	config *tls.Config
+	client *fasthttp.Client
+}
+
+// NewHealthChecker 创建一个新的主动健康检查器
+// NewHealthChecker creates a new active health checker
+func NewHealthChecker(target HealthTarget, config HealthCheckConfig) *HealthChecker {
+	states := make(map[string]*probeState)
+	for _, backend := range target.GetBackends() {
+		states[backend] = &probeState{}
+	}
+
+	return &HealthChecker{
+		target: target,
+		config: config,
+		client: &fasthttp.Client{
+			ReadTimeout:  config.Timeout,
+			WriteTimeout: config.Timeout,
+		},
+		states:     states,
+		stopChan:   make(chan struct{}),
+		tlsClients: make(map[string]tlsClient),
+	}
+}
+
+// Start 启动后台探测
+// Start starts probing in the background
+func (hc *HealthChecker) Start() {
+	hc.target.SetActiveHealthCheck(true)
+
+	logger.Info("Starting active health checks",
+		zap.Strings("backends", hc.target.GetBackends()),
+		zap.String("path", hc.config.Path),
+		zap.Duration("interval", hc.config.Interval))
+
+	go func() {
+		ticker := time.NewTicker(hc.config.Interval)
+		defer ticker.Stop()
+
+		hc.probeAll()
+		for {
+			select {
+			case <-ticker.C:
+				hc.probeAll()
+			case <-hc.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台探测
+// Stop stops probing
+func (hc *HealthChecker) Stop() {
+	hc.stopOnce.Do(func() {
+		close(hc.stopChan)
+	})
+}
+
+// probeAll 并发探测所有后端
+// probeAll probes all backends concurrently
+func (hc *HealthChecker) probeAll() {
+	var wg sync.WaitGroup
+	backends := hc.target.GetBackends()
+	results := make([]bool, len(backends))
+
+	for i, backend := range backends {
+		wg.Add(1)
+		go func(i int, backend string) {
+			defer wg.Done()
+			results[i] = hc.probe(backend)
+		}(i, backend)
+	}
+	wg.Wait()
+
+	for i, backend := range backends {
+		hc.record(backend, results[i])
+	}
+}
+
+// probe 对单个后端发送一次探测请求
+// probe sends a single probe request to a backend
+func (hc *HealthChecker) probe(backend string) bool {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(strings.TrimRight(backend, "/") + hc.config.Path)
+	req.Header.SetMethod(hc.config.Method)
+
+	if err := hc.clientFor(backend).DoTimeout(req, resp, hc.config.Timeout); err != nil {
+		logger.Debug("Health check probe failed", zap.String("backend", backend), zap.Error(err))
+		return false
+	}
+
+	statusCode := resp.StatusCode()
+	if len(hc.config.ExpectedStatus) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+	if !slices.Contains(hc.config.ExpectedStatus, statusCode) {
+		logger.Debug("Health check probe returned unexpected status",
+			zap.String("backend", backend),
+			zap.Int("statusCode", statusCode),
+			zap.Ints("expectedStatus", hc.config.ExpectedStatus))
+		return false
+	}
+	return true
+}
+
+// clientFor 返回探测后端使用的客户端，配置了 TLS 的后端使用单独的客户端
+// clientFor returns the client probing the backend, backends with a TLS config get their own client
+func (hc *HealthChecker) clientFor(backend string) *fasthttp.Client {
+	if hc.config.TLSConfig == nil {
+		return hc.client
+	}
+	tlsConfig := hc.config.TLSConfig(backend)
+	if tlsConfig == nil {
+		return hc.client
+	}
+
+	hc.tlsClientsMutex.Lock()
+	defer hc.tlsClientsMutex.Unlock()
+	if entry, exists := hc.tlsClients[backend]; exists && entry.config == tlsConfig {
+		return entry.client
+	}
+	client := &fasthttp.Client{
+		ReadTimeout:  hc.config.Timeout,
+		WriteTimeout: hc.config.Timeout,
+		TLSConfig:    tlsConfig,
+	}
+	hc.tlsClients[backend] = tlsClient{config: tlsConfig, client: client}
+	return client
+}
+
+// record 根据 rise/fall 阈值更新后端健康状态，达到阈值后每次探测都会确认状态，
+// 以便纠正被动失败检测造成的不健康标记
+// record updates backend health according to the rise/fall thresholds. Once a threshold is reached every
+// probe confirms the state, so backends marked unhealthy by passive failure detection are corrected too
+func (hc *HealthChecker) record(backend string, ok bool) {
+	state, exists := hc.states[backend]
+	if !exists {
+		state = &probeState{}
+		hc.states[backend] = state
+	}
+
+	if ok {
+		state.successes++
+		state.failures = 0
+		if state.successes >= hc.config.Rise {
+			hc.target.SetBackendHealth(backend, true)
+		}
+		return
+	}
+
+	state.failures++
+	state.successes = 0
+	if state.failures >= hc.config.Fall {
+		hc.target.SetBackendHealth(backend, false)
+	}
+}
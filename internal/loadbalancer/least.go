@@ -0,0 +1,150 @@
+package loadbalancer
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// LeastConnectionsLoadBalancer 选择正在处理请求数最少的后端
+// LeastConnectionsLoadBalancer picks the backend with the fewest in-flight requests
+type LeastConnectionsLoadBalancer struct {
+	*backendPool
+	offset uint32 // 平局时的起始偏移，避免总是选择第一个 / Start offset for ties so the first backend is not always chosen
+}
+
+// NewLeastConnectionsLoadBalancer 创建一个新的最少连接负载均衡器
+// NewLeastConnectionsLoadBalancer creates a new least-connections load balancer
+func NewLeastConnectionsLoadBalancer(backends []string) *LeastConnectionsLoadBalancer {
+	return &LeastConnectionsLoadBalancer{
+		backendPool: newBackendPool(backends),
+	}
+}
+
+// NextBackend 返回下一个要使用的后端服务
+// NextBackend returns the next backend to use
+func (lb *LeastConnectionsLoadBalancer) NextBackend() string {
+	return lb.NextBackendExcluding(nil)
+}
+
+// NextBackendExcluding 返回下一个不在 exclude 中的后端服务
+// NextBackendExcluding returns the next backend not in exclude
+func (lb *LeastConnectionsLoadBalancer) NextBackendExcluding(exclude []string) string {
+	return lb.pick(exclude, func(candidates []string) string {
+		statuses := lb.snapshot(candidates)
+		if len(statuses) == 0 {
+			return ""
+		}
+
+		start := int(atomic.AddUint32(&lb.offset, 1) % uint32(len(statuses)))
+		best := start
+		for n := 1; n < len(statuses); n++ {
+			i := (start + n) % len(statuses)
+			if statuses[i].ActiveRequests < statuses[best].ActiveRequests {
+				best = i
+			}
+		}
+		return statuses[best].URL
+	})
+}
+
+// LeastResponseTimeLoadBalancer 选择最近平均响应时间最短的后端，没有响应时间样本的后端优先
+// LeastResponseTimeLoadBalancer picks the backend with the lowest recent average response time, backends without samples first
+type LeastResponseTimeLoadBalancer struct {
+	*backendPool
+	offset uint32 // 平局时的起始偏移 / Start offset for ties
+}
+
+// NewLeastResponseTimeLoadBalancer 创建一个新的最短响应时间负载均衡器
+// NewLeastResponseTimeLoadBalancer creates a new least-response-time load balancer
+func NewLeastResponseTimeLoadBalancer(backends []string) *LeastResponseTimeLoadBalancer {
+	return &LeastResponseTimeLoadBalancer{
+		backendPool: newBackendPool(backends),
+	}
+}
+
+// NextBackend 返回下一个要使用的后端服务
+// NextBackend returns the next backend to use
+func (lb *LeastResponseTimeLoadBalancer) NextBackend() string {
+	return lb.NextBackendExcluding(nil)
+}
+
+// NextBackendExcluding 返回下一个不在 exclude 中的后端服务
+// NextBackendExcluding returns the next backend not in exclude
+func (lb *LeastResponseTimeLoadBalancer) NextBackendExcluding(exclude []string) string {
+	return lb.pick(exclude, func(candidates []string) string {
+		statuses := lb.snapshot(candidates)
+		if len(statuses) == 0 {
+			return ""
+		}
+
+		start := int(atomic.AddUint32(&lb.offset, 1) % uint32(len(statuses)))
+		best := start
+		for n := 1; n < len(statuses); n++ {
+			i := (start + n) % len(statuses)
+			if fasterThan(&statuses[i], &statuses[best]) {
+				best = i
+			}
+		}
+		return statuses[best].URL
+	})
+}
+
+// fasterThan 比较两个后端，先比较平均响应时间，再比较正在处理的请求数
+// fasterThan compares two backends by average response time, then by in-flight requests
+func fasterThan(a, b *BackendStatus) bool {
+	aTime, bTime := a.averageResponseTime(), b.averageResponseTime()
+	if aTime != bTime {
+		return aTime < bTime
+	}
+	return a.ActiveRequests < b.ActiveRequests
+}
+
+// RandomTwoChoicesLoadBalancer 随机选择两个后端，并使用负载较低的一个（power of two choices）
+// RandomTwoChoicesLoadBalancer picks two random backends and uses the less loaded one (power of two choices)
+type RandomTwoChoicesLoadBalancer struct {
+	*backendPool
+}
+
+// NewRandomTwoChoicesLoadBalancer 创建一个新的随机双选负载均衡器
+// NewRandomTwoChoicesLoadBalancer creates a new random-two-choices load balancer
+func NewRandomTwoChoicesLoadBalancer(backends []string) *RandomTwoChoicesLoadBalancer {
+	return &RandomTwoChoicesLoadBalancer{
+		backendPool: newBackendPool(backends),
+	}
+}
+
+// NextBackend 返回下一个要使用的后端服务
+// NextBackend returns the next backend to use
+func (lb *RandomTwoChoicesLoadBalancer) NextBackend() string {
+	return lb.NextBackendExcluding(nil)
+}
+
+// NextBackendExcluding 返回下一个不在 exclude 中的后端服务
+// NextBackendExcluding returns the next backend not in exclude
+func (lb *RandomTwoChoicesLoadBalancer) NextBackendExcluding(exclude []string) string {
+	return lb.pick(exclude, func(candidates []string) string {
+		if len(candidates) == 1 {
+			return candidates[0]
+		}
+
+		i := rand.IntN(len(candidates))
+		j := rand.IntN(len(candidates) - 1)
+		if j >= i {
+			j++
+		}
+
+		statuses := lb.snapshot([]string{candidates[i], candidates[j]})
+		if len(statuses) < 2 {
+			return candidates[i]
+		}
+
+		// 先比较正在处理的请求数，再比较平均响应时间
+		// Compare in-flight requests first, then average response time
+		first, second := &statuses[0], &statuses[1]
+		if second.ActiveRequests < first.ActiveRequests ||
+			(second.ActiveRequests == first.ActiveRequests && second.averageResponseTime() < first.averageResponseTime()) {
+			return second.URL
+		}
+		return first.URL
+	})
+}
@@ -1,6 +1,7 @@
 package loadbalancer
 
 import (
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -11,15 +12,57 @@ import (
 
 var logger = loggerPkg.GetLogger()
 
+// 最多保存的最近响应时间个数
+// Maximum number of recent response times kept per backend
+const maxResponseTimes = 10
+
+// 后端的管理状态，由运维人员通过管理 API 设置
+// Administrative states of a backend, set by operators through the admin API
+const (
+	// AdminStateEnabled 后端正常接收流量
+	// AdminStateEnabled means the backend receives traffic normally
+	AdminStateEnabled = "enabled"
+	// AdminStateDraining 后端不再接收新请求，正在处理的请求和连接继续完成
+	// AdminStateDraining means the backend gets no new requests while in-flight requests and connections finish
+	AdminStateDraining = "draining"
+	// AdminStateDisabled 后端不再接收任何流量
+	// AdminStateDisabled means the backend receives no traffic at all
+	AdminStateDisabled = "disabled"
+)
+
+// IsAdminState 判断 state 是否为有效的管理状态
+// IsAdminState reports whether state is a valid administrative state
+func IsAdminState(state string) bool {
+	return state == AdminStateEnabled || state == AdminStateDraining || state == AdminStateDisabled
+}
+
 // BackendStatus 表示后端服务的状态
 // BackendStatus represents the status of a backend service
 type BackendStatus struct {
-	URL           string          // 后端服务URL / Backend service URL
-	Healthy       bool            // 是否健康 / Whether it's healthy
-	FailCount     int             // 连续失败次数 / Consecutive failure count
-	LastFailTime  time.Time       // 最后一次失败时间 / Last failure time
-	ResponseTimes []time.Duration // 最近的响应时间 / Recent response times
-	mutex         *sync.RWMutex   // 读写锁 / Read-write lock
+	URL            string          // 后端服务URL / Backend service URL
+	Healthy        bool            // 是否健康 / Whether it's healthy
+	FailCount      int             // 连续失败次数 / Consecutive failure count
+	LastFailTime   time.Time       // 最后一次失败时间 / Last failure time
+	ResponseTimes  []time.Duration // 最近的响应时间 / Recent response times
+	ActiveRequests int             // 正在处理的请求数 / In-flight request count
+	AdminState     string          // 管理状态 / Administrative state
+	CircuitState   string          // 熔断器状态，未启用时为空 / Circuit breaker state, empty when disabled
+	breaker        *circuitBreaker // 熔断器 / Circuit breaker
+	mutex          *sync.RWMutex   // 读写锁 / Read-write lock
+}
+
+// averageResponseTime 返回最近响应时间的平均值，没有样本时返回 0，调用方需持有读锁
+// averageResponseTime returns the average of recent response times, 0 without samples; the caller must hold the read lock
+func (s *BackendStatus) averageResponseTime() time.Duration {
+	if len(s.ResponseTimes) == 0 {
+		return 0
+	}
+
+	var total time.Duration
+	for _, responseTime := range s.ResponseTimes {
+		total += responseTime
+	}
+	return total / time.Duration(len(s.ResponseTimes))
 }
 
 // LoadBalancer 负载均衡器接口
@@ -29,6 +72,12 @@ type LoadBalancer interface {
 	// NextBackend returns the next backend to use
 	NextBackend() string
 
+	// NextBackendExcluding 返回下一个不在 exclude 中的后端服务，用于重试时换用其他后端；
+	// 所有可用后端都被排除时仍从中选择
+	// NextBackendExcluding returns the next backend not in exclude, used to switch backends on retry;
+	// when every available backend is excluded it still picks one of them
+	NextBackendExcluding(exclude []string) string
+
 	// ReportSuccess 报告后端服务请求成功
 	// ReportSuccess reports a successful request to the backend
 	ReportSuccess(backend string, responseTime time.Duration)
@@ -37,211 +86,475 @@ type LoadBalancer interface {
 	// ReportFailure reports a failed request to the backend
 	ReportFailure(backend string)
 
+	// Release 释放选择后端时记录的请求，既不计为成功也不计为失败，用于请求未发送到后端的情况
+	// Release releases the request recorded when the backend was picked without counting a success or a failure, for
+	// requests that never reached the backend
+	Release(backend string)
+
 	// GetBackends 获取所有后端服务
 	// GetBackends returns all backends
 	GetBackends() []string
 
-	// GetHealthyBackends 获取所有健康的后端服务
-	// GetHealthyBackends returns all healthy backends
+	// GetHealthyBackends 获取所有健康且启用的后端服务
+	// GetHealthyBackends returns all healthy backends that are enabled
 	GetHealthyBackends() []string
+
+	// GetBackendStatuses 获取所有后端服务状态的副本
+	// GetBackendStatuses returns copies of all backend statuses
+	GetBackendStatuses() []BackendStatus
+
+	// SetBackendAdminState 设置后端的管理状态，只有 enabled 状态的后端会被选择；后端不存在时返回 false
+	// SetBackendAdminState sets the administrative state of a backend, only enabled backends are picked;
+	// it returns false when the backend does not exist
+	SetBackendAdminState(backend, state string) bool
 }
 
-// RoundRobinLoadBalancer 实现轮询负载均衡
-// RoundRobinLoadBalancer implements round-robin load balancing
-type RoundRobinLoadBalancer struct {
+// KeyedLoadBalancer 根据请求键选择后端的负载均衡器，例如一致性哈希
+// KeyedLoadBalancer is a load balancer that picks a backend from a request key, e.g. consistent hashing
+type KeyedLoadBalancer interface {
+	LoadBalancer
+
+	// NextBackendForKey 返回给定键对应的后端服务，exclude 的含义与 NextBackendExcluding 相同
+	// NextBackendForKey returns the backend for the given key, exclude works as in NextBackendExcluding
+	NextBackendForKey(key string, exclude []string) string
+}
+
+// PassiveHealthConfig 根据真实流量的失败标记后端不健康的配置
+// PassiveHealthConfig configures how failures of live traffic mark backends unhealthy
+type PassiveHealthConfig struct {
+	MaxFails    int           // 连续失败多少次后标记为不健康 / Consecutive failures before marking unhealthy
+	FailTimeout time.Duration // 不健康的后端多久后重试 / Time before an unhealthy backend is retried
+	FailOpen    bool          // 所有后端都不可用时重置并使用所有启用的后端 / Reset and use every enabled backend when all are down
+}
+
+// PassiveHealthTarget 可以设置被动健康检查阈值的负载均衡器
+// PassiveHealthTarget is a load balancer whose passive health thresholds can be set
+type PassiveHealthTarget interface {
+	// SetPassiveHealth 设置被动健康检查阈值以及所有后端都不可用时的处理方式
+	// SetPassiveHealth sets the passive health thresholds and what to do when every backend is down
+	SetPassiveHealth(config PassiveHealthConfig)
+}
+
+// backendPool 各负载均衡策略共用的后端列表和健康状态跟踪
+// backendPool is the backend list and health tracking shared by all load balancing strategies
+type backendPool struct {
 	backends     []BackendStatus // 后端服务列表 / List of backends
-	current      uint32          // 当前索引 / Current index
 	maxFailCount int             // 最大失败次数 / Maximum failure count
 	failTimeout  time.Duration   // 失败超时时间 / Failure timeout
+	activeCheck  atomic.Bool     // 是否由主动健康检查恢复后端 / Whether backends recover through active health checks
+	failOpen     atomic.Bool     // 所有后端都不可用时是否重置 / Whether backends are reset when all are down
 	mutex        sync.RWMutex    // 读写锁 / Read-write lock
 }
 
-// NewRoundRobinLoadBalancer 创建一个新的轮询负载均衡器
-// NewRoundRobinLoadBalancer creates a new round-robin load balancer
-func NewRoundRobinLoadBalancer(backends []string) *RoundRobinLoadBalancer {
-	lb := &RoundRobinLoadBalancer{
+// newBackendPool 创建后端列表，所有后端初始为健康
+// newBackendPool creates the backend list with every backend initially healthy
+func newBackendPool(backends []string) *backendPool {
+	pool := &backendPool{
 		backends:     make([]BackendStatus, len(backends)),
-		current:      0,
 		maxFailCount: 3,                // 默认最大失败次数 / Default maximum failure count
 		failTimeout:  30 * time.Second, // 默认失败超时时间 / Default failure timeout
 		mutex:        sync.RWMutex{},
 	}
 
+	pool.failOpen.Store(true)
+
 	for i, backend := range backends {
-		lb.backends[i] = BackendStatus{
+		pool.backends[i] = BackendStatus{
 			URL:           backend,
 			Healthy:       true,
 			FailCount:     0,
 			LastFailTime:  time.Time{},
-			ResponseTimes: make([]time.Duration, 0, 10),
+			ResponseTimes: make([]time.Duration, 0, maxResponseTimes),
+			AdminState:    AdminStateEnabled,
 			mutex:         &sync.RWMutex{},
 		}
 	}
 
-	return lb
+	return pool
 }
 
-// NextBackend 返回下一个要使用的后端服务
-// NextBackend returns the next backend to use
-func (lb *RoundRobinLoadBalancer) NextBackend() string {
-	// 首先尝试获取下一个健康的后端
-	// First try to get the next healthy backend
-	healthyBackends := lb.GetHealthyBackends()
-	if len(healthyBackends) == 0 {
-		// 如果没有健康的后端，重置所有后端状态并返回第一个
-		// If no healthy backends, reset all backends and return the first one
-		lb.resetBackends()
-
-		lb.mutex.RLock()
-		if len(lb.backends) > 0 {
-			firstBackend := lb.backends[0].URL
-			lb.mutex.RUnlock()
-			return firstBackend
-		}
-		lb.mutex.RUnlock()
+// candidates 返回可以接收流量的后端。没有健康的后端时，fail open 则重置所有后端并返回熔断器允许的启用后端，否则返回空列表。
+// 重置不会关闭熔断器，所有熔断器都打开时同样返回空列表
+// candidates returns the backends that may receive traffic. When none is healthy, all backends are reset and the
+// enabled ones their circuit breaker allows are returned when failing open, otherwise the result is empty. Resetting
+// does not close circuits, so the result is also empty while every circuit is open
+func (p *backendPool) candidates() []string {
+	healthyBackends := p.GetHealthyBackends()
+	if len(healthyBackends) > 0 {
+		return healthyBackends
+	}
+
+	if !p.failOpen.Load() {
+		// 不向已知不可用的后端发送流量，避免所有请求同时涌向刚恢复的后端
+		// Don't send traffic to backends known to be down, so requests do not all rush to backends that just came back
+		logger.Warn("No healthy backends available")
+		return nil
+	}
+
+	// 如果没有健康的后端，重置所有后端状态，打开的熔断器仍然拒绝流量
+	// If no healthy backends, reset all backends, open circuits still refuse traffic
+	p.resetBackends()
+	return p.GetHealthyBackends()
+}
+
+// candidatesExcluding 返回不在 exclude 中的候选后端，全部被排除时返回全部候选后端
+// candidatesExcluding returns the candidates not in exclude, or all candidates when every one is excluded
+func (p *backendPool) candidatesExcluding(exclude []string) []string {
+	candidates := p.candidates()
+	if len(exclude) == 0 {
+		return candidates
+	}
+
+	remaining := make([]string, 0, len(candidates))
+	for _, backend := range candidates {
+		if !slices.Contains(exclude, backend) {
+			remaining = append(remaining, backend)
+		}
+	}
+	if len(remaining) == 0 {
+		return candidates
+	}
+	return remaining
+}
+
+// acquire 记录一个发往后端的请求，请求结束时由 ReportSuccess、ReportFailure 或 Release 释放。
+// 熔断器拒绝时（半开状态的探测名额已用完）返回空字符串
+// acquire records a request sent to the backend, released by ReportSuccess, ReportFailure or Release when it completes.
+// It returns an empty string when the circuit breaker refuses the request because the half-open probes are taken
+func (p *backendPool) acquire(backend string) string {
+	if backend == "" {
 		return ""
 	}
+	acquired := false
+	p.withBackend(backend, func(status *BackendStatus) {
+		if !status.breaker.acquire(backend, time.Now()) {
+			return
+		}
+		status.ActiveRequests++
+		acquired = true
+	})
+	if !acquired {
+		return ""
+	}
+	return backend
+}
+
+// pick 从不在 exclude 中的候选后端中用 choose 选择后端并记录请求，被熔断器拒绝的后端会被移除后重新选择
+// pick chooses a backend among the candidates not in exclude with choose and records the request, backends refused
+// by their circuit breaker are removed before choosing again
+func (p *backendPool) pick(exclude []string, choose func(candidates []string) string) string {
+	candidates := p.candidatesExcluding(exclude)
+	for len(candidates) > 0 {
+		backend := choose(candidates)
+		if backend == "" {
+			return ""
+		}
+		if p.acquire(backend) != "" {
+			return backend
+		}
+		candidates = slices.DeleteFunc(slices.Clone(candidates), func(candidate string) bool { return candidate == backend })
+	}
+	return ""
+}
 
-	// 使用原子操作增加计数器，实现线程安全的轮询
-	// Use atomic operation to increment counter for thread-safe round-robin
-	current := atomic.AddUint32(&lb.current, 1) % uint32(len(healthyBackends))
-	return healthyBackends[current]
+// withBackend 在持有后端写锁时对其执行 fn
+// withBackend runs fn on the backend while holding its write lock
+func (p *backendPool) withBackend(backend string, fn func(status *BackendStatus)) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	for i := range p.backends {
+		if p.backends[i].URL == backend {
+			p.backends[i].mutex.Lock()
+			fn(&p.backends[i])
+			p.backends[i].mutex.Unlock()
+			return
+		}
+	}
 }
 
 // ReportSuccess 报告后端服务请求成功
 // ReportSuccess reports a successful request to the backend
-func (lb *RoundRobinLoadBalancer) ReportSuccess(backend string, responseTime time.Duration) {
-	lb.mutex.Lock()
-	defer lb.mutex.Unlock()
-
-	for i := range lb.backends {
-		if lb.backends[i].URL == backend {
-			lb.backends[i].mutex.Lock()
-			lb.backends[i].Healthy = true
-			lb.backends[i].FailCount = 0
-
-			// 保存最近的响应时间，最多保存10个
-			// Save recent response times, up to 10
-			if len(lb.backends[i].ResponseTimes) >= 10 {
-				lb.backends[i].ResponseTimes = lb.backends[i].ResponseTimes[1:]
-			}
-			lb.backends[i].ResponseTimes = append(lb.backends[i].ResponseTimes, responseTime)
-			lb.backends[i].mutex.Unlock()
+func (p *backendPool) ReportSuccess(backend string, responseTime time.Duration) {
+	p.withBackend(backend, func(status *BackendStatus) {
+		status.Healthy = true
+		status.FailCount = 0
+		if status.ActiveRequests > 0 {
+			status.ActiveRequests--
+		}
+		status.breaker.recordSuccess(backend, time.Now())
 
-			logger.Debug("Backend reported success",
-				zap.String("backend", backend),
-				zap.Duration("responseTime", responseTime))
-			break
+		// 保存最近的响应时间，最多保存10个
+		// Save recent response times, up to 10
+		if len(status.ResponseTimes) >= maxResponseTimes {
+			status.ResponseTimes = status.ResponseTimes[1:]
 		}
-	}
+		status.ResponseTimes = append(status.ResponseTimes, responseTime)
+
+		logger.Debug("Backend reported success",
+			zap.String("backend", backend),
+			zap.Duration("responseTime", responseTime))
+	})
 }
 
 // ReportFailure 报告后端服务请求失败
 // ReportFailure reports a failed request to the backend
-func (lb *RoundRobinLoadBalancer) ReportFailure(backend string) {
-	lb.mutex.Lock()
-	defer lb.mutex.Unlock()
-
-	for i := range lb.backends {
-		if lb.backends[i].URL == backend {
-			lb.backends[i].mutex.Lock()
-			lb.backends[i].FailCount++
-			lb.backends[i].LastFailTime = time.Now()
-
-			// 如果连续失败次数超过最大失败次数，标记为不健康
-			// If consecutive failures exceed the maximum, mark as unhealthy
-			if lb.backends[i].FailCount >= lb.maxFailCount {
-				lb.backends[i].Healthy = false
-				logger.Warn("Backend marked as unhealthy",
-					zap.String("backend", backend),
-					zap.Int("failCount", lb.backends[i].FailCount))
-			} else {
-				logger.Debug("Backend reported failure",
-					zap.String("backend", backend),
-					zap.Int("failCount", lb.backends[i].FailCount))
-			}
-			lb.backends[i].mutex.Unlock()
-			break
+func (p *backendPool) ReportFailure(backend string) {
+	p.withBackend(backend, func(status *BackendStatus) {
+		status.FailCount++
+		status.LastFailTime = time.Now()
+		if status.ActiveRequests > 0 {
+			status.ActiveRequests--
+		}
+
+		// 启用熔断器时由熔断器决定是否停止向后端发送流量
+		// With a circuit breaker, the breaker decides whether the backend stops receiving traffic
+		if status.breaker != nil {
+			status.breaker.recordFailure(backend, status.LastFailTime)
+			return
 		}
-	}
+
+		// 如果连续失败次数超过最大失败次数，标记为不健康
+		// If consecutive failures exceed the maximum, mark as unhealthy
+		if status.FailCount >= p.maxFailCount {
+			status.Healthy = false
+			logger.Warn("Backend marked as unhealthy",
+				zap.String("backend", backend),
+				zap.Int("failCount", status.FailCount))
+		} else {
+			logger.Debug("Backend reported failure",
+				zap.String("backend", backend),
+				zap.Int("failCount", status.FailCount))
+		}
+	})
 
 	// 检查是否所有后端都不健康，如果是，重置所有后端
 	// Check if all backends are unhealthy, if so, reset all backends
+	p.mutex.RLock()
 	allUnhealthy := true
-	for i := range lb.backends {
-		lb.backends[i].mutex.RLock()
-		if lb.backends[i].Healthy {
+	for i := range p.backends {
+		p.backends[i].mutex.RLock()
+		if p.backends[i].Healthy {
 			allUnhealthy = false
 		}
-		lb.backends[i].mutex.RUnlock()
+		p.backends[i].mutex.RUnlock()
 	}
+	p.mutex.RUnlock()
 
-	if allUnhealthy {
-		lb.resetBackends()
+	if allUnhealthy && p.failOpen.Load() {
+		p.resetBackends()
 	}
 }
 
+// Release 释放选择后端时记录的请求，不影响后端的健康状态和熔断器统计
+// Release releases the request recorded when the backend was picked, leaving its health and circuit breaker counts
+// untouched
+func (p *backendPool) Release(backend string) {
+	p.withBackend(backend, func(status *BackendStatus) {
+		if status.ActiveRequests > 0 {
+			status.ActiveRequests--
+		}
+		status.breaker.release()
+	})
+}
+
 // GetBackends 获取所有后端服务
 // GetBackends returns all backends
-func (lb *RoundRobinLoadBalancer) GetBackends() []string {
-	lb.mutex.RLock()
-	defer lb.mutex.RUnlock()
+func (p *backendPool) GetBackends() []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
 
-	result := make([]string, len(lb.backends))
-	for i, backend := range lb.backends {
+	result := make([]string, len(p.backends))
+	for i, backend := range p.backends {
 		result[i] = backend.URL
 	}
 	return result
 }
 
-// GetHealthyBackends 获取所有健康的后端服务
-// GetHealthyBackends returns all healthy backends
-func (lb *RoundRobinLoadBalancer) GetHealthyBackends() []string {
-	lb.mutex.RLock()
-	defer lb.mutex.RUnlock()
+// GetBackendStatuses 获取所有后端服务状态的副本
+// GetBackendStatuses returns copies of all backend statuses
+func (p *backendPool) GetBackendStatuses() []BackendStatus {
+	return p.snapshot(p.GetBackends())
+}
+
+// GetHealthyBackends 获取所有健康且启用的后端服务
+// GetHealthyBackends returns all healthy backends that are enabled
+func (p *backendPool) GetHealthyBackends() []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
 
 	var result []string
 	now := time.Now()
 
-	for i := range lb.backends {
-		lb.backends[i].mutex.RLock()
+	for i := range p.backends {
+		p.backends[i].mutex.RLock()
 
 		// 如果后端不健康但已经超过失败超时时间，重新标记为健康以便重试
 		// If backend is unhealthy but failure timeout has passed, mark as healthy for retry
-		if !lb.backends[i].Healthy && !lb.backends[i].LastFailTime.IsZero() {
-			if now.Sub(lb.backends[i].LastFailTime) > lb.failTimeout {
-				lb.backends[i].mutex.RUnlock()
-				lb.backends[i].mutex.Lock()
-				lb.backends[i].Healthy = true
-				lb.backends[i].FailCount = 0
-				lb.backends[i].mutex.Unlock()
-				lb.backends[i].mutex.RLock()
-
-				logger.Info("Backend recovery attempt", zap.String("backend", lb.backends[i].URL))
+		if !p.backends[i].Healthy && !p.backends[i].LastFailTime.IsZero() && !p.activeCheck.Load() {
+			if now.Sub(p.backends[i].LastFailTime) > p.failTimeout {
+				p.backends[i].mutex.RUnlock()
+				p.backends[i].mutex.Lock()
+				p.backends[i].Healthy = true
+				p.backends[i].FailCount = 0
+				p.backends[i].mutex.Unlock()
+				p.backends[i].mutex.RLock()
+
+				logger.Info("Backend recovery attempt", zap.String("backend", p.backends[i].URL))
 			}
 		}
 
-		if lb.backends[i].Healthy {
-			result = append(result, lb.backends[i].URL)
+		if p.backends[i].Healthy && p.backends[i].AdminState == AdminStateEnabled && p.backends[i].breaker.allows(now) {
+			result = append(result, p.backends[i].URL)
+		}
+		p.backends[i].mutex.RUnlock()
+	}
+
+	return result
+}
+
+// SetBackendAdminState 设置后端的管理状态
+// SetBackendAdminState sets the administrative state of a backend
+func (p *backendPool) SetBackendAdminState(backend, state string) bool {
+	found := false
+	p.withBackend(backend, func(status *BackendStatus) {
+		found = true
+		if status.AdminState == state {
+			return
+		}
+		logger.Info("Backend administrative state changed",
+			zap.String("backend", backend),
+			zap.String("from", status.AdminState),
+			zap.String("to", state),
+			zap.Int("activeRequests", status.ActiveRequests))
+		status.AdminState = state
+	})
+	return found
+}
+
+// SetActiveHealthCheck 启用后，后端只能由健康检查恢复
+// SetActiveHealthCheck makes backends recover only through health checks when enabled
+func (p *backendPool) SetActiveHealthCheck(enabled bool) {
+	p.activeCheck.Store(enabled)
+}
+
+// SetPassiveHealth 设置被动健康检查阈值以及所有后端都不可用时的处理方式
+// SetPassiveHealth sets the passive health thresholds and what to do when every backend is down
+func (p *backendPool) SetPassiveHealth(config PassiveHealthConfig) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.maxFailCount = config.MaxFails
+	p.failTimeout = config.FailTimeout
+	p.failOpen.Store(config.FailOpen)
+}
+
+// SetCircuitBreaker 为所有后端启用熔断器，config 为 nil 时停用
+// SetCircuitBreaker enables a circuit breaker for every backend, disabling it when config is nil
+func (p *backendPool) SetCircuitBreaker(config *CircuitBreakerConfig) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	for i := range p.backends {
+		p.backends[i].mutex.Lock()
+		p.backends[i].breaker = nil
+		if config != nil {
+			p.backends[i].breaker = newCircuitBreaker(*config)
 		}
-		lb.backends[i].mutex.RUnlock()
+		p.backends[i].mutex.Unlock()
 	}
+}
+
+// SetBackendHealth 设置后端服务的健康状态
+// SetBackendHealth sets the health of a backend
+func (p *backendPool) SetBackendHealth(backend string, healthy bool) {
+	p.withBackend(backend, func(status *BackendStatus) {
+		if status.Healthy == healthy {
+			return
+		}
 
+		status.Healthy = healthy
+		if healthy {
+			status.FailCount = 0
+			logger.Info("Backend marked as healthy by health check", zap.String("backend", backend))
+		} else {
+			status.LastFailTime = time.Now()
+			logger.Warn("Backend marked as unhealthy by health check", zap.String("backend", backend))
+		}
+	})
+}
+
+// snapshot 返回指定后端状态的副本，按给定顺序排列
+// snapshot returns copies of the given backends' statuses in the given order
+func (p *backendPool) snapshot(backends []string) []BackendStatus {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	result := make([]BackendStatus, 0, len(backends))
+	for _, backend := range backends {
+		for i := range p.backends {
+			if p.backends[i].URL != backend {
+				continue
+			}
+			p.backends[i].mutex.RLock()
+			status := p.backends[i]
+			status.ResponseTimes = append([]time.Duration(nil), p.backends[i].ResponseTimes...)
+			status.CircuitState = p.backends[i].breaker.circuitState()
+			p.backends[i].mutex.RUnlock()
+			status.breaker = nil
+			status.mutex = nil
+			result = append(result, status)
+			break
+		}
+	}
 	return result
 }
 
 // resetBackends 重置所有后端状态
 // resetBackends resets all backend statuses
-func (lb *RoundRobinLoadBalancer) resetBackends() {
-	lb.mutex.Lock()
-	defer lb.mutex.Unlock()
+func (p *backendPool) resetBackends() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
 
 	logger.Warn("No healthy backends available, resetting all backends")
 
-	for i := range lb.backends {
-		lb.backends[i].mutex.Lock()
-		lb.backends[i].Healthy = true
-		lb.backends[i].FailCount = 0
-		lb.backends[i].mutex.Unlock()
+	for i := range p.backends {
+		p.backends[i].mutex.Lock()
+		p.backends[i].Healthy = true
+		p.backends[i].FailCount = 0
+		p.backends[i].mutex.Unlock()
 	}
 }
+
+// RoundRobinLoadBalancer 实现轮询负载均衡
+// RoundRobinLoadBalancer implements round-robin load balancing
+type RoundRobinLoadBalancer struct {
+	*backendPool
+	current uint32 // 当前索引 / Current index
+}
+
+// NewRoundRobinLoadBalancer 创建一个新的轮询负载均衡器
+// NewRoundRobinLoadBalancer creates a new round-robin load balancer
+func NewRoundRobinLoadBalancer(backends []string) *RoundRobinLoadBalancer {
+	return &RoundRobinLoadBalancer{
+		backendPool: newBackendPool(backends),
+		current:     0,
+	}
+}
+
+// NextBackend 返回下一个要使用的后端服务
+// NextBackend returns the next backend to use
+func (lb *RoundRobinLoadBalancer) NextBackend() string {
+	return lb.NextBackendExcluding(nil)
+}
+
+// NextBackendExcluding 返回下一个不在 exclude 中的后端服务
+// NextBackendExcluding returns the next backend not in exclude
+func (lb *RoundRobinLoadBalancer) NextBackendExcluding(exclude []string) string {
+	return lb.pick(exclude, func(candidates []string) string {
+		// 使用原子操作增加计数器，实现线程安全的轮询
+		// Use atomic operation to increment counter for thread-safe round-robin
+		current := atomic.AddUint32(&lb.current, 1) % uint32(len(candidates))
+		return candidates[current]
+	})
+}
@@ -0,0 +1,59 @@
+package loadbalancer
+
+import "sync"
+
+// WeightedRoundRobinLoadBalancer 实现平滑加权轮询负载均衡
+// WeightedRoundRobinLoadBalancer implements smooth weighted round-robin load balancing
+type WeightedRoundRobinLoadBalancer struct {
+	*backendPool
+	weights map[string]int // 后端权重 / Backend weights
+	current map[string]int // 当前有效权重 / Current effective weights
+	mutex   sync.Mutex     // 保护 current / Guards current
+}
+
+// NewWeightedRoundRobinLoadBalancer 创建一个新的加权轮询负载均衡器，未配置权重的后端权重为 1
+// NewWeightedRoundRobinLoadBalancer creates a new weighted round-robin load balancer, backends without a weight get weight 1
+func NewWeightedRoundRobinLoadBalancer(backends []string, weights map[string]int) *WeightedRoundRobinLoadBalancer {
+	lb := &WeightedRoundRobinLoadBalancer{
+		backendPool: newBackendPool(backends),
+		weights:     make(map[string]int, len(backends)),
+		current:     make(map[string]int, len(backends)),
+	}
+
+	for _, backend := range backends {
+		weight := weights[backend]
+		if weight <= 0 {
+			weight = 1
+		}
+		lb.weights[backend] = weight
+	}
+
+	return lb
+}
+
+// NextBackend 返回下一个要使用的后端服务
+// NextBackend returns the next backend to use
+func (lb *WeightedRoundRobinLoadBalancer) NextBackend() string {
+	return lb.NextBackendExcluding(nil)
+}
+
+// NextBackendExcluding 返回下一个不在 exclude 中的后端服务
+// NextBackendExcluding returns the next backend not in exclude
+func (lb *WeightedRoundRobinLoadBalancer) NextBackendExcluding(exclude []string) string {
+	return lb.pick(exclude, func(candidates []string) string {
+		lb.mutex.Lock()
+		defer lb.mutex.Unlock()
+
+		total := 0
+		best := ""
+		for _, backend := range candidates {
+			lb.current[backend] += lb.weights[backend]
+			total += lb.weights[backend]
+			if best == "" || lb.current[backend] > lb.current[best] {
+				best = backend
+			}
+		}
+		lb.current[best] -= total
+		return best
+	})
+}
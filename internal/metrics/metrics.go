@@ -0,0 +1,244 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nerdneilsfield/simple_api_gateway/internal/loadbalancer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 指标名称前缀
+// Prefix of all metric names
+const namespace = "gateway"
+
+// 后端请求失败（没有状态码）时使用的状态类别
+// Status class used when a backend request failed without a status code
+const StatusClassError = "error"
+
+// registry 网关自己的指标注册表，避免暴露全局默认注册表中的无关指标
+// registry is the gateway's own registry so unrelated metrics from the default registry are not exposed
+var registry = prometheus.NewRegistry()
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Requests handled per route and status class.",
+	}, []string{"route", "status_class"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "Time to handle a request per route, until the response headers are ready.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+
+	backendRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "backend_requests_total",
+		Help:      "Requests sent to backends per route, backend and status class; status_class is \"error\" when no response was received.",
+	}, []string{"route", "backend", "status_class"})
+
+	backendResponseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "backend_response_duration_seconds",
+		Help:      "Time until a backend answered or failed, per route and backend.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "backend"})
+
+	rateLimitedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected with 429 by the rate limiter per route.",
+	}, []string{"route"})
+
+	mirrorRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mirror_requests_total",
+		Help:      "Mirrored copies of requests per route and result: success, failure (error or 5xx) or dropped.",
+	}, []string{"route", "result"})
+
+	mirrorDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mirror_duration_seconds",
+		Help:      "Time until the mirror backend answered or failed, per route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+
+	cacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_hits_total",
+		Help:      "Cache lookups that found an entry.",
+	})
+
+	cacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_misses_total",
+		Help:      "Cache lookups that found no entry.",
+	})
+
+	cacheSets = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_sets_total",
+		Help:      "Responses stored in the cache.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestsTotal,
+		requestDuration,
+		backendRequestsTotal,
+		backendResponseDuration,
+		rateLimitedTotal,
+		mirrorRequestsTotal,
+		mirrorDuration,
+		cacheHits,
+		cacheMisses,
+		cacheSets,
+	)
+}
+
+// Handler 返回暴露所有网关指标的 HTTP 处理程序
+// Handler returns the HTTP handler exposing all gateway metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// StatusClass 返回状态码的类别，例如 2xx；状态码为 0 时返回 error
+// StatusClass returns the class of a status code such as 2xx, or error for status 0
+func StatusClass(statusCode int) string {
+	if statusCode <= 0 {
+		return StatusClassError
+	}
+	return strconv.Itoa(statusCode/100) + "xx"
+}
+
+// ObserveRequest 记录路由处理的一个请求
+// ObserveRequest records a request handled by a route
+func ObserveRequest(route string, statusCode int, duration time.Duration) {
+	requestsTotal.WithLabelValues(route, StatusClass(statusCode)).Inc()
+	requestDuration.WithLabelValues(route).Observe(duration.Seconds())
+}
+
+// ObserveBackend 记录发送到后端的一次请求，statusCode 为 0 表示请求失败
+// ObserveBackend records a request sent to a backend, statusCode 0 means the request failed
+func ObserveBackend(route, backend string, statusCode int, duration time.Duration) {
+	backendRequestsTotal.WithLabelValues(route, backend, StatusClass(statusCode)).Inc()
+	backendResponseDuration.WithLabelValues(route, backend).Observe(duration.Seconds())
+}
+
+// RateLimited 记录一个被限流拒绝的请求
+// RateLimited records a request rejected by the rate limiter
+func RateLimited(route string) {
+	rateLimitedTotal.WithLabelValues(route).Inc()
+}
+
+// 镜像请求的结果
+// Results of mirrored requests
+const (
+	MirrorSuccess = "success"
+	MirrorFailure = "failure"
+	MirrorDropped = "dropped"
+)
+
+// ObserveMirror 记录一个镜像请求的结果，被丢弃的副本没有耗时
+// ObserveMirror records the result of a mirrored request, dropped copies have no duration
+func ObserveMirror(route, result string, duration time.Duration) {
+	mirrorRequestsTotal.WithLabelValues(route, result).Inc()
+	if result != MirrorDropped {
+		mirrorDuration.WithLabelValues(route).Observe(duration.Seconds())
+	}
+}
+
+// CacheHit 记录一次缓存命中
+// CacheHit records a cache hit
+func CacheHit() {
+	cacheHits.Inc()
+}
+
+// CacheMiss 记录一次缓存未命中
+// CacheMiss records a cache miss
+func CacheMiss() {
+	cacheMisses.Inc()
+}
+
+// CacheSet 记录一次缓存写入
+// CacheSet records a response stored in the cache
+func CacheSet() {
+	cacheSets.Inc()
+}
+
+// RegisterBackendSource 注册后端状态来源，抓取指标时读取各路由后端的健康状态和活动请求数
+// RegisterBackendSource registers the backend status source read on every scrape for backend health and active requests
+func RegisterBackendSource(source func() map[string][]loadbalancer.BackendStatus) {
+	registry.MustRegister(&backendCollector{source: source})
+}
+
+var (
+	backendHealthyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "backend_healthy"),
+		"Whether the backend is currently considered healthy (1) or not (0).",
+		[]string{"route", "backend"}, nil)
+
+	backendActiveRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "backend_active_requests"),
+		"Requests and WebSocket connections currently in flight to the backend.",
+		[]string{"route", "backend"}, nil)
+
+	backendCircuitOpenDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "backend_circuit_open"),
+		"Whether the circuit breaker of the backend is open (1), half-open (0.5) or closed (0); only reported when enabled.",
+		[]string{"route", "backend"}, nil)
+)
+
+// backendCollector 在抓取时从负载均衡器读取后端状态，热重载后自动反映新的后端列表
+// backendCollector reads backend statuses from the load balancers at scrape time, so hot reloads are reflected
+type backendCollector struct {
+	source func() map[string][]loadbalancer.BackendStatus
+}
+
+// Describe 实现 prometheus.Collector
+// Describe implements prometheus.Collector
+func (c *backendCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- backendHealthyDesc
+	ch <- backendActiveRequestsDesc
+	ch <- backendCircuitOpenDesc
+}
+
+// Collect 实现 prometheus.Collector
+// Collect implements prometheus.Collector
+func (c *backendCollector) Collect(ch chan<- prometheus.Metric) {
+	for route, statuses := range c.source() {
+		for _, status := range statuses {
+			healthy := 0.0
+			if status.Healthy {
+				healthy = 1
+			}
+			ch <- prometheus.MustNewConstMetric(backendHealthyDesc, prometheus.GaugeValue, healthy, route, status.URL)
+			ch <- prometheus.MustNewConstMetric(backendActiveRequestsDesc, prometheus.GaugeValue, float64(status.ActiveRequests), route, status.URL)
+			if status.CircuitState != "" {
+				ch <- prometheus.MustNewConstMetric(backendCircuitOpenDesc, prometheus.GaugeValue, circuitValue(status.CircuitState), route, status.URL)
+			}
+		}
+	}
+}
+
+// circuitValue 将熔断器状态转换为指标值
+// circuitValue converts a circuit state to its metric value
+func circuitValue(state string) float64 {
+	switch state {
+	case loadbalancer.CircuitOpen:
+		return 1
+	case loadbalancer.CircuitHalfOpen:
+		return 0.5
+	default:
+		return 0
+	}
+}
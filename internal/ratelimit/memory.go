@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 清理空闲计数的间隔
+// Interval between sweeps of idle counters
+const sweepInterval = time.Minute
+
+// memoryTokenBucket 在内存中实现令牌桶限流
+// memoryTokenBucket implements token bucket rate limiting in memory
+type memoryTokenBucket struct {
+	config    Config
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+// tokenBucket 单个键的令牌桶
+// tokenBucket is the token bucket of a single key
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newMemoryTokenBucket(config Config) *memoryTokenBucket {
+	return &memoryTokenBucket{
+		config:    config,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow 实现 Limiter
+// Allow implements Limiter
+func (l *memoryTokenBucket) Allow(_ context.Context, key string) (Result, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(l.config.Burst), last: now}
+		l.buckets[key] = bucket
+	}
+
+	// 按经过的时间补充令牌，不超过容量
+	// Refill tokens for the elapsed time, up to the capacity
+	refill := float64(now.Sub(bucket.last)) * float64(l.config.Rate) / float64(l.config.Period)
+	bucket.tokens = min(float64(l.config.Burst), bucket.tokens+refill)
+	bucket.last = now
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	return tokenBucketResult(l.config, bucket.tokens, allowed), nil
+}
+
+// sweep 删除已经补满的令牌桶，调用方需持有锁
+// sweep removes buckets that are full again, the caller must hold the lock
+func (l *memoryTokenBucket) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	fullAfter := time.Duration(float64(l.config.Burst) * float64(l.config.Period) / float64(l.config.Rate))
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) > fullAfter {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// memorySlidingWindow 在内存中实现滑动窗口计数限流
+// memorySlidingWindow implements sliding window counter rate limiting in memory
+type memorySlidingWindow struct {
+	config    Config
+	windows   map[string]*slidingWindow
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+// slidingWindow 单个键的当前窗口和上一个窗口的计数
+// slidingWindow holds the counts of the current and previous windows of a single key
+type slidingWindow struct {
+	window   int64
+	previous int
+	current  int
+}
+
+func newMemorySlidingWindow(config Config) *memorySlidingWindow {
+	return &memorySlidingWindow{
+		config:    config,
+		windows:   make(map[string]*slidingWindow),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow 实现 Limiter
+// Allow implements Limiter
+func (l *memorySlidingWindow) Allow(_ context.Context, key string) (Result, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	period := int64(l.config.Period)
+	window := now.UnixNano() / period
+	elapsed := time.Duration(now.UnixNano() - window*period)
+
+	counts, exists := l.windows[key]
+	if !exists {
+		counts = &slidingWindow{window: window}
+		l.windows[key] = counts
+	}
+
+	// 进入新窗口时，当前计数成为上一个窗口的计数；跳过多个窗口时两者都清零
+	// On entering a new window the current count becomes the previous one; both reset when windows were skipped
+	switch counts.window {
+	case window:
+	case window - 1:
+		counts.previous, counts.current = counts.current, 0
+	default:
+		counts.previous, counts.current = 0, 0
+	}
+	counts.window = window
+
+	estimate := float64(counts.previous)*float64(l.config.Period-elapsed)/float64(l.config.Period) + float64(counts.current)
+	allowed := estimate+1 <= float64(l.config.Rate)
+	if allowed {
+		counts.current++
+	}
+	return slidingWindowResult(l.config, counts.previous, counts.current, elapsed, allowed), nil
+}
+
+// sweep 删除超过两个窗口没有请求的计数，调用方需持有锁
+// sweep removes counts without requests for more than two windows, the caller must hold the lock
+func (l *memorySlidingWindow) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	window := now.UnixNano() / int64(l.config.Period)
+	for key, counts := range l.windows {
+		if window-counts.window > 1 {
+			delete(l.windows, key)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 限流算法
+// Rate limiting algorithms
+const (
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmSlidingWindow = "sliding_window"
+)
+
+// Config 限流配置
+// Config is the rate limit configuration
+type Config struct {
+	Algorithm string        // 限流算法 / Rate limiting algorithm
+	Rate      int           // 每个周期允许的请求数 / Requests allowed per period
+	Period    time.Duration // 速率的周期 / Period of the rate
+	Burst     int           // 令牌桶容量 / Token bucket capacity
+}
+
+// limit 返回客户端可见的请求上限：令牌桶为容量，滑动窗口为每个周期的请求数
+// limit returns the request limit shown to clients: the capacity for token buckets, the rate for sliding windows
+func (c Config) limit() int {
+	if c.Algorithm == AlgorithmTokenBucket {
+		return c.Burst
+	}
+	return c.Rate
+}
+
+// Result 一次限流判断的结果
+// Result is the outcome of a rate limit decision
+type Result struct {
+	Allowed    bool          // 是否允许请求 / Whether the request is allowed
+	Limit      int           // 请求上限 / Request limit
+	Remaining  int           // 剩余可用请求数 / Requests still available
+	RetryAfter time.Duration // 被拒绝时多久后可以重试 / When denied, how long until a retry may succeed
+	Reset      time.Duration // 多久后配额完全恢复 / How long until the quota is fully restored
+}
+
+// Limiter 按键限制请求速率
+// Limiter limits the request rate per key
+type Limiter interface {
+	// Allow 判断键对应的请求是否允许，并消耗一个配额
+	// Allow decides whether a request for the key is allowed and consumes one unit of quota
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// New 创建限流器。client 不为 nil 时计数保存在 Redis 中，在多个网关实例之间共享；否则保存在内存中
+// New creates a limiter. With a non-nil client counters are kept in Redis and shared across gateway instances,
+// otherwise they are kept in memory
+func New(config Config, client *redis.Client, keyPrefix string) Limiter {
+	if client != nil {
+		if config.Algorithm == AlgorithmSlidingWindow {
+			return &redisSlidingWindow{config: config, client: client, keyPrefix: keyPrefix}
+		}
+		return &redisTokenBucket{config: config, client: client, keyPrefix: keyPrefix}
+	}
+
+	if config.Algorithm == AlgorithmSlidingWindow {
+		return newMemorySlidingWindow(config)
+	}
+	return newMemoryTokenBucket(config)
+}
+
+// tokenBucketResult 根据扣除后剩余的令牌数计算令牌桶的结果
+// tokenBucketResult computes a token bucket result from the tokens left after the decision
+func tokenBucketResult(config Config, tokens float64, allowed bool) Result {
+	perToken := float64(config.Period) / float64(config.Rate)
+
+	result := Result{
+		Allowed:   allowed,
+		Limit:     config.limit(),
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration(math.Ceil((float64(config.Burst) - tokens) * perToken)),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration(math.Ceil((1 - tokens) * perToken))
+	}
+	return result
+}
+
+// slidingWindowResult 根据上一个窗口和当前窗口的计数计算滑动窗口的结果。
+// 估计的请求数为上一个窗口的计数按剩余比例加权，再加上当前窗口的计数
+// slidingWindowResult computes a sliding window result from the counts of the previous and current windows.
+// The estimated count is the previous count weighted by the part of it still inside the window, plus the current count
+func slidingWindowResult(config Config, previous, current int, elapsed time.Duration, allowed bool) Result {
+	period := float64(config.Period)
+	remainingWindow := period - float64(elapsed)
+	estimate := float64(previous)*remainingWindow/period + float64(current)
+
+	result := Result{
+		Allowed:   allowed,
+		Limit:     config.limit(),
+		Remaining: max(0, config.Rate-int(math.Ceil(estimate))),
+		// 当前窗口的请求完全移出窗口后配额恢复
+		// The quota is restored once the requests of the current window left the window
+		Reset: time.Duration(remainingWindow + period),
+	}
+	if current == 0 {
+		result.Reset = time.Duration(remainingWindow)
+	}
+
+	if !allowed {
+		rate := float64(config.Rate)
+		var wait float64
+		if current < config.Rate {
+			// 等待上一个窗口的权重降到足以容纳一个请求
+			// Wait until the weight of the previous window dropped enough to fit one request
+			wait = period*(1-(rate-float64(current)-1)/float64(previous)) - float64(elapsed)
+		} else {
+			// 等待下一个窗口，当前窗口的计数成为上一个窗口的计数
+			// Wait for the next window, where the current count becomes the previous count
+			wait = remainingWindow + period*(1-(rate-1)/float64(current))
+		}
+		result.RetryAfter = time.Duration(math.Ceil(max(wait, 0)))
+	}
+	return result
+}
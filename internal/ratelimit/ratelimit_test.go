@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketResult(t *testing.T) {
+	config := Config{Algorithm: AlgorithmTokenBucket, Rate: 10, Period: 10 * time.Second, Burst: 5}
+
+	tests := []struct {
+		name    string
+		tokens  float64
+		allowed bool
+		want    Result
+	}{
+		{
+			name:    "full after the request",
+			tokens:  4,
+			allowed: true,
+			want:    Result{Allowed: true, Limit: 5, Remaining: 4, Reset: time.Second},
+		},
+		{
+			name:    "fractional tokens left",
+			tokens:  2.5,
+			allowed: true,
+			want:    Result{Allowed: true, Limit: 5, Remaining: 2, Reset: 2500 * time.Millisecond},
+		},
+		{
+			name:    "denied until a token refills",
+			tokens:  0.25,
+			allowed: false,
+			want:    Result{Allowed: false, Limit: 5, Remaining: 0, RetryAfter: 750 * time.Millisecond, Reset: 4750 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tokenBucketResult(config, tt.tokens, tt.allowed); got != tt.want {
+				t.Fatalf("tokenBucketResult = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlidingWindowResult(t *testing.T) {
+	config := Config{Algorithm: AlgorithmSlidingWindow, Rate: 10, Period: 10 * time.Second}
+
+	tests := []struct {
+		name     string
+		previous int
+		current  int
+		elapsed  time.Duration
+		allowed  bool
+		want     Result
+	}{
+		{
+			name:     "previous window weighted by its remaining part",
+			previous: 10,
+			current:  3,
+			elapsed:  5 * time.Second,
+			allowed:  true,
+			want:     Result{Allowed: true, Limit: 10, Remaining: 2, Reset: 15 * time.Second},
+		},
+		{
+			name:     "no requests in the current window",
+			previous: 10,
+			elapsed:  5 * time.Second,
+			allowed:  true,
+			want:     Result{Allowed: true, Limit: 10, Remaining: 5, Reset: 5 * time.Second},
+		},
+		{
+			// 1 秒后上一个窗口的权重为 4，加上当前的 5 个请求还能容纳一个请求
+			// One second later the previous window weighs 4, leaving room for one request next to the current 5
+			name:     "denied until the previous window weighs less",
+			previous: 10,
+			current:  5,
+			elapsed:  5 * time.Second,
+			allowed:  false,
+			want:     Result{Allowed: false, Limit: 10, Remaining: 0, RetryAfter: time.Second, Reset: 15 * time.Second},
+		},
+		{
+			// 下一个窗口开始 1 秒后，当前的 10 个请求权重为 9，还能容纳一个请求
+			// One second into the next window the current 10 requests weigh 9, leaving room for one request
+			name:    "denied until the next window",
+			current: 10,
+			elapsed: 4 * time.Second,
+			allowed: false,
+			want:    Result{Allowed: false, Limit: 10, Remaining: 0, RetryAfter: 7 * time.Second, Reset: 16 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slidingWindowResult(config, tt.previous, tt.current, tt.elapsed, tt.allowed)
+			if got != tt.want {
+				t.Fatalf("slidingWindowResult = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryLimiters(t *testing.T) {
+	// 周期足够长，测试期间不会补充令牌或进入下一个窗口
+	// The period is long enough that no tokens refill and no new window starts during the test
+	tests := []struct {
+		name    string
+		config  Config
+		allowed int
+	}{
+		{name: "token bucket allows the burst", config: Config{Algorithm: AlgorithmTokenBucket, Rate: 1, Period: time.Hour, Burst: 3}, allowed: 3},
+		{name: "sliding window allows the rate", config: Config{Algorithm: AlgorithmSlidingWindow, Rate: 2, Period: 24 * time.Hour}, allowed: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := New(tt.config, nil, "")
+			ctx := context.Background()
+
+			for i := 0; i < tt.allowed; i++ {
+				result, err := limiter.Allow(ctx, "client")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !result.Allowed || result.Remaining != tt.allowed-i-1 {
+					t.Fatalf("request %d: %+v, want allowed with %d remaining", i, result, tt.allowed-i-1)
+				}
+			}
+
+			result, err := limiter.Allow(ctx, "client")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Allowed || result.RetryAfter <= 0 {
+				t.Fatalf("request over the limit: %+v, want denied with a retry delay", result)
+			}
+
+			// 每个键单独计数
+			// Every key is counted separately
+			if result, err := limiter.Allow(ctx, "other"); err != nil || !result.Allowed {
+				t.Fatalf("other key: %+v, %v, want allowed", result, err)
+			}
+		})
+	}
+}
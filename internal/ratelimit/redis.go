@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// tokenBucketScript 原子地补充并扣除令牌，使用 Redis 服务器时间以避免网关实例之间的时钟偏差。
+// 返回是否允许以及剩余令牌数（字符串，避免 Lua 数字被截断为整数）
+// tokenBucketScript atomically refills and takes a token, using the Redis server time to avoid clock skew between
+// gateway instances. It returns whether the request is allowed and the tokens left (as a string so the Lua number is
+// not truncated to an integer)
+var tokenBucketScript = redis.NewScript(`
+if redis.replicate_commands then redis.replicate_commands() end
+local rate = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / period)
+
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', string.format('%.0f', now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * period / rate / 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// slidingWindowScript 原子地读取上一个窗口和当前窗口的计数，允许时增加当前窗口的计数。
+// 返回是否允许、上一个窗口计数、当前窗口计数和当前窗口已经过的微秒数
+// slidingWindowScript atomically reads the counts of the previous and current windows and increments the current one
+// when allowed. It returns whether the request is allowed, the previous count, the current count and the microseconds
+// elapsed in the current window
+var slidingWindowScript = redis.NewScript(`
+if redis.replicate_commands then redis.replicate_commands() end
+local rate = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local window = math.floor(now / period)
+local elapsed = now - window * period
+local currentKey = KEYS[1] .. ':' .. string.format('%.0f', window)
+local previous = tonumber(redis.call('GET', KEYS[1] .. ':' .. string.format('%.0f', window - 1))) or 0
+local current = tonumber(redis.call('GET', currentKey)) or 0
+
+local allowed = 0
+if previous * (period - elapsed) / period + current + 1 <= rate then
+  current = redis.call('INCR', currentKey)
+  redis.call('PEXPIRE', currentKey, math.ceil(period * 2 / 1000))
+  allowed = 1
+end
+return {allowed, previous, current, elapsed}
+`)
+
+// redisTokenBucket 在 Redis 中实现令牌桶限流，计数在网关实例之间共享
+// redisTokenBucket implements token bucket rate limiting in Redis, sharing counters across gateway instances
+type redisTokenBucket struct {
+	config    Config
+	client    *redis.Client
+	keyPrefix string
+}
+
+// Allow 实现 Limiter
+// Allow implements Limiter
+func (l *redisTokenBucket) Allow(ctx context.Context, key string) (Result, error) {
+	values, err := tokenBucketScript.Run(ctx, l.client, []string{l.keyPrefix + key},
+		l.config.Rate, l.config.Period.Microseconds(), l.config.Burst).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(values) != 2 {
+		return Result{}, fmt.Errorf("unexpected token bucket script result: %v", values)
+	}
+
+	allowed, _ := values[0].(int64)
+	text, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("unexpected token bucket script result: %v", values)
+	}
+
+	return tokenBucketResult(l.config, tokens, allowed == 1), nil
+}
+
+// redisSlidingWindow 在 Redis 中实现滑动窗口计数限流，计数在网关实例之间共享
+// redisSlidingWindow implements sliding window counter rate limiting in Redis, sharing counters across gateway instances
+type redisSlidingWindow struct {
+	config    Config
+	client    *redis.Client
+	keyPrefix string
+}
+
+// Allow 实现 Limiter
+// Allow implements Limiter
+func (l *redisSlidingWindow) Allow(ctx context.Context, key string) (Result, error) {
+	values, err := slidingWindowScript.Run(ctx, l.client, []string{l.keyPrefix + key},
+		l.config.Rate, l.config.Period.Microseconds()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(values) != 4 {
+		return Result{}, fmt.Errorf("unexpected sliding window script result: %v", values)
+	}
+
+	elapsed := time.Duration(values[3]) * time.Microsecond
+	return slidingWindowResult(l.config, int(values[1]), int(values[2]), elapsed, values[0] == 1), nil
+}
@@ -0,0 +1,282 @@
+package router
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/loadbalancer"
+	"go.uber.org/zap"
+)
+
+// 管理 API 操作名称与后端管理状态的对应关系
+// Admin API actions and the backend administrative states they set
+var adminBackendActions = map[string]string{
+	"enable":  loadbalancer.AdminStateEnabled,
+	"drain":   loadbalancer.AdminStateDraining,
+	"disable": loadbalancer.AdminStateDisabled,
+}
+
+// adminBackend 管理 API 返回的后端状态
+// adminBackend is a backend status returned by the admin API
+type adminBackend struct {
+	URL              string     `json:"url"`
+	State            string     `json:"state"`
+	Healthy          bool       `json:"healthy"`
+	Circuit          string     `json:"circuit,omitempty"`
+	FailCount        int        `json:"fail_count"`
+	LastFailTime     *time.Time `json:"last_fail_time,omitempty"`
+	ResponseTimesMs  []float64  `json:"recent_response_times_ms"`
+	ActiveRequests   int        `json:"active_requests"`
+	WebSocketsClosed int        `json:"websockets_closed,omitempty"`
+}
+
+// adminRoute 管理 API 返回的路由及其后端
+// adminRoute is a route and its backends returned by the admin API
+type adminRoute struct {
+	ID           string         `json:"id"`
+	VHost        string         `json:"vhost,omitempty"`
+	Name         string         `json:"name,omitempty"`
+	Path         string         `json:"path"`
+	LBStrategy   string         `json:"lb_strategy"`
+	CacheEnabled bool           `json:"cache_enabled"`
+	Backends     []adminBackend `json:"backends"`
+}
+
+// adminBackendRequest 修改后端管理状态的请求体
+// adminBackendRequest is the request body of a backend state change
+type adminBackendRequest struct {
+	Route   string `json:"route"`
+	Backend string `json:"backend"`
+}
+
+// adminPurgeRequest 清除缓存的请求体，可以直接给出缓存键，也可以给出请求由网关计算缓存键
+// adminPurgeRequest is the request body of a cache purge, either giving cache keys directly or requests the gateway
+// computes the cache keys from
+type adminPurgeRequest struct {
+	Route    string   `json:"route"`
+	Keys     []string `json:"keys"`
+	Requests []struct {
+		Method  string `json:"method"`
+		Path    string `json:"path"`
+		Query   string `json:"query"`
+		Body    string `json:"body"`
+		Subject string `json:"subject"`
+		Group   string `json:"group"`
+	} `json:"requests"`
+}
+
+// serveAdmin 在网关端口的前缀下或单独的监听地址上提供管理 API
+// serveAdmin serves the admin API under a prefix on the gateway port or on a separate listen address
+func serveAdmin(app *fiber.App, adminConfig config.Admin) {
+	if !adminConfig.Enabled {
+		return
+	}
+	adminConfig = adminConfig.WithDefaults()
+
+	if adminConfig.Listen == "" {
+		registerAdminRoutes(app.Group(adminConfig.Prefix), adminConfig.Token)
+		logger.Info("Serving admin API on the gateway port", zap.String("prefix", adminConfig.Prefix))
+		return
+	}
+
+	adminApp := fiber.New(fiber.Config{DisableStartupMessage: true})
+	registerAdminRoutes(adminApp.Group(adminConfig.Prefix), adminConfig.Token)
+	go func() {
+		logger.Info("Serving admin API", zap.String("address", adminConfig.Listen), zap.String("prefix", adminConfig.Prefix))
+		if err := adminApp.Listen(adminConfig.Listen); err != nil {
+			logger.Error("Admin server stopped", zap.String("address", adminConfig.Listen), zap.Error(err))
+		}
+	}()
+}
+
+// registerAdminRoutes 注册管理 API 的所有端点，所有端点都需要 Bearer 令牌
+// registerAdminRoutes registers every admin API endpoint, all of them require the bearer token
+func registerAdminRoutes(router fiber.Router, token string) {
+	router.Use(adminAuth(token))
+	router.Get("/routes", adminListRoutes)
+	router.Post("/backends/:action", adminSetBackendState)
+	router.Post("/cache/purge", adminPurgeCache)
+
+	// 前缀下的未知路径不交给代理路由
+	// Unknown paths under the prefix are not passed on to the proxy routes
+	router.All("/*", func(c *fiber.Ctx) error {
+		return fiber.NewError(fiber.StatusNotFound, "Unknown admin endpoint")
+	})
+}
+
+// adminAuth 校验 Authorization 头中的 Bearer 令牌
+// adminAuth checks the bearer token in the Authorization header
+func adminAuth(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		provided, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			logger.Warn("Rejected unauthorized admin request", zap.String("path", c.Path()), zap.String("ip", clientIP(c)))
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="admin"`)
+			return fiber.NewError(fiber.StatusUnauthorized, "Unauthorized")
+		}
+		return c.Next()
+	}
+}
+
+// adminListRoutes 列出所有路由及其后端状态
+// adminListRoutes lists every route with its backend statuses
+func adminListRoutes(c *fiber.Ctx) error {
+	table := currentRouteTable.Load()
+	if table == nil {
+		return c.JSON(fiber.Map{"routes": []adminRoute{}})
+	}
+
+	loadBalancerMutex.RLock()
+	defer loadBalancerMutex.RUnlock()
+
+	routes := make([]adminRoute, 0, len(table.entries))
+	for _, entry := range table.entries {
+		route := adminRoute{
+			ID:           entry.route.ID(),
+			VHost:        entry.route.VHost,
+			Name:         entry.route.Name,
+			Path:         entry.route.Path,
+			LBStrategy:   entry.route.LBStrategy,
+			CacheEnabled: entry.route.CacheEnable && table.config.Cache.Enabled,
+			Backends:     []adminBackend{},
+		}
+		if route.LBStrategy == "" {
+			route.LBStrategy = config.LBStrategyRoundRobin
+		}
+		if balancer, exists := routeLoadBalancers[entry.route.ID()]; exists {
+			for _, status := range balancer.lb.GetBackendStatuses() {
+				route.Backends = append(route.Backends, newAdminBackend(status))
+			}
+		}
+		routes = append(routes, route)
+	}
+
+	return c.JSON(fiber.Map{"routes": routes})
+}
+
+// newAdminBackend 将后端状态转换为管理 API 的表示
+// newAdminBackend converts a backend status to its admin API representation
+func newAdminBackend(status loadbalancer.BackendStatus) adminBackend {
+	backend := adminBackend{
+		URL:             status.URL,
+		State:           status.AdminState,
+		Healthy:         status.Healthy,
+		Circuit:         status.CircuitState,
+		FailCount:       status.FailCount,
+		ResponseTimesMs: make([]float64, 0, len(status.ResponseTimes)),
+		ActiveRequests:  status.ActiveRequests,
+	}
+	if !status.LastFailTime.IsZero() {
+		backend.LastFailTime = &status.LastFailTime
+	}
+	for _, responseTime := range status.ResponseTimes {
+		backend.ResponseTimesMs = append(backend.ResponseTimesMs, float64(responseTime.Microseconds())/1000)
+	}
+	return backend
+}
+
+// adminSetBackendState 启用、排空或禁用一个后端。禁用时同时关闭连接到该后端的 WebSocket 连接
+// adminSetBackendState enables, drains or disables a backend. Disabling also closes the WebSocket connections to it
+func adminSetBackendState(c *fiber.Ctx) error {
+	state, ok := adminBackendActions[c.Params("action")]
+	if !ok {
+		return fiber.NewError(fiber.StatusNotFound, "Unknown backend action, use enable, drain or disable")
+	}
+
+	var body adminBackendRequest
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid JSON body: "+err.Error())
+	}
+
+	loadBalancerMutex.RLock()
+	balancer, exists := routeLoadBalancers[body.Route]
+	loadBalancerMutex.RUnlock()
+	if !exists {
+		return fiber.NewError(fiber.StatusNotFound, "Unknown route: "+body.Route)
+	}
+	if !balancer.lb.SetBackendAdminState(body.Backend, state) {
+		return fiber.NewError(fiber.StatusNotFound, "Unknown backend: "+body.Backend)
+	}
+
+	closed := 0
+	if state == loadbalancer.AdminStateDisabled {
+		closed = closeWebSocketConns(body.Route, body.Backend)
+	}
+	logger.Info("Backend state changed through admin API",
+		zap.String("route", body.Route),
+		zap.String("backend", body.Backend),
+		zap.String("state", state),
+		zap.Int("websocketsClosed", closed))
+
+	for _, status := range balancer.lb.GetBackendStatuses() {
+		if status.URL == body.Backend {
+			backend := newAdminBackend(status)
+			backend.WebSocketsClosed = closed
+			return c.JSON(backend)
+		}
+	}
+	return fiber.NewError(fiber.StatusNotFound, "Unknown backend: "+body.Backend)
+}
+
+// adminPurgeCache 通过 CacheManager.Delete 清除路由的缓存键
+// adminPurgeCache purges cache keys of a route through CacheManager.Delete
+func adminPurgeCache(c *fiber.Ctx) error {
+	var body adminPurgeRequest
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid JSON body: "+err.Error())
+	}
+
+	table := currentRouteTable.Load()
+	namespace := ""
+	if table != nil {
+		for _, entry := range table.entries {
+			if entry.route.ID() == body.Route {
+				namespace = cacheNamespace(entry.route)
+				break
+			}
+		}
+	}
+	if namespace == "" {
+		return fiber.NewError(fiber.StatusNotFound, "Unknown route: "+body.Route)
+	}
+
+	keys := make([]string, 0, len(body.Keys)+len(body.Requests))
+	for _, key := range body.Keys {
+		// 只允许清除属于该路由的缓存键
+		// Only keys belonging to the route may be purged
+		if !strings.HasPrefix(key, namespace+":") {
+			return fiber.NewError(fiber.StatusBadRequest, "Cache key does not belong to route "+body.Route+": "+key)
+		}
+		keys = append(keys, key)
+	}
+	for _, request := range body.Requests {
+		method := strings.ToUpper(request.Method)
+		if method == "" {
+			method = fiber.MethodGet
+		}
+		keys = append(keys, cacheKey(namespace, method, request.Path, []byte(request.Query), []byte(request.Body), request.Subject, request.Group))
+	}
+	if len(keys) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Nothing to purge, give keys or requests")
+	}
+
+	cm, releaseCache := acquireCacheManager()
+	defer releaseCache()
+	if cm == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "Cache is not enabled")
+	}
+
+	for _, key := range keys {
+		if err := cm.Delete(key); err != nil {
+			logger.Error("Failed to purge cache key", zap.String("route", body.Route), zap.String("key", key), zap.Error(err))
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to purge cache key "+key)
+		}
+	}
+	logger.Info("Cache keys purged through admin API", zap.String("route", body.Route), zap.Strings("keys", keys))
+
+	return c.JSON(fiber.Map{"route": body.Route, "purged": keys})
+}
@@ -0,0 +1,85 @@
+package router
+
+import (
+	"crypto/sha256"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"go.uber.org/zap"
+)
+
+// 请求上下文中保存已认证 API 密钥名称的键
+// Locals key holding the name of the authenticated API key
+const localsAPIKeyName = "apiKeyName"
+
+// apiKeyStore 按密钥摘要索引的 API 密钥，避免逐个比较密钥
+// apiKeyStore indexes API keys by their digest so keys are not compared one by one
+type apiKeyStore struct {
+	keys map[[sha256.Size]byte]config.APIKey
+}
+
+// newAPIKeyStore 创建 API 密钥索引
+// newAPIKeyStore creates the API key index
+func newAPIKeyStore(keys []config.APIKey) *apiKeyStore {
+	store := &apiKeyStore{keys: make(map[[sha256.Size]byte]config.APIKey, len(keys))}
+	for _, key := range keys {
+		store.keys[sha256.Sum256([]byte(key.Key))] = key
+	}
+	return store
+}
+
+// lookup 查找客户端发送的密钥
+// lookup finds the key sent by a client
+func (s *apiKeyStore) lookup(key string) (config.APIKey, bool) {
+	apiKey, exists := s.keys[sha256.Sum256([]byte(key))]
+	return apiKey, exists
+}
+
+// requireAPIKey 要求请求携带允许访问该路由的 API 密钥，并将密钥名称通过身份请求头传递给后端。
+// 除非配置了 forward_key，否则转发前移除密钥
+// requireAPIKey requires requests to carry an API key allowed on the route and passes the key name to the backend in
+// the identity header. The key is removed before forwarding unless forward_key is set
+func requireAPIKey(route config.Route, store *apiKeyStore, next fiber.Handler) fiber.Handler {
+	if !route.APIKeyAuth.Enabled {
+		return next
+	}
+	auth := route.APIKeyAuth.WithDefaults()
+
+	return func(c *fiber.Ctx) error {
+		key := c.Get(auth.Header)
+		if key == "" && auth.QueryParam != "" {
+			key = c.Query(auth.QueryParam)
+		}
+		if key == "" {
+			return fiber.NewError(fiber.StatusUnauthorized, "API key required")
+		}
+
+		apiKey, exists := store.lookup(key)
+		if !exists {
+			logger.Warn("Rejected request with an unknown API key", zap.String("path", route.Path), zap.String("ip", clientIP(c)))
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid API key")
+		}
+		if !apiKey.AllowsRoute(route) {
+			logger.Warn("Rejected API key not allowed on route", zap.String("path", route.Path), zap.String("key", apiKey.Name))
+			return fiber.NewError(fiber.StatusForbidden, "API key not allowed on this route")
+		}
+
+		if !auth.ForwardKey {
+			c.Request().Header.Del(auth.Header)
+			if auth.QueryParam != "" {
+				args := c.Request().URI().QueryArgs()
+				if args.Has(auth.QueryParam) {
+					args.Del(auth.QueryParam)
+					c.Request().URI().SetQueryStringBytes(args.QueryString())
+				}
+			}
+		}
+
+		// 覆盖客户端可能伪造的身份请求头
+		// Overwrite an identity header the client may have forged
+		c.Request().Header.Set(auth.IdentityHeader, apiKey.Name)
+		c.Locals(localsAPIKeyName, apiKey.Name)
+
+		return next(c)
+	}
+}
@@ -0,0 +1,98 @@
+package router
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/loadbalancer"
+	"go.uber.org/zap"
+)
+
+// newLoadBalancer 根据路由的 lb_strategy 创建负载均衡器
+// newLoadBalancer creates a load balancer according to the route's lb_strategy
+func newLoadBalancer(route config.Route) loadbalancer.LoadBalancer {
+	// 每个后端分组使用相同策略的独立负载均衡器
+	// Each backend group gets its own load balancer with the same strategy
+	if len(route.BackendGroups) > 0 {
+		names := make([]string, 0, len(route.BackendGroups))
+		groups := make([]loadbalancer.LoadBalancer, 0, len(route.BackendGroups))
+		for _, group := range route.BackendGroups {
+			groupRoute := route
+			groupRoute.Backends = group.Backends
+			groupRoute.BackendGroups = nil
+			names = append(names, group.Name)
+			groups = append(groups, newLoadBalancer(groupRoute))
+		}
+		return loadbalancer.NewGroupLoadBalancer(names, groups)
+	}
+
+	switch route.LBStrategy {
+	case config.LBStrategyWeightedRoundRobin:
+		return loadbalancer.NewWeightedRoundRobinLoadBalancer(route.Backends, route.BackendWeights)
+	case config.LBStrategyLeastConnections:
+		return loadbalancer.NewLeastConnectionsLoadBalancer(route.Backends)
+	case config.LBStrategyLeastResponseTime:
+		return loadbalancer.NewLeastResponseTimeLoadBalancer(route.Backends)
+	case config.LBStrategyRandomTwoChoices:
+		return loadbalancer.NewRandomTwoChoicesLoadBalancer(route.Backends)
+	case config.LBStrategyConsistentHash:
+		return loadbalancer.NewConsistentHashLoadBalancer(route.Backends)
+	default:
+		return loadbalancer.NewRoundRobinLoadBalancer(route.Backends)
+	}
+}
+
+// nextBackend 从负载均衡器选择一个不在 tried 中的后端。使用后端分组时从请求分配到的分组中选择，
+// 该分组没有可用后端时按配置顺序换用其他分组
+// nextBackend picks a backend not in tried from the load balancer. With backend groups it picks from the group the
+// request is assigned to, and switches to the other groups in config order when that group has no available backend
+func nextBackend(c *fiber.Ctx, lb loadbalancer.LoadBalancer, route config.Route, tried []string) string {
+	groups, ok := lb.(*loadbalancer.GroupLoadBalancer)
+	if !ok {
+		return pickBackend(c, lb, route, tried)
+	}
+
+	assigned := backendGroup(c, route)
+	if group := groups.Group(assigned); group != nil {
+		if backend := pickBackend(c, group, route, tried); backend != "" {
+			return backend
+		}
+	}
+	for _, name := range groups.Names() {
+		if name == assigned {
+			continue
+		}
+		if backend := pickBackend(c, groups.Group(name), route, tried); backend != "" {
+			logger.Warn("Backend group has no available backend, using another group",
+				zap.String("route", route.ID()),
+				zap.String("group", assigned),
+				zap.String("fallback", name))
+			c.Locals(localsBackendGroup, name)
+			return backend
+		}
+	}
+	return ""
+}
+
+// pickBackend 从负载均衡器选择一个不在 tried 中的后端，一致性哈希负载均衡器使用请求键
+// pickBackend picks a backend not in tried from the load balancer, using the request key for consistent-hash load balancers
+func pickBackend(c *fiber.Ctx, lb loadbalancer.LoadBalancer, route config.Route, tried []string) string {
+	if keyed, ok := lb.(loadbalancer.KeyedLoadBalancer); ok {
+		return keyed.NextBackendForKey(balancerKey(c, route.LBHashKey), tried)
+	}
+	return lb.NextBackendExcluding(tried)
+}
+
+// balancerKey 根据 lb_hash_key 从请求中取出哈希键，默认使用客户端IP
+// balancerKey extracts the hash key from the request according to lb_hash_key, the client IP by default
+func balancerKey(c *fiber.Ctx, hashKey string) string {
+	switch {
+	case strings.HasPrefix(hashKey, config.LBHashKeyHeaderPrefix):
+		return c.Get(strings.TrimPrefix(hashKey, config.LBHashKeyHeaderPrefix))
+	case strings.HasPrefix(hashKey, config.LBHashKeyCookiePrefix):
+		return c.Cookies(strings.TrimPrefix(hashKey, config.LBHashKeyCookiePrefix))
+	default:
+		return clientIP(c)
+	}
+}
@@ -0,0 +1,80 @@
+package router
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/htpasswd"
+	"go.uber.org/zap"
+)
+
+// 请求上下文中保存 Basic 认证用户名的键
+// Locals key holding the user name of basic authentication
+const localsBasicAuthUser = "basicAuthUser"
+
+// requireBasicAuth 要求请求携带 htpasswd 文件中的用户名和密码。htpasswd 文件在路由表构建时读取，文件变化时随配置重新加载。
+// 文件无法读取时拒绝所有请求
+// requireBasicAuth requires requests to carry a user name and password from the htpasswd file. The htpasswd file is
+// read when the route table is built and reloaded with the config when it changes. All requests are rejected when the
+// file cannot be read
+func requireBasicAuth(route config.Route, next fiber.Handler) fiber.Handler {
+	if !route.BasicAuth.Enabled {
+		return next
+	}
+	auth := route.BasicAuth.WithDefaults()
+
+	file, err := htpasswd.Load(auth.HtpasswdFile)
+	if err != nil {
+		logger.Error("Failed to load htpasswd file, rejecting requests on route", zap.String("path", route.Path), zap.Error(err))
+		return func(c *fiber.Ctx) error {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "Authentication unavailable")
+		}
+	}
+	challenge := `Basic realm="` + auth.Realm + `", charset="UTF-8"`
+
+	return func(c *fiber.Ctx) error {
+		user, password, ok := basicCredentials(c.Get(fiber.HeaderAuthorization))
+		if !ok {
+			c.Set(fiber.HeaderWWWAuthenticate, challenge)
+			return fiber.NewError(fiber.StatusUnauthorized, "Unauthorized")
+		}
+
+		if !file.Verify(user, password) {
+			logger.Warn("Rejected request with invalid basic auth credentials",
+				zap.String("path", route.Path),
+				zap.String("user", user),
+				zap.String("ip", clientIP(c)))
+			c.Set(fiber.HeaderWWWAuthenticate, challenge)
+			return fiber.NewError(fiber.StatusUnauthorized, "Unauthorized")
+		}
+
+		if auth.StripAuthorization {
+			c.Request().Header.Del(fiber.HeaderAuthorization)
+		}
+		if auth.UserHeader != "" {
+			// 覆盖客户端可能伪造的用户名请求头
+			// Overwrite a user header the client may have forged
+			c.Request().Header.Set(auth.UserHeader, user)
+		}
+		c.Locals(localsBasicAuthUser, user)
+
+		return next(c)
+	}
+}
+
+// basicCredentials 解析 Basic 方案的 Authorization 请求头
+// basicCredentials parses an Authorization header of the Basic scheme
+func basicCredentials(value string) (user, password string, ok bool) {
+	const scheme = "basic "
+	if len(value) <= len(scheme) || !strings.EqualFold(value[:len(scheme)], scheme) {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[len(scheme):]))
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
@@ -0,0 +1,129 @@
+package router
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"go.uber.org/zap"
+)
+
+// 请求上下文中保存客户端证书主题的键
+// Locals key holding the subject of the client certificate
+const localsClientCertSubject = "clientCertSubject"
+
+// requireClientCert 要求请求通过 TLS 连接携带由路由 CA 签发的客户端证书，并检查主题和 SAN 白名单。
+// TLS 监听器在需要时请求客户端证书，证书在这里按路由验证，因此不同路由可以使用不同的 CA
+// requireClientCert requires requests to arrive over a TLS connection with a client certificate issued by the CA of the
+// route, and checks the subject and SAN allowlists. The TLS listener requests client certificates when needed and they
+// are verified here per route, so routes can use different CAs
+func requireClientCert(route config.Route, next fiber.Handler) fiber.Handler {
+	if !route.ClientCert.Enabled {
+		return next
+	}
+	auth := route.ClientCert.WithDefaults()
+
+	roots, err := auth.LoadCAPool()
+	if err != nil {
+		logger.Error("Failed to load client CA file, rejecting requests on route", zap.String("path", route.Path), zap.Error(err))
+		return func(c *fiber.Ctx) error {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "Authentication unavailable")
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		state := c.Context().TLSConnectionState()
+		if state == nil || len(state.PeerCertificates) == 0 {
+			return fiber.NewError(fiber.StatusUnauthorized, "Client certificate required")
+		}
+
+		certificate := state.PeerCertificates[0]
+		intermediates := x509.NewCertPool()
+		for _, intermediate := range state.PeerCertificates[1:] {
+			intermediates.AddCert(intermediate)
+		}
+		if _, err := certificate.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}); err != nil {
+			logger.Warn("Rejected request with an untrusted client certificate",
+				zap.String("path", route.Path),
+				zap.String("subject", certificate.Subject.String()),
+				zap.String("ip", clientIP(c)),
+				zap.Error(err))
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid client certificate")
+		}
+
+		subject := certificate.Subject.String()
+		if !clientCertAllowed(certificate, auth) {
+			logger.Warn("Rejected client certificate not in the allowlist",
+				zap.String("path", route.Path),
+				zap.String("subject", subject),
+				zap.String("ip", clientIP(c)))
+			return fiber.NewError(fiber.StatusForbidden, "Forbidden")
+		}
+
+		// 覆盖客户端可能伪造的身份请求头
+		// Overwrite identity headers the client may have forged
+		fingerprint := sha256.Sum256(certificate.Raw)
+		c.Request().Header.Set(auth.SubjectHeader, subject)
+		c.Request().Header.Set(auth.FingerprintHeader, hex.EncodeToString(fingerprint[:]))
+		c.Locals(localsClientCertSubject, subject)
+
+		return next(c)
+	}
+}
+
+// clientCertAllowed 判断证书是否匹配主题或 SAN 白名单。两个白名单都为空时允许任意已验证的证书
+// clientCertAllowed reports whether the certificate matches the subject or SAN allowlist. Any verified certificate is
+// allowed when both allowlists are empty
+func clientCertAllowed(certificate *x509.Certificate, auth config.ClientCertAuth) bool {
+	if len(auth.AllowedSubjects) == 0 && len(auth.AllowedSANs) == 0 {
+		return true
+	}
+
+	if slices.Contains(auth.AllowedSubjects, certificate.Subject.CommonName) ||
+		slices.Contains(auth.AllowedSubjects, certificate.Subject.String()) {
+		return true
+	}
+
+	for _, allowed := range auth.AllowedSANs {
+		for _, name := range certificate.DNSNames {
+			if matchDNSName(allowed, name) {
+				return true
+			}
+		}
+		for _, email := range certificate.EmailAddresses {
+			if strings.EqualFold(allowed, email) {
+				return true
+			}
+		}
+		for _, uri := range certificate.URIs {
+			if allowed == uri.String() {
+				return true
+			}
+		}
+		for _, ip := range certificate.IPAddresses {
+			if allowed == ip.String() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchDNSName 匹配 DNS 名称，"*.example.com" 匹配 example.com 下一级的任意名称
+// matchDNSName matches a DNS name, "*.example.com" matches any name one label below example.com
+func matchDNSName(pattern, name string) bool {
+	suffix, wildcard := strings.CutPrefix(pattern, "*")
+	if !wildcard {
+		return strings.EqualFold(pattern, name)
+	}
+	label, found := strings.CutSuffix(strings.ToLower(name), strings.ToLower(suffix))
+	return found && label != "" && !strings.Contains(label, ".")
+}
@@ -0,0 +1,235 @@
+package router
+
+import (
+	"net/netip"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+// 请求上下文中保存真实客户端 IP 的键
+// Locals key holding the real client IP
+const localsClientIP = "clientIP"
+
+// 转发请求头
+// Forwarding headers
+const (
+	headerForwarded       = "Forwarded"
+	headerXForwardedFor   = "X-Forwarded-For"
+	headerXForwardedProto = "X-Forwarded-Proto"
+	headerXForwardedHost  = "X-Forwarded-Host"
+)
+
+// forwardedSettings 当前的受信任代理和转发请求头格式
+// forwardedSettings holds the current trusted proxies and forwarding header format
+type forwardedSettings struct {
+	trusted []netip.Prefix
+	header  string
+}
+
+// 当前的转发设置，热重载时替换
+// Current forwarding settings, replaced on hot reload
+var currentForwarded atomic.Pointer[forwardedSettings]
+
+// syncForwarded 替换当前的转发设置，受信任的代理无效时保留当前设置
+// syncForwarded replaces the current forwarding settings, keeping the current ones when the trusted proxies are invalid
+func syncForwarded(forwarded config.Forwarded) {
+	forwarded = forwarded.WithDefaults()
+	trusted, err := forwarded.ParseTrustedProxies()
+	if err != nil {
+		logger.Error("Failed to parse trusted proxies, keeping the current ones", zap.Error(err))
+		return
+	}
+	currentForwarded.Store(&forwardedSettings{trusted: trusted, header: forwarded.Header})
+}
+
+// isTrustedProxy 判断地址是否属于受信任的代理
+// isTrustedProxy reports whether the address belongs to a trusted proxy
+func (s *forwardedSettings) isTrustedProxy(addr netip.Addr) bool {
+	if s == nil {
+		return false
+	}
+	for _, prefix := range s.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerAddr 返回直接连接网关的地址
+// peerAddr returns the address of the peer directly connected to the gateway
+func peerAddr(c *fiber.Ctx) netip.Addr {
+	addr, _ := netip.AddrFromSlice(c.Context().RemoteIP())
+	return addr.Unmap()
+}
+
+// clientIP 返回真实的客户端 IP。对端是受信任的代理时，从右向左查找转发链中第一个不受信任的地址；
+// 链中所有地址都受信任时使用最左边的地址。结果在请求中缓存
+// clientIP returns the real client IP. When the peer is a trusted proxy, the forwarding chain is walked from right to
+// left up to the first untrusted address; when every address of the chain is trusted the leftmost one is used. The
+// result is cached for the request
+func clientIP(c *fiber.Ctx) string {
+	if ip, ok := c.Locals(localsClientIP).(string); ok {
+		return ip
+	}
+
+	settings := currentForwarded.Load()
+	client := peerAddr(c)
+	if settings.isTrustedProxy(client) {
+		chain := forwardedChain(&c.Request().Header)
+		for i := len(chain) - 1; i >= 0; i-- {
+			// 无法解析的节点（例如 unknown 或混淆标识）终止查找
+			// Nodes that cannot be parsed, such as unknown or obfuscated identifiers, stop the walk
+			addr, ok := parseForwardedNode(chain[i])
+			if !ok {
+				break
+			}
+			client = addr
+			if !settings.isTrustedProxy(addr) {
+				break
+			}
+		}
+	}
+
+	ip := client.String()
+	c.Locals(localsClientIP, ip)
+	return ip
+}
+
+// forwardedChain 返回转发请求头中的节点，优先使用 Forwarded 的 for 参数，否则使用 X-Forwarded-For
+// forwardedChain returns the nodes of the forwarding headers, the for parameters of Forwarded if present or else
+// X-Forwarded-For
+func forwardedChain(header *fasthttp.RequestHeader) []string {
+	var chain []string
+	for _, value := range header.PeekAll(headerForwarded) {
+		for _, element := range strings.Split(string(value), ",") {
+			for _, pair := range strings.Split(element, ";") {
+				name, node, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(name, "for") {
+					chain = append(chain, strings.Trim(node, `"`))
+				}
+			}
+		}
+	}
+	if len(chain) > 0 {
+		return chain
+	}
+
+	for _, value := range header.PeekAll(headerXForwardedFor) {
+		for _, node := range strings.Split(string(value), ",") {
+			if node = strings.TrimSpace(node); node != "" {
+				chain = append(chain, node)
+			}
+		}
+	}
+	return chain
+}
+
+// parseForwardedNode 解析转发节点中的 IP，节点可以带端口，IPv6 地址可以带方括号
+// parseForwardedNode parses the IP of a forwarding node, which may carry a port and brackets around IPv6 addresses
+func parseForwardedNode(node string) (netip.Addr, bool) {
+	if addrPort, err := netip.ParseAddrPort(node); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(strings.Trim(node, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// setForwardedHeaders 设置发送给后端的转发请求头。只有对端是受信任的代理时才保留客户端发送的转发请求头并在其后追加，
+// 否则丢弃它们，以免客户端伪造来源
+// setForwardedHeaders sets the forwarding headers sent to the backend. The forwarding headers sent by the client are
+// only kept and appended to when the peer is a trusted proxy, otherwise they are dropped so clients cannot forge their
+// origin
+func setForwardedHeaders(c *fiber.Ctx, req *fasthttp.Request) {
+	settings := currentForwarded.Load()
+	peer := peerAddr(c)
+	trusted := settings.isTrustedProxy(peer)
+
+	// fiber 的 Protocol 和 Hostname 会无条件信任转发请求头，这里只使用连接本身
+	// fiber's Protocol and Hostname trust forwarding headers unconditionally, only the connection itself is used here
+	proto, host := requestScheme(c), string(c.Request().Host())
+	var chain []string
+	incomingForwarded := joinHeaderValues(c.Request().Header.PeekAll(headerForwarded))
+	if trusted {
+		chain = forwardedChain(&c.Request().Header)
+		if value := c.Get(headerXForwardedProto); value != "" {
+			proto = value
+		}
+		if value := c.Get(headerXForwardedHost); value != "" {
+			host = value
+		}
+	} else {
+		incomingForwarded = ""
+		for _, name := range []string{headerForwarded, headerXForwardedFor, headerXForwardedProto, headerXForwardedHost} {
+			req.Header.Del(name)
+		}
+	}
+
+	if settings != nil && settings.header == config.ForwardedHeaderRFC7239 {
+		elements := make([]string, 0, len(chain)+1)
+		if incomingForwarded != "" {
+			elements = append(elements, incomingForwarded)
+		} else {
+			for _, node := range chain {
+				elements = append(elements, "for="+forwardedNode(node))
+			}
+		}
+		elements = append(elements, "for="+forwardedNode(peer.String())+";proto="+requestScheme(c)+";host="+quoteForwarded(string(c.Request().Host())))
+		req.Header.Set(headerForwarded, strings.Join(elements, ", "))
+		return
+	}
+
+	for i, node := range chain {
+		if addr, ok := parseForwardedNode(node); ok {
+			chain[i] = addr.String()
+		}
+	}
+	req.Header.Set(headerXForwardedFor, strings.Join(append(chain, peer.String()), ", "))
+	req.Header.Set(headerXForwardedProto, proto)
+	req.Header.Set(headerXForwardedHost, host)
+}
+
+// requestScheme 返回客户端连接网关使用的协议
+// requestScheme returns the scheme the client used to connect to the gateway
+func requestScheme(c *fiber.Ctx) string {
+	if c.Context().IsTLS() {
+		return "https"
+	}
+	return "http"
+}
+
+// joinHeaderValues 用逗号连接同名请求头的多个值
+// joinHeaderValues joins the values of a repeated header with commas
+func joinHeaderValues(values [][]byte) string {
+	joined := make([]string, len(values))
+	for i, value := range values {
+		joined[i] = string(value)
+	}
+	return strings.Join(joined, ", ")
+}
+
+// forwardedNode 将节点格式化为 Forwarded 的 for 参数，IPv6 地址需要方括号和引号
+// forwardedNode formats a node as the for parameter of Forwarded, IPv6 addresses need brackets and quotes
+func forwardedNode(node string) string {
+	if addr, ok := parseForwardedNode(node); ok && addr.Is6() && !strings.HasPrefix(node, "[") {
+		node = "[" + addr.String() + "]"
+	}
+	return quoteForwarded(node)
+}
+
+// quoteForwarded 在值不是合法的 token 时加上引号
+// quoteForwarded quotes the value when it is not a valid token
+func quoteForwarded(value string) string {
+	if value != "" && !strings.ContainsAny(value, `:[]"(),/;<=>?@\{} `) {
+		return value
+	}
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
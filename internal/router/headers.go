@@ -0,0 +1,135 @@
+package router
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+)
+
+// 请求上下文中保存请求 ID 和所选后端的键
+// Locals keys holding the request ID and the selected backend
+const (
+	localsRequestID = "requestID"
+	localsBackend   = "backend"
+)
+
+// headerEditor 请求头和响应头共同的操作
+// headerEditor holds the operations shared by request and response headers
+type headerEditor interface {
+	Set(key, value string)
+	Add(key, value string)
+	Del(key string)
+	PeekAll(key string) [][]byte
+}
+
+// applyHeaderOps 依次执行 remove、rename、set、add，set 和 add 的值按请求展开模板
+// applyHeaderOps applies remove, rename, set and add in this order, expanding the templates of set and add for the
+// request
+func applyHeaderOps(c *fiber.Ctx, route config.Route, header headerEditor, ops config.HeaderOps) {
+	for _, name := range ops.Remove {
+		header.Del(name)
+	}
+
+	for from, to := range ops.Rename {
+		values := header.PeekAll(from)
+		if len(values) == 0 {
+			continue
+		}
+		// PeekAll 返回的切片在修改请求头后失效
+		// The slices returned by PeekAll are invalidated by modifying the header
+		copied := make([]string, len(values))
+		for i, value := range values {
+			copied[i] = string(value)
+		}
+		header.Del(from)
+		header.Del(to)
+		for _, value := range copied {
+			header.Add(to, value)
+		}
+	}
+
+	lookup := headerVariables(c, route)
+	for name, value := range ops.Set {
+		header.Set(name, config.ExpandHeaderTemplate(value, lookup))
+	}
+	for name, value := range ops.Add {
+		header.Add(name, config.ExpandHeaderTemplate(value, lookup))
+	}
+}
+
+// applyResponseHeaderRules 将路由的响应头规则应用到发送给客户端的响应
+// applyResponseHeaderRules applies the response header rules of the route to the response sent to the client
+func applyResponseHeaderRules(c *fiber.Ctx, route config.Route) {
+	applyHeaderOps(c, route, &c.Response().Header, route.Headers.Response)
+}
+
+// headerVariables 返回请求头模板变量的查找函数
+// headerVariables returns the lookup function of the header template variables
+func headerVariables(c *fiber.Ctx, route config.Route) func(name string) (string, bool) {
+	return func(name string) (string, bool) {
+		switch name {
+		case config.HeaderVarClientIP:
+			return clientIP(c), true
+		case config.HeaderVarRequestID:
+			return requestID(c), true
+		case config.HeaderVarRoute:
+			return route.ID(), true
+		case config.HeaderVarBackend:
+			backend, _ := c.Locals(localsBackend).(string)
+			return backend, true
+		case config.HeaderVarGroup:
+			group, _ := c.Locals(localsBackendGroup).(string)
+			return group, true
+		}
+		return "", false
+	}
+}
+
+// requestID 返回请求 ID：优先使用客户端发送的 X-Request-ID，否则生成一个随机 ID，同一请求中保持不变
+// requestID returns the request ID: the X-Request-ID sent by the client, or else a random ID that stays the same for
+// the whole request
+func requestID(c *fiber.Ctx) string {
+	if id, ok := c.Locals(localsRequestID).(string); ok {
+		return id
+	}
+
+	id := c.Get(fiber.HeaderXRequestID)
+	if id == "" {
+		var b [16]byte
+		rand.Read(b[:])
+		id = hex.EncodeToString(b[:])
+	}
+	c.Locals(localsRequestID, id)
+	return id
+}
+
+// connectionTokens 返回 Connection 请求头列出的请求头名称，这些请求头同样是逐跳的
+// connectionTokens returns the header names listed in the Connection header, which are hop-by-hop as well
+func connectionTokens(values [][]byte) []string {
+	var tokens []string
+	for _, value := range values {
+		for _, token := range strings.Split(string(value), ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+// isHopByHop 判断请求头是否为逐跳请求头或在 Connection 中列出
+// isHopByHop reports whether the header is hop-by-hop or listed in Connection
+func isHopByHop(name string, tokens []string) bool {
+	if config.IsHopByHopHeader(name) {
+		return true
+	}
+	for _, token := range tokens {
+		if strings.EqualFold(name, token) {
+			return true
+		}
+	}
+	return false
+}
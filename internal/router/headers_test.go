@@ -0,0 +1,34 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHeaderVariableRoute(t *testing.T) {
+	tests := []struct {
+		name  string
+		route config.Route
+		want  string
+	}{
+		{name: "path", route: config.Route{Path: "/users"}, want: "/users"},
+		{name: "name", route: config.Route{Path: "/users", Name: "users"}, want: "users"},
+		{name: "vhost", route: config.Route{Path: "/users", Name: "users", VHost: "api"}, want: "api:users"},
+	}
+
+	app := fiber.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := app.AcquireCtx(&fasthttp.RequestCtx{})
+			defer app.ReleaseCtx(c)
+
+			got, ok := headerVariables(c, tt.route)(config.HeaderVarRoute)
+			if !ok || got != tt.want {
+				t.Fatalf("{route} = %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/jwtauth"
+	"go.uber.org/zap"
+)
+
+// 请求上下文中保存 JWT sub 声明的键
+// Locals key holding the sub claim of the JWT
+const localsJWTSubject = "jwtSubject"
+
+// newJWTVerifier 读取路由的密钥并创建令牌验证器。cache_by_subject 要求令牌带有 sub，避免没有 sub 的请求共享缓存
+// newJWTVerifier loads the route's keys and creates the token verifier. cache_by_subject requires tokens to carry
+// sub, so requests without it do not share cache entries
+func newJWTVerifier(auth config.JWTAuth) (*jwtauth.Verifier, error) {
+	keys, err := jwtauth.LoadKeys(auth.KeySources())
+	if err != nil {
+		return nil, err
+	}
+
+	requiredClaims := auth.RequiredClaims
+	if auth.CacheBySubject {
+		requiredClaims = append([]string{"sub"}, requiredClaims...)
+	}
+
+	return jwtauth.NewVerifier(jwtauth.Config{
+		Algorithms:     auth.Algorithms,
+		Issuer:         auth.Issuer,
+		Audience:       auth.Audience,
+		RequiredClaims: requiredClaims,
+		Leeway:         auth.Leeway,
+	}, keys), nil
+}
+
+// requireJWT 要求请求携带有效的 JWT，并将选定的声明通过请求头传递给后端。密钥无法读取时拒绝所有请求
+// requireJWT requires requests to carry a valid JWT and passes the selected claims to the backend in request headers.
+// All requests are rejected when the keys cannot be loaded
+func requireJWT(route config.Route, next fiber.Handler) fiber.Handler {
+	if !route.JWT.Enabled {
+		return next
+	}
+	auth := route.JWT.WithDefaults()
+
+	verifier, err := newJWTVerifier(auth)
+	if err != nil {
+		logger.Error("Failed to load JWT keys, rejecting requests on route", zap.String("path", route.Path), zap.Error(err))
+		return func(c *fiber.Ctx) error {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "Authentication unavailable")
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		token := bearerToken(c.Get(auth.Header), strings.EqualFold(auth.Header, fiber.HeaderAuthorization))
+		if token == "" {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			return fiber.NewError(fiber.StatusUnauthorized, "Bearer token required")
+		}
+
+		claims, err := verifier.Verify(token, time.Now())
+		if err != nil {
+			logger.Warn("Rejected request with an invalid JWT",
+				zap.String("path", route.Path),
+				zap.String("ip", clientIP(c)),
+				zap.Error(err))
+			c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf(`Bearer error="invalid_token", error_description=%q`, jwtErrorDescription(err)))
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid token")
+		}
+
+		if auth.StripToken {
+			c.Request().Header.Del(auth.Header)
+		}
+
+		// 先移除客户端可能伪造的声明请求头，再写入令牌中的值
+		// Remove claim headers the client may have forged before writing the values from the token
+		for claim, header := range auth.ClaimHeaders {
+			c.Request().Header.Del(header)
+			if value, ok := claims.String(claim); ok {
+				c.Request().Header.Set(header, value)
+			}
+		}
+		c.Locals(localsJWTSubject, claims.Subject())
+
+		return next(c)
+	}
+}
+
+// bearerToken 从请求头取出令牌。Authorization 请求头必须使用 Bearer 方案，自定义请求头可以直接携带令牌
+// bearerToken extracts the token from a header value. The Authorization header must use the Bearer scheme, custom
+// headers may carry the bare token
+func bearerToken(value string, requireScheme bool) string {
+	const scheme = "bearer "
+	if len(value) > len(scheme) && strings.EqualFold(value[:len(scheme)], scheme) {
+		return strings.TrimSpace(value[len(scheme):])
+	}
+	if requireScheme {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
+// jwtErrorDescription 返回不泄露密钥信息的错误描述
+// jwtErrorDescription returns an error description that reveals nothing about the keys
+func jwtErrorDescription(err error) string {
+	switch {
+	case errors.Is(err, jwtauth.ErrExpired):
+		return "token is expired"
+	case errors.Is(err, jwtauth.ErrNotYetValid):
+		return "token is not valid yet"
+	case errors.Is(err, jwtauth.ErrIssuer), errors.Is(err, jwtauth.ErrAudience), errors.Is(err, jwtauth.ErrMissingClaim):
+		return "token claims are not accepted"
+	default:
+		return "token is invalid"
+	}
+}
@@ -0,0 +1,74 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/loadbalancer"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// instrumentRoute 记录路由处理的请求数、状态码类别和耗时
+// instrumentRoute records request counts, status classes and latency of a route
+func instrumentRoute(routeID string, next fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		startTime := time.Now()
+		err := next(c)
+
+		statusCode := c.Response().StatusCode()
+		if err != nil {
+			statusCode = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				statusCode = fiberErr.Code
+			}
+		}
+		metrics.ObserveRequest(routeID, statusCode, time.Since(startTime))
+
+		return err
+	}
+}
+
+// backendStatuses 返回每个路由的后端状态，用于导出后端健康指标
+// backendStatuses returns the backend statuses of every route for the backend health metrics
+func backendStatuses() map[string][]loadbalancer.BackendStatus {
+	loadBalancerMutex.RLock()
+	defer loadBalancerMutex.RUnlock()
+
+	result := make(map[string][]loadbalancer.BackendStatus, len(routeLoadBalancers))
+	for path, balancer := range routeLoadBalancers {
+		result[path] = balancer.lb.GetBackendStatuses()
+	}
+	return result
+}
+
+// serveMetrics 在网关端口或单独的监听地址上暴露 Prometheus 指标
+// serveMetrics exposes Prometheus metrics on the gateway port or on a separate listen address
+func serveMetrics(app *fiber.App, metricsConfig config.Metrics) {
+	if !metricsConfig.Enabled {
+		return
+	}
+	metricsConfig = metricsConfig.WithDefaults()
+
+	metrics.RegisterBackendSource(backendStatuses)
+
+	if metricsConfig.Listen == "" {
+		app.Get(metricsConfig.Path, adaptor.HTTPHandler(metrics.Handler()))
+		logger.Info("Serving metrics on the gateway port", zap.String("path", metricsConfig.Path))
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsConfig.Path, metrics.Handler())
+	go func() {
+		logger.Info("Serving metrics", zap.String("address", metricsConfig.Listen), zap.String("path", metricsConfig.Path))
+		if err := http.ListenAndServe(metricsConfig.Listen, mux); err != nil {
+			logger.Error("Metrics server stopped", zap.String("address", metricsConfig.Listen), zap.Error(err))
+		}
+	}()
+}
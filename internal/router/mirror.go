@@ -0,0 +1,111 @@
+package router
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/metrics"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+// mirror 将路由的部分请求异步复制到镜像后端，镜像响应被丢弃，不影响客户端响应和主后端的负载均衡器
+// mirror asynchronously copies part of a route's requests to the mirror backend, mirror responses are discarded and
+// affect neither the client response nor the load balancer of the primary backends
+type mirror struct {
+	routeID  string
+	config   config.Mirror
+	client   *fasthttp.Client
+	inFlight atomic.Int64
+}
+
+// newMirror 创建路由的流量镜像，路由没有启用镜像时返回 nil
+// newMirror creates the traffic mirror of a route, nil when the route does not mirror traffic
+func newMirror(route config.Route) *mirror {
+	if !route.Mirror.Enabled {
+		return nil
+	}
+
+	tlsConfig, err := route.UpstreamTLS.ClientConfig()
+	if err != nil {
+		logger.Error("Failed to load upstream TLS config for the mirror backend, using the default settings",
+			zap.String("route", route.ID()),
+			zap.Error(err))
+	}
+
+	return &mirror{
+		routeID: route.ID(),
+		config:  route.Mirror.WithDefaults(),
+		client: &fasthttp.Client{
+			TLSConfig:                 tlsConfig,
+			Dial:                      backendDialer(route.Timeouts),
+			MaxIdemponentCallAttempts: 1,
+		},
+	}
+}
+
+// send 按比例抽样，将请求副本发送到镜像后端。副本在返回前复制完毕，发送在后台进行；
+// 流式请求体无法复制，进行中的镜像请求过多时副本被丢弃
+// send samples the request by percentage and sends a copy to the mirror backend. The copy is made before returning and
+// sent in the background; streamed request bodies cannot be copied, and copies are dropped while too many mirrored
+// requests are in flight
+func (m *mirror) send(c *fiber.Ctx, route config.Route) {
+	if m == nil || rand.Float64()*100 >= *m.config.Percentage {
+		return
+	}
+
+	if c.Request().IsBodyStream() {
+		m.drop("streamed request body")
+		return
+	}
+	if m.inFlight.Add(1) > int64(m.config.MaxInFlight) {
+		m.inFlight.Add(-1)
+		m.drop("too many mirrored requests in flight")
+		return
+	}
+
+	targetFullURL, err := buildTargetURL(c, m.config.Backend, route)
+	if err != nil {
+		m.inFlight.Add(-1)
+		m.drop(err.Error())
+		return
+	}
+
+	req := fasthttp.AcquireRequest()
+	prepareProxyRequest(c, req, targetFullURL, route)
+	if len(c.Body()) > 0 {
+		req.SetBody(c.Body())
+	}
+
+	go func() {
+		defer m.inFlight.Add(-1)
+		defer fasthttp.ReleaseRequest(req)
+
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+
+		startTime := time.Now()
+		err := m.client.DoTimeout(req, resp, m.config.Timeout)
+		duration := time.Since(startTime)
+
+		if err != nil || resp.StatusCode() >= fiber.StatusInternalServerError {
+			metrics.ObserveMirror(m.routeID, metrics.MirrorFailure, duration)
+			logger.Debug("Mirrored request failed",
+				zap.String("route", m.routeID),
+				zap.Int("statusCode", resp.StatusCode()),
+				zap.Error(err))
+			return
+		}
+		metrics.ObserveMirror(m.routeID, metrics.MirrorSuccess, duration)
+	}()
+}
+
+// drop 记录一个被丢弃的镜像副本
+// drop records a dropped mirror copy
+func (m *mirror) drop(reason string) {
+	metrics.ObserveMirror(m.routeID, metrics.MirrorDropped, 0)
+	logger.Debug("Dropped mirrored request", zap.String("route", m.routeID), zap.String("reason", reason))
+}
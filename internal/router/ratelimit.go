@@ -0,0 +1,173 @@
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/metrics"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/ratelimit"
+	"go.uber.org/zap"
+)
+
+// Redis 中限流计数键的前缀，位于缓存的 redis_prefix 之后
+// Prefix of rate limit counter keys in Redis, after the cache redis_prefix
+const rateLimitKeyPrefix = "ratelimit:"
+
+// routeLimiter 路由的限流器以及创建它时使用的配置和缓存管理器句柄
+// routeLimiter holds a route's limiter and the config and cache manager handle it was built from
+type routeLimiter struct {
+	limiter ratelimit.Limiter
+	config  config.RateLimit
+	cache   *cacheHandle
+}
+
+// 存储每个路由的限流器，热重载时配置未变化的路由保留计数
+// Store limiters for each route, routes whose config did not change keep their counters on hot reload
+var (
+	routeLimiters = make(map[string]*routeLimiter)
+	limiterMutex  sync.RWMutex
+)
+
+// syncRateLimiters 替换路由限流器。使用 Redis 缓存时计数保存在同一个 Redis 连接中，在多个网关实例之间共享
+// syncRateLimiters replaces the route limiters. With a Redis cache counters are kept on the same Redis connection and
+// shared across gateway instances
+func syncRateLimiters(routes []config.Route) {
+	var redisClient *redis.Client
+	var redisPrefix string
+	handle := getCacheHandle()
+	if handle != nil {
+		redisClient, redisPrefix = handle.manager.RedisClient()
+	}
+
+	limiterMutex.Lock()
+	defer limiterMutex.Unlock()
+
+	next := make(map[string]*routeLimiter, len(routes))
+	for _, route := range routes {
+		if !route.RateLimit.Enabled {
+			continue
+		}
+		rateLimit := route.RateLimit.WithDefaults()
+
+		if existing, exists := routeLimiters[route.ID()]; exists && existing.config == rateLimit && existing.cache == handle {
+			next[route.ID()] = existing
+			continue
+		}
+
+		next[route.ID()] = &routeLimiter{
+			limiter: ratelimit.New(ratelimit.Config{
+				Algorithm: rateLimit.Algorithm,
+				Rate:      rateLimit.Rate,
+				Period:    rateLimit.Period,
+				Burst:     rateLimit.Burst,
+			}, redisClient, redisPrefix+rateLimitKeyPrefix),
+			config: rateLimit,
+			cache:  handle,
+		}
+		logger.Info("Created rate limiter for route",
+			zap.String("path", route.Path),
+			zap.String("algorithm", rateLimit.Algorithm),
+			zap.Int("rate", rateLimit.Rate),
+			zap.Duration("period", rateLimit.Period),
+			zap.String("key", rateLimit.Key),
+			zap.Bool("redis", redisClient != nil))
+	}
+
+	routeLimiters = next
+}
+
+// getRateLimiter 返回路由的限流器，未启用限流时返回 nil
+// getRateLimiter returns the route's limiter, or nil when rate limiting is not enabled
+func getRateLimiter(routeID string) *routeLimiter {
+	limiterMutex.RLock()
+	defer limiterMutex.RUnlock()
+	return routeLimiters[routeID]
+}
+
+// limitRate 在请求交给 next 之前按客户端限流，超过限制时返回 429 和 Retry-After。
+// 限流器出错时放行请求，避免 Redis 故障导致网关不可用
+// limitRate rate limits clients before passing requests to next and returns 429 with Retry-After over the limit.
+// Requests are let through when the limiter fails, so a Redis outage does not take the gateway down
+func limitRate(route config.Route, limiter *routeLimiter, next fiber.Handler) fiber.Handler {
+	if limiter == nil {
+		return next
+	}
+
+	return func(c *fiber.Ctx) error {
+		// 限流计数可能保存在缓存的 Redis 连接中，缓存管理器在热重载时被替换后放行请求
+		// Counters may live on the cache's Redis connection, requests are let through once the cache manager was
+		// replaced on hot reload
+		if limiter.cache != nil {
+			if limiter.cache.acquire() == nil {
+				return next(c)
+			}
+			defer limiter.cache.release()
+		}
+
+		key := route.ID() + ":" + rateLimitKey(c, limiter.config.Key)
+		result, err := limiter.limiter.Allow(c.UserContext(), key)
+		if err != nil {
+			logger.Warn("Rate limiter failed, allowing request",
+				zap.String("path", route.Path),
+				zap.String("key", key),
+				zap.Error(err))
+			return next(c)
+		}
+
+		c.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			metrics.RateLimited(route.ID())
+			logger.Debug("Request rate limited",
+				zap.String("path", route.Path),
+				zap.String("key", key),
+				zap.Duration("retryAfter", result.RetryAfter))
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(1, ceilSeconds(result.RetryAfter))))
+			return fiber.NewError(fiber.StatusTooManyRequests, "Too Many Requests")
+		}
+
+		return next(c)
+	}
+}
+
+// rateLimitKey 根据限流键配置从请求中取出客户端标识的哈希，请求中没有该值时使用客户端IP
+// rateLimitKey extracts a hash of the client identity from the request according to the rate limit key, falling back
+// to the client IP when the request does not carry it
+func rateLimitKey(c *fiber.Ctx, key string) string {
+	var value string
+	switch {
+	case key == config.RateLimitKeyAPIKey:
+		// 优先使用已认证的 API 密钥名称
+		// Prefer the name of the authenticated API key
+		if name, ok := c.Locals(localsAPIKeyName).(string); ok {
+			return key + ":" + name
+		}
+		value = c.Get(config.DefaultRateLimitAPIKeyHeader)
+	case strings.HasPrefix(key, config.RateLimitKeyHeaderPrefix):
+		value = c.Get(strings.TrimPrefix(key, config.RateLimitKeyHeaderPrefix))
+	}
+	if value == "" {
+		return "ip:" + clientIP(c)
+	}
+
+	// 不在计数键中保存原始的 API 密钥
+	// Do not keep raw API keys in counter keys
+	sum := sha256.Sum256([]byte(value))
+	return key + "=" + hex.EncodeToString(sum[:16])
+}
+
+// ceilSeconds 将时长向上取整为秒
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,436 @@
+package router
+
+import (
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/cache"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"go.uber.org/zap"
+)
+
+// 配置文件变更后的防抖时间，编辑器保存时通常会触发多个事件
+// Debounce delay after config file changes, editors usually emit several events per save
+const configReloadDebounce = 500 * time.Millisecond
+
+// routeEntry 路由表中已构建好处理程序的路由
+// routeEntry is a route in the route table with its handler already built
+type routeEntry struct {
+	route   config.Route
+	handler fiber.Handler
+}
+
+// matches 判断请求是否属于该路由：路径匹配并且满足路由的匹配条件
+// matches reports whether the request belongs to the route: the path matches and the route's match conditions hold
+func (e *routeEntry) matches(c *fiber.Ctx) bool {
+	return e.route.Matches(config.RouteRequest{
+		Path:   c.Path(),
+		Method: c.Method(),
+		Host:   string(c.Request().Host()),
+		Header: func(name string) (string, bool) {
+			value := c.Request().Header.Peek(name)
+			return string(value), value != nil
+		},
+		Query: func(name string) (string, bool) {
+			args := c.Request().URI().QueryArgs()
+			return string(args.Peek(name)), args.Has(name)
+		},
+	})
+}
+
+// routeTable 当前生效的路由表，热重载时整体替换
+// routeTable is the active route table, replaced as a whole on hot reload
+type routeTable struct {
+	config       *config.Config
+	vhosts       []config.VHost
+	vhostEntries [][]routeEntry // Routes of each vhost in match order / 每个虚拟主机按匹配顺序排列的路由
+	entries      []routeEntry   // Routes of all vhosts / 所有虚拟主机的路由
+}
+
+var (
+	currentRouteTable atomic.Pointer[routeTable]
+	reloadMutex       sync.Mutex
+)
+
+// dispatchRoute 先按 Host 选择虚拟主机，再将请求分发到其第一个匹配的路由处理程序，路由已按优先级排序
+// dispatchRoute selects the vhost by Host first, then dispatches the request to its first matching route handler,
+// the routes are already sorted by priority
+func dispatchRoute(c *fiber.Ctx) error {
+	table := currentRouteTable.Load()
+	if table == nil {
+		return c.Next()
+	}
+
+	vhost := config.SelectVHost(table.vhosts, string(c.Request().Host()))
+	if vhost < 0 {
+		return c.Next()
+	}
+
+	entries := table.vhostEntries[vhost]
+	for i := range entries {
+		if entries[i].matches(c) {
+			return entries[i].handler(c)
+		}
+	}
+
+	return c.Next()
+}
+
+// buildRouteTable 按匹配顺序为每个虚拟主机的每个路由创建处理程序
+// buildRouteTable creates a handler for every route of every vhost, in match order
+func buildRouteTable(config_ *config.Config) *routeTable {
+	vhosts := config_.VirtualHosts()
+	routeCount := len(config_.AllRoutes())
+	logger.Info("Initializing routes", zap.Int("routeCount", routeCount), zap.Int("vhostCount", len(vhosts)))
+
+	table := &routeTable{
+		config:       config_,
+		vhosts:       vhosts,
+		vhostEntries: make([][]routeEntry, len(vhosts)),
+		entries:      make([]routeEntry, 0, routeCount),
+	}
+
+	apiKeys := newAPIKeyStore(config_.APIKeys.Keys)
+
+	for v, vhost := range vhosts {
+		table.vhostEntries[v] = buildVHostEntries(config_, vhost, apiKeys, len(table.entries), routeCount)
+		table.entries = append(table.entries, table.vhostEntries[v]...)
+	}
+
+	return table
+}
+
+// buildVHostEntries 按匹配顺序为虚拟主机的每个路由创建处理程序，offset 为之前虚拟主机的路由数，用于日志
+// buildVHostEntries creates a handler for every route of the vhost in match order, offset is the number of routes of
+// the previous vhosts, used for logging
+func buildVHostEntries(config_ *config.Config, vhost config.VHost, apiKeys *apiKeyStore, offset, routeCount int) []routeEntry {
+	entries := make([]routeEntry, 0, len(vhost.Routes))
+	for i, route := range vhost.OrderedRoutes() {
+		// 合并全局超时和默认值
+		// Merge the global timeouts and defaults
+		route.Timeouts = route.Timeouts.Merge(config_.Timeouts).WithDefaults()
+
+		backendCount := len(route.Backends)
+		logger.Info("Setting up route",
+			zap.Int("routeIndex", offset+i+1),
+			zap.Int("totalRoutes", routeCount),
+			zap.String("route", route.ID()),
+			zap.String("vhost", vhost.Name),
+			zap.String("path", route.Path),
+			zap.Int("priority", route.Priority),
+			zap.Bool("conditional", !route.Match.IsEmpty()),
+			zap.Int("backendCount", backendCount),
+			zap.Bool("cacheEnabled", route.CacheEnable && config_.Cache.Enabled),
+			zap.Int("cacheTTL", route.CacheTTL),
+			zap.Int("cachePathCount", len(route.CachePaths)))
+
+		// 按客户端IP或请求头限流的请求在认证之前限流，未认证的请求同样受限；
+		// 按 API 密钥限流的请求在认证之后限流，以便使用已认证的密钥名称
+		// Requests limited by client IP or header are limited before authentication, so unauthenticated requests are
+		// limited too; requests limited by API key are limited after authentication to use the authenticated key name
+		limiter := getRateLimiter(route.ID())
+		limitAfterAuth := limiter != nil && limiter.config.Key == config.RateLimitKeyAPIKey
+
+		handler := CreateNewHandler(route, config_.Cache.Enabled)
+		if limitAfterAuth {
+			handler = limitRate(route, limiter, handler)
+		}
+		handler = requireJWT(route, handler)
+		handler = requireBasicAuth(route, handler)
+		handler = requireAPIKey(route, apiKeys, handler)
+		handler = requireClientCert(route, handler)
+		if !limitAfterAuth {
+			handler = limitRate(route, limiter, handler)
+		}
+
+		entries = append(entries, routeEntry{
+			route:   route,
+			handler: instrumentRoute(route.ID(), handler),
+		})
+	}
+
+	return entries
+}
+
+// applyConfig 应用新配置：缓存、负载均衡器和路由表
+// applyConfig applies a new config: cache, load balancers and the route table
+func applyConfig(config_ *config.Config) {
+	previous := currentRouteTable.Load()
+	if previous != nil && (previous.config.Host != config_.Host || previous.config.Port != config_.Port) {
+		logger.Warn("Listen address changes require a restart, keeping the current listener",
+			zap.String("currentHost", previous.config.Host),
+			zap.Int("currentPort", previous.config.Port),
+			zap.String("newHost", config_.Host),
+			zap.Int("newPort", config_.Port))
+	}
+
+	if previous != nil && previous.config.Metrics != config_.Metrics {
+		logger.Warn("Metrics endpoint changes require a restart, keeping the current endpoint")
+	}
+	if previous != nil && previous.config.Admin != config_.Admin {
+		logger.Warn("Admin API changes require a restart, keeping the current admin API")
+	}
+
+	if previous != nil {
+		current, next := previous.config.TLS.WithDefaults(), config_.TLS.WithDefaults()
+		if current.Enabled != next.Enabled || current.Port != next.Port || current.RedirectHTTP != next.RedirectHTTP {
+			logger.Warn("TLS listener changes require a restart, keeping the current listener; certificates and ciphers are reloaded")
+		}
+	}
+
+	var previousCache *config.Cache
+	if previous != nil {
+		previousCache = &previous.config.Cache
+	}
+	applyCacheConfig(config_.Cache, previousCache)
+	routes := config_.AllRoutes()
+	syncUpstreamTLS(routes)
+	syncLoadBalancers(routes)
+	syncRateLimiters(routes)
+	syncTLS(config_.EffectiveTLS(), routes)
+	syncForwarded(config_.Forwarded)
+
+	currentRouteTable.Store(buildRouteTable(config_))
+}
+
+// applyCacheConfig 在缓存配置变化时重新创建缓存管理器
+// applyCacheConfig recreates the cache manager when the cache config changes
+func applyCacheConfig(cacheConfig config.Cache, previous *config.Cache) {
+	if previous != nil && reflect.DeepEqual(*previous, cacheConfig) {
+		return
+	}
+
+	var next *cache.CacheManager
+	if cacheConfig.Enabled {
+		logger.Info("Initializing cache manager",
+			zap.Bool("useRedis", cacheConfig.UseRedis),
+			zap.String("redisPrefix", cacheConfig.RedisPrefix))
+
+		cacheStartTime := time.Now()
+		manager, err := cache.NewCacheManager(cacheConfig)
+		cacheDuration := time.Since(cacheStartTime)
+
+		if err != nil {
+			logger.Warn("Failed to initialize cache manager, running without cache",
+				zap.Error(err),
+				zap.Duration("initTime", cacheDuration))
+		} else {
+			logger.Info("Cache manager initialized successfully",
+				zap.Bool("useRedis", cacheConfig.UseRedis),
+				zap.Duration("initTime", cacheDuration))
+			next = manager
+		}
+	} else {
+		logger.Info("Cache is disabled in configuration, running without cache")
+	}
+
+	swapCacheManager(next)
+}
+
+// swapCacheManager 替换当前的缓存管理器，之前的缓存管理器在正在使用它的请求结束后关闭
+// swapCacheManager replaces the current cache manager, the previous one is closed once the requests using it finish
+func swapCacheManager(next *cache.CacheManager) {
+	var handle *cacheHandle
+	if next != nil {
+		handle = &cacheHandle{manager: next}
+	}
+
+	cacheMutex.Lock()
+	previous := currentCache
+	currentCache = handle
+	cacheMutex.Unlock()
+
+	previous.retire()
+}
+
+// sameBalancerConfig 判断两个路由配置能否共用同一个负载均衡器
+// sameBalancerConfig reports whether two route configs can share the same load balancer
+func sameBalancerConfig(a, b config.Route) bool {
+	return slices.Equal(a.Backends, b.Backends) &&
+		a.LBStrategy == b.LBStrategy &&
+		maps.Equal(a.BackendWeights, b.BackendWeights) &&
+		slices.EqualFunc(a.BackendGroups, b.BackendGroups, sameBackendGroup) &&
+		reflect.DeepEqual(a.HealthCheck, b.HealthCheck) &&
+		reflect.DeepEqual(a.PassiveHealth, b.PassiveHealth) &&
+		reflect.DeepEqual(a.CircuitBreaker, b.CircuitBreaker)
+}
+
+// sameBackendGroup 判断两个后端分组的名称和后端是否相同，权重只影响分配，修改权重时保留负载均衡器
+// sameBackendGroup reports whether two backend groups have the same name and backends, weights only affect the
+// assignment so load balancers are kept when weights change
+func sameBackendGroup(a, b config.BackendGroup) bool {
+	return a.Name == b.Name && slices.Equal(a.Backends, b.Backends)
+}
+
+// syncLoadBalancers 替换路由负载均衡器，配置未变化的路由保留原有的健康状态
+// syncLoadBalancers replaces the route load balancers, keeping health state for routes whose balancing config did not change
+func syncLoadBalancers(routes []config.Route) {
+	loadBalancerMutex.Lock()
+	defer loadBalancerMutex.Unlock()
+
+	next := make(map[string]*routeBalancer, len(routes))
+	for _, route := range routes {
+		if balancer, exists := routeLoadBalancers[route.ID()]; exists && sameBalancerConfig(balancer.route, route) {
+			next[route.ID()] = balancer
+			continue
+		}
+		next[route.ID()] = newRouteBalancer(route)
+	}
+
+	// 停止不再使用的负载均衡器的健康检查
+	// Stop health checks of load balancers that are no longer used
+	for id, balancer := range routeLoadBalancers {
+		if next[id] != balancer {
+			balancer.close()
+		}
+	}
+
+	routeLoadBalancers = next
+}
+
+// ReloadConfig 重新解析并验证配置文件，验证失败时保留当前配置
+// ReloadConfig re-parses and validates the config file, keeping the current config if validation fails
+func ReloadConfig(configPath string) error {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	logger.Info("Reloading config", zap.String("config", configPath))
+	reloadStartTime := time.Now()
+
+	config_, err := config.ParseConfig(configPath)
+	if err != nil {
+		logger.Error("Config reload rejected, keeping current config", zap.String("config", configPath), zap.Error(err))
+		return err
+	}
+	if err := config.ValidateConfig(config_); err != nil {
+		logger.Error("Config reload rejected, keeping current config", zap.String("config", configPath), zap.Error(err))
+		return err
+	}
+
+	applyConfig(config_)
+	logger.Info("Config reloaded",
+		zap.String("config", configPath),
+		zap.Int("routeCount", len(config_.AllRoutes())),
+		zap.Duration("reloadTime", time.Since(reloadStartTime)))
+
+	return nil
+}
+
+// watchReloadSignal 收到 SIGHUP 时重新加载配置
+// watchReloadSignal reloads the config on SIGHUP
+func watchReloadSignal(configPath string) {
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+
+	go func() {
+		for range hupChan {
+			logger.Info("Received SIGHUP")
+			_ = ReloadConfig(configPath)
+		}
+	}()
+}
+
+// watchConfigFile 监听配置文件及其引用的文件（例如 API 密钥文件）的变化并重新加载配置
+// watchConfigFile watches the config file and the files it references (such as the API keys file) for changes and
+// reloads the config
+func watchConfigFile(configPath string) {
+	absPath, err := filepath.Abs(configPath)
+	if err != nil {
+		logger.Warn("Config file watching disabled", zap.String("config", configPath), zap.Error(err))
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Warn("Config file watching disabled", zap.String("config", configPath), zap.Error(err))
+		return
+	}
+
+	// 监听所在目录而不是文件本身，以便处理编辑器重命名替换文件的情况
+	// Watch the parent directory instead of the file so editors that replace the file via rename are handled
+	if err := watcher.Add(filepath.Dir(absPath)); err != nil {
+		logger.Warn("Config file watching disabled", zap.String("config", configPath), zap.Error(err))
+		watcher.Close()
+		return
+	}
+	watchIncludedFiles(watcher)
+
+	logger.Info("Watching config file for changes", zap.String("config", absPath))
+
+	go func() {
+		defer watcher.Close()
+
+		var debounce *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) || !isWatchedFile(absPath, filepath.Clean(event.Name)) {
+					continue
+				}
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = time.AfterFunc(configReloadDebounce, func() {
+					if _, err := os.Stat(absPath); err != nil {
+						logger.Warn("Config file is missing, keeping current config", zap.String("config", absPath), zap.Error(err))
+						return
+					}
+					if ReloadConfig(configPath) == nil {
+						watchIncludedFiles(watcher)
+					}
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("Config file watcher error", zap.Error(err))
+			}
+		}
+	}()
+}
+
+// includedFiles 返回当前配置引用的文件的绝对路径
+// includedFiles returns the absolute paths of the files referenced by the current config
+func includedFiles() []string {
+	table := currentRouteTable.Load()
+	if table == nil {
+		return nil
+	}
+
+	var files []string
+	for _, file := range table.config.IncludedFiles() {
+		if absFile, err := filepath.Abs(file); err == nil {
+			files = append(files, absFile)
+		}
+	}
+	return files
+}
+
+// isWatchedFile 判断变化的文件是否为配置文件或其引用的文件
+// isWatchedFile reports whether the changed file is the config file or a file it references
+func isWatchedFile(configPath, name string) bool {
+	return name == configPath || slices.Contains(includedFiles(), name)
+}
+
+// watchIncludedFiles 监听当前配置引用的文件所在的目录
+// watchIncludedFiles watches the directories of the files referenced by the current config
+func watchIncludedFiles(watcher *fsnotify.Watcher) {
+	for _, file := range includedFiles() {
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			logger.Warn("Failed to watch included file", zap.String("file", file), zap.Error(err))
+		}
+	}
+}
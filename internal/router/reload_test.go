@@ -0,0 +1,65 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/nerdneilsfield/simple_api_gateway/internal/cache"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+)
+
+func TestSwapCacheManagerWaitsForRequests(t *testing.T) {
+	newManager := func() *cache.CacheManager {
+		t.Helper()
+		manager, err := cache.NewCacheManager(config.Cache{Enabled: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return manager
+	}
+	defer swapCacheManager(nil)
+
+	first := newManager()
+	swapCacheManager(first)
+	previous := getCacheHandle()
+
+	// 两个请求在热重载前获取了缓存管理器
+	// Two requests acquire the cache manager before the hot reload
+	cm, releaseFirst := acquireCacheManager()
+	if cm != first {
+		t.Fatal("acquireCacheManager did not return the current cache manager")
+	}
+	_, releaseSecond := acquireCacheManager()
+
+	second := newManager()
+	swapCacheManager(second)
+	if cm, release := acquireCacheManager(); cm != second {
+		t.Fatal("acquireCacheManager did not return the new cache manager")
+	} else {
+		release()
+	}
+	if previous.acquire() != nil {
+		t.Fatal("a replaced cache manager can still be acquired")
+	}
+
+	releaseFirst()
+	if previous.closed {
+		t.Fatal("previous cache manager closed while a request still uses it")
+	}
+	releaseSecond()
+	if !previous.closed {
+		t.Fatal("previous cache manager not closed after the last request finished")
+	}
+
+	// 没有请求使用时，被替换的缓存管理器立即关闭
+	// Without requests using it, a replaced cache manager is closed right away
+	current := getCacheHandle()
+	swapCacheManager(nil)
+	if !current.closed {
+		t.Fatal("unused cache manager not closed when replaced")
+	}
+	if cm, release := acquireCacheManager(); cm != nil {
+		t.Fatal("acquireCacheManager returned a cache manager with cache disabled")
+	} else {
+		release()
+	}
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"github.com/valyala/fasthttp"
+)
+
+// 幂等的 HTTP 方法，默认只重试这些方法
+// Idempotent HTTP methods, only these are retried by default
+var idempotentMethods = []string{
+	fasthttp.MethodGet,
+	fasthttp.MethodHead,
+	fasthttp.MethodOptions,
+	fasthttp.MethodTrace,
+	fasthttp.MethodPut,
+	fasthttp.MethodDelete,
+}
+
+// maxAttempts 返回该请求最多可以尝试的次数
+// maxAttempts returns how many attempts the request may make
+func maxAttempts(method string, retry config.Retry) int {
+	if !retry.AllowNonIdempotent && !slices.Contains(idempotentMethods, method) {
+		return 1
+	}
+	return retry.Attempts
+}
+
+// classifyProxyError 将代理错误归类为 connect_failure、timeout 或 reset，无法归类时返回空字符串
+// classifyProxyError classifies a proxy error as connect_failure, timeout or reset, or returns an empty string
+func classifyProxyError(err error) string {
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, fasthttp.ErrDialTimeout), errors.As(err, &opErr) && opErr.Op == "dial":
+		return config.RetryOnConnectFailure
+	case errors.Is(err, fasthttp.ErrTimeout), errors.Is(err, syscall.ETIMEDOUT):
+		return config.RetryOnTimeout
+	case errors.Is(err, fasthttp.ErrConnectionClosed), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return config.RetryOnReset
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return config.RetryOnTimeout
+	}
+	return ""
+}
+
+// isRetryableError 判断代理错误是否可以按配置重试
+// isRetryableError reports whether the proxy error may be retried according to the config
+func isRetryableError(err error, retry config.Retry) bool {
+	kind := classifyProxyError(err)
+	return kind != "" && slices.Contains(retry.RetryOnErrors, kind)
+}
+
+// retryBackoff 返回第 n 次重试前的指数退避时间
+// retryBackoff returns the exponential backoff before the n-th retry
+func retryBackoff(n int, retry config.Retry) time.Duration {
+	backoff := retry.Backoff
+	for i := 1; i < n && backoff < retry.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, retry.MaxBackoff)
+}
@@ -2,9 +2,12 @@ package router
 
 import (
 	"crypto/md5"
+	"crypto/tls"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -15,19 +18,101 @@ import (
 	"github.com/nerdneilsfield/simple_api_gateway/internal/cache"
 	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
 	"github.com/nerdneilsfield/simple_api_gateway/internal/loadbalancer"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/metrics"
 	"github.com/nerdneilsfield/simple_api_gateway/internal/wiki"
+	"github.com/valyala/fasthttp"
 	"go.uber.org/zap"
 )
 
+var logger = loggerPkg.GetLogger()
+
+// 当前的缓存管理器，热重载时可能被替换
+// Current cache manager, may be replaced on hot reload
 var (
-	logger       = loggerPkg.GetLogger()
-	cacheManager *cache.CacheManager
+	currentCache *cacheHandle
+	cacheMutex   sync.RWMutex
 )
 
+// cacheHandle 缓存管理器及正在使用它的请求数。被替换后，缓存管理器在最后一个使用者释放时关闭
+// cacheHandle holds a cache manager and the number of requests using it. Once replaced, the cache manager is closed
+// when the last user releases it
+type cacheHandle struct {
+	manager *cache.CacheManager
+	mutex   sync.Mutex
+	users   int
+	retired bool
+	closed  bool
+}
+
+// acquire 记录一个使用者并返回缓存管理器，缓存管理器已被替换时返回 nil。返回非 nil 时需调用 release
+// acquire records a user and returns the cache manager, nil once the cache manager was replaced. release must be
+// called when the result is not nil
+func (h *cacheHandle) acquire() *cache.CacheManager {
+	if h == nil {
+		return nil
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.retired {
+		return nil
+	}
+	h.users++
+	return h.manager
+}
+
+// release 释放一个使用者，缓存管理器已被替换且没有其他使用者时将其关闭
+// release releases a user, closing the cache manager when it was replaced and has no other users
+func (h *cacheHandle) release() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.users--
+	if h.retired && h.users == 0 {
+		h.close()
+	}
+}
+
+// retire 标记缓存管理器已被替换，没有使用者时立即关闭，否则在最后一个使用者释放时关闭
+// retire marks the cache manager as replaced, closing it now without users or when the last user releases it
+func (h *cacheHandle) retire() {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.retired = true
+	if h.users == 0 {
+		h.close()
+	}
+}
+
+// close 关闭缓存管理器，调用方需持有锁
+// close closes the cache manager, the caller must hold the lock
+func (h *cacheHandle) close() {
+	if h.closed {
+		return
+	}
+	h.closed = true
+	logger.Info("Closing cache manager")
+	if err := h.manager.Close(); err != nil {
+		logger.Warn("Failed to close cache manager", zap.Error(err))
+	}
+}
+
+// routeBalancer 路由的负载均衡器、健康检查器以及创建它们时使用的路由配置
+// routeBalancer holds a route's load balancer, health checker and the route config they were built from
+type routeBalancer struct {
+	lb      loadbalancer.LoadBalancer
+	checker *loadbalancer.HealthChecker
+	route   config.Route
+}
+
 // 存储每个路由的负载均衡器
 // Store load balancers for each route
 var (
-	routeLoadBalancers = make(map[string]loadbalancer.LoadBalancer)
+	routeLoadBalancers = make(map[string]*routeBalancer)
 	loadBalancerMutex  sync.RWMutex
 )
 
@@ -36,12 +121,19 @@ var (
 func generateCacheKey(c *fiber.Ctx, route config.Route) string {
 	// Use request method, path, query parameters, and body to generate cache key
 	// 使用请求方法、路径、查询参数和请求体生成缓存键
-	h := md5.New()
-	h.Write([]byte(c.Method()))
-	h.Write([]byte(c.Path()))
-	h.Write(c.Request().URI().QueryString())
-	h.Write(c.Body())
-	key := route.Path + ":" + hex.EncodeToString(h.Sum(nil))
+	// With cache_by_subject the JWT subject is included so per-user responses are not shared
+	// 启用 cache_by_subject 时包含 JWT 的 sub，避免不同用户共享响应
+	var subject string
+	if route.JWT.Enabled && route.JWT.CacheBySubject {
+		subject, _ = c.Locals(localsJWTSubject).(string)
+	}
+	// 使用后端分组时包含分组，避免不同分组共享响应
+	// With backend groups the group is included so groups do not share responses
+	var group string
+	if len(route.BackendGroups) > 0 {
+		group = backendGroup(c, route)
+	}
+	key := cacheKey(cacheNamespace(route), c.Method(), c.Path(), c.Request().URI().QueryString(), c.Body(), subject, group)
 	logger.Debug("Generated cache key",
 		zap.String("method", c.Method()),
 		zap.String("path", c.Path()),
@@ -50,6 +142,30 @@ func generateCacheKey(c *fiber.Ctx, route config.Route) string {
 	return key
 }
 
+// cacheNamespace 返回路由缓存键的前缀：虚拟主机的缓存前缀加上路由标识
+// cacheNamespace returns the prefix of the route's cache keys: the vhost cache prefix followed by the route ID
+func cacheNamespace(route config.Route) string {
+	return route.CachePrefix + route.ID()
+}
+
+// cacheKey 由路由命名空间和请求方法、路径、查询字符串、请求体以及可选的 JWT sub 和后端分组计算缓存键，管理 API 清除缓存时使用相同的规则
+// cacheKey computes the cache key from the route namespace and the request method, path, query string, body and the
+// optional JWT subject and backend group; the admin API uses the same rule to purge cache entries
+func cacheKey(namespace, method, path string, query, body []byte, subject, group string) string {
+	h := md5.New()
+	h.Write([]byte(method))
+	h.Write([]byte(path))
+	h.Write(query)
+	h.Write(body)
+	if subject != "" {
+		h.Write([]byte("\x00sub:" + subject))
+	}
+	if group != "" {
+		h.Write([]byte("\x00group:" + group))
+	}
+	return namespace + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
 // shouldCache determines if a request should be cached based on configuration
 // 根据配置确定请求是否应该被缓存
 func shouldCache(route config.Route, globalCacheEnabled bool, requestPath string) bool {
@@ -110,11 +226,99 @@ func shouldCache(route config.Route, globalCacheEnabled bool, requestPath string
 	return true
 }
 
+// acquireCacheManager 获取当前的缓存管理器和释放函数，缓存未启用时返回 nil。使用完缓存管理器后需调用释放函数，
+// 热重载替换的缓存管理器在此之前不会关闭
+// acquireCacheManager returns the current cache manager and a release function, nil when cache is not enabled. The
+// release function must be called once the cache manager is no longer used, a cache manager replaced on hot reload is
+// not closed before
+func acquireCacheManager() (*cache.CacheManager, func()) {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+
+	cm := currentCache.acquire()
+	if cm == nil {
+		return nil, func() {}
+	}
+	return cm, currentCache.release
+}
+
+// getCacheHandle 返回当前的缓存管理器句柄，缓存未启用时返回 nil
+// getCacheHandle returns the handle of the current cache manager, or nil when cache is not enabled
+func getCacheHandle() *cacheHandle {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	return currentCache
+}
+
+// newRouteBalancer 为路由创建负载均衡器，并在配置了主动健康检查时启动健康检查器
+// newRouteBalancer creates a load balancer for a route and starts a health checker when active health checks are configured
+func newRouteBalancer(route config.Route) *routeBalancer {
+	lb := newLoadBalancer(route)
+	balancer := &routeBalancer{
+		lb:    lb,
+		route: route,
+	}
+
+	if target, ok := lb.(loadbalancer.HealthTarget); ok && route.HealthCheck.Enabled {
+		healthCheck := route.HealthCheck.WithDefaults()
+		balancer.checker = loadbalancer.NewHealthChecker(target, loadbalancer.HealthCheckConfig{
+			Path:           healthCheck.Path,
+			Method:         healthCheck.Method,
+			ExpectedStatus: healthCheck.ExpectedStatus,
+			Interval:       healthCheck.Interval,
+			Timeout:        healthCheck.Timeout,
+			Rise:           healthCheck.Rise,
+			Fall:           healthCheck.Fall,
+			TLSConfig: func(backend string) *tls.Config {
+				return upstreamTLSConfig(route.ID(), backend)
+			},
+		})
+		balancer.checker.Start()
+	}
+
+	if target, ok := lb.(loadbalancer.PassiveHealthTarget); ok {
+		passive := route.PassiveHealth.WithDefaults()
+		target.SetPassiveHealth(loadbalancer.PassiveHealthConfig{
+			MaxFails:    passive.MaxFails,
+			FailTimeout: passive.FailTimeout,
+			FailOpen:    route.AllDownPolicy() == config.AllDownFailOpen,
+		})
+	}
+
+	if target, ok := lb.(loadbalancer.CircuitBreakerTarget); ok && route.CircuitBreaker.Enabled {
+		breaker := route.CircuitBreaker.WithDefaults()
+		target.SetCircuitBreaker(&loadbalancer.CircuitBreakerConfig{
+			Window:           breaker.Window,
+			MinRequests:      breaker.MinRequests,
+			ErrorRate:        breaker.ErrorRate,
+			OpenDuration:     breaker.OpenDuration,
+			HalfOpenRequests: breaker.HalfOpenRequests,
+		})
+	}
+
+	logger.Info("Created load balancer for route",
+		zap.String("path", route.Path),
+		zap.Strings("backends", route.Backends),
+		zap.String("strategy", route.LBStrategy),
+		zap.Bool("healthCheck", route.HealthCheck.Enabled),
+		zap.Bool("circuitBreaker", route.CircuitBreaker.Enabled))
+
+	return balancer
+}
+
+// close 停止路由负载均衡器的后台任务
+// close stops the background tasks of the route load balancer
+func (b *routeBalancer) close() {
+	if b.checker != nil {
+		b.checker.Stop()
+	}
+}
+
 // getLoadBalancer 获取或创建路由的负载均衡器
 // getLoadBalancer gets or creates a load balancer for a route
 func getLoadBalancer(route config.Route) loadbalancer.LoadBalancer {
 	loadBalancerMutex.RLock()
-	lb, exists := routeLoadBalancers[route.Path]
+	balancer, exists := routeLoadBalancers[route.ID()]
 	loadBalancerMutex.RUnlock()
 
 	if !exists {
@@ -123,48 +327,107 @@ func getLoadBalancer(route config.Route) loadbalancer.LoadBalancer {
 
 		// 再次检查，避免并发创建
 		// Check again to avoid concurrent creation
-		lb, exists = routeLoadBalancers[route.Path]
+		balancer, exists = routeLoadBalancers[route.ID()]
 		if !exists {
-			lb = loadbalancer.NewRoundRobinLoadBalancer(route.Backends)
-			routeLoadBalancers[route.Path] = lb
-			logger.Info("Created load balancer for route",
-				zap.String("path", route.Path),
-				zap.Strings("backends", route.Backends))
+			balancer = newRouteBalancer(route)
+			routeLoadBalancers[route.ID()] = balancer
 		}
 	}
 
-	return lb
+	return balancer.lb
 }
 
-// sendProxyRequest sends the request to the backend and returns the response
-// 向后端发送请求并返回响应
-func sendProxyRequest(c *fiber.Ctx, targetFullURL string, route config.Route) (int, []byte, map[string][]string, error) {
-	// Create proxy request
-	// 创建代理请求
-	req := fiber.AcquireAgent()
-	defer fiber.ReleaseAgent(req)
+// backendResponse 后端响应，流式模式下 bodyStream 不为空且 body 为空
+// backendResponse is a backend response, in streaming mode bodyStream is set instead of body
+type backendResponse struct {
+	statusCode    int
+	body          []byte
+	headers       map[string][]string
+	bodyStream    io.ReadCloser
+	contentLength int
+}
+
+// close 关闭未发送的响应体流
+// close closes a body stream that will not be sent
+func (r *backendResponse) close() {
+	if r.bodyStream != nil {
+		r.bodyStream.Close()
+	}
+}
 
+// prepareProxyRequest copies the method, URL, end-to-end headers and route headers into the proxy request
+// 将方法、URL、端到端请求头和路由头部复制到代理请求中
+func prepareProxyRequest(c *fiber.Ctx, req *fasthttp.Request, targetFullURL string, route config.Route) {
 	// Set method and URL
 	// 设置方法和URL
-	req.Request().SetRequestURI(targetFullURL)
-	req.Request().Header.SetMethod(string(c.Method()))
+	req.SetRequestURI(targetFullURL)
+	req.Header.SetMethod(string(c.Method()))
 
-	// Copy all headers
-	// 复制所有头部
+	// Copy all headers except hop-by-hop ones
+	// 复制除逐跳请求头以外的所有头部
+	tokens := connectionTokens(c.Request().Header.PeekAll(fiber.HeaderConnection))
 	c.Request().Header.VisitAll(func(key, value []byte) {
-		req.Request().Header.SetBytesKV(key, value)
+		if !isHopByHop(string(key), tokens) {
+			req.Header.SetBytesKV(key, value)
+		}
 	})
 
+	// WebSocket 升级需要将 Connection 和 Upgrade 转发给后端
+	// WebSocket upgrades need Connection and Upgrade forwarded to the backend
+	if isWebSocketUpgrade(c) {
+		req.Header.Set(fiber.HeaderConnection, "Upgrade")
+		req.Header.Set(fiber.HeaderUpgrade, c.Get(fiber.HeaderUpgrade))
+	}
+
+	// 设置转发请求头
+	// Set the forwarding headers
+	setForwardedHeaders(c, req)
+
 	if route.UaClient != "" {
-		req.Request().Header.Set("User-Agent", route.UaClient)
+		req.Header.Set("User-Agent", route.UaClient)
 	}
 
 	// Add custom headers
 	// 添加自定义头部
 	for key, value := range route.CustomHeaders {
-		req.Request().Header.Set(key, value)
+		req.Header.Set(key, value)
 	}
 
+	// 应用请求头规则
+	// Apply the request header rules
+	applyHeaderOps(c, route, &req.Header, route.Headers.Request)
+}
+
+// responseHeaders 获取端到端响应头，逐跳响应头不返回给客户端
+// responseHeaders returns the end-to-end response headers, hop-by-hop headers are not returned to the client
+func responseHeaders(resp *fasthttp.Response) map[string][]string {
+	headers := make(map[string][]string)
+	tokens := connectionTokens(resp.Header.PeekAll(fiber.HeaderConnection))
+	resp.Header.VisitAll(func(key, value []byte) {
+		k := string(key)
+		if isHopByHop(k, tokens) {
+			return
+		}
+		v := string(value)
+		headers[k] = append(headers[k], v)
+	})
+	return headers
+}
+
+// sendProxyRequest sends the request to the backend and returns the buffered response
+// 向后端发送请求并返回缓冲的响应
+func sendProxyRequest(c *fiber.Ctx, targetFullURL string, route config.Route, timeouts config.Timeouts, tlsConfig *tls.Config) (*backendResponse, error) {
+	// Create proxy request
+	// 创建代理请求
+	req := fiber.AcquireAgent()
+	defer fiber.ReleaseAgent(req)
+
+	// Set total timeout of this attempt
+	// 设置本次尝试的总超时时间
+	req.Timeout(timeouts.Total)
+
+	prepareProxyRequest(c, req.Request(), targetFullURL, route)
+
 	// Add request body
 	// 添加请求体
 	if len(c.Body()) > 0 {
@@ -180,71 +443,176 @@ func sendProxyRequest(c *fiber.Ctx, targetFullURL string, route config.Route) (i
 	// Send request
 	// 发送请求
 	if err := req.Parse(); err != nil {
-		return 0, nil, nil, err
+		return nil, err
 	}
 
+	// Apply connect and response header timeouts, retries are handled by handleBackendRequest
+	// 应用连接超时和响应头超时，重试由 handleBackendRequest 处理
+	req.HostClient.Dial = backendDialer(timeouts)
+	req.HostClient.MaxIdemponentCallAttempts = 1
+	req.HostClient.TLSConfig = tlsConfig
+
 	// 获取响应
 	// Get response
 	statusCode, body, errs := req.Bytes()
 	if len(errs) > 0 {
-		return 0, nil, nil, errs[0]
+		return nil, errs[0]
 	}
 
-	// 获取响应头
-	// Get response headers
-	headers := make(map[string][]string)
-	resp.Header.VisitAll(func(key, value []byte) {
-		k := string(key)
-		v := string(value)
-		headers[k] = append(headers[k], v)
-	})
-
-	return statusCode, body, headers, nil
+	return &backendResponse{
+		statusCode: statusCode,
+		body:       body,
+		headers:    responseHeaders(resp),
+	}, nil
 }
 
-// handleBackendRequest processes the request to the backend server
-// 处理后端服务器请求
-func handleBackendRequest(c *fiber.Ctx, lb loadbalancer.LoadBalancer, route config.Route) (int, []byte, map[string][]string, error) {
-	// 记录开始时间，用于计算响应时间
-	// Record start time for response time calculation
-	startTime := time.Now()
+// handleBackendRequest processes the request to the backend server, retrying on other backends when configured
+// 处理后端服务器请求，配置了重试时在其他后端上重试
+func handleBackendRequest(c *fiber.Ctx, lb loadbalancer.LoadBalancer, route config.Route, useCache bool) (*backendResponse, error) {
+	retry := route.Retry.WithDefaults()
+	attempts := maxAttempts(c.Method(), retry)
 
-	// 获取下一个后端
-	// Get next backend
-	backendURL := lb.NextBackend()
-	if backendURL == "" {
-		return 503, nil, nil, c.Status(503).SendString("No backend servers available")
+	// 流式请求体只能发送一次
+	// A streamed request body can only be sent once
+	if c.Request().IsBodyStream() {
+		attempts = 1
 	}
+	tried := make([]string, 0, attempts)
 
-	// 构建代理请求
-	// Build proxy request
-	targetFullURL, err := buildTargetURL(c, backendURL, route)
-	if err != nil {
-		logger.Error("Error parsing backend URL", zap.String("backend", backendURL), zap.Error(err))
-		return 500, nil, nil, c.Status(500).SendString("Error parsing backend URL")
-	}
+	// 总超时覆盖包括重试在内的整个代理请求
+	// The total timeout covers the whole proxied request including retries
+	deadline := time.Now().Add(route.Timeouts.Total)
 
-	// 创建并发送请求
-	// Create and send request
-	statusCode, body, headers, err := sendProxyRequest(c, targetFullURL, route)
-	if err != nil {
-		lb.ReportFailure(backendURL)
-		logger.Error("Backend request failed",
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(retryBackoff(attempt-1, retry))
+		}
+		if !time.Now().Before(deadline) {
+			lastErr = fasthttp.ErrTimeout
+			break
+		}
+
+		// 记录开始时间，用于计算响应时间
+		// Record start time for response time calculation
+		startTime := time.Now()
+
+		// 获取下一个后端，优先选择尚未尝试过的后端
+		// Get next backend, preferring one that has not been tried yet
+		backendURL := nextBackend(c, lb, route, tried)
+		if backendURL == "" {
+			break
+		}
+		tried = append(tried, backendURL)
+		c.Locals(localsBackend, backendURL)
+
+		// 构建代理请求
+		// Build proxy request
+		targetFullURL, err := buildTargetURL(c, backendURL, route)
+		if err != nil {
+			// 请求没有发送到后端，只释放选择后端时记录的请求，不计为后端失败
+			// The request never reached the backend, so only release it without counting a backend failure
+			lb.Release(backendURL)
+			logger.Error("Error parsing backend URL", zap.String("backend", backendURL), zap.Error(err))
+			return nil, fiber.NewError(fiber.StatusInternalServerError, "Error parsing backend URL")
+		}
+
+		// 创建并发送请求
+		// Create and send request
+		timeouts := route.TimeoutsFor(backendURL)
+		timeouts.Total = min(timeouts.Total, time.Until(deadline))
+		if retry.PerTryTimeout > 0 {
+			timeouts.Total = min(timeouts.Total, retry.PerTryTimeout)
+		}
+		tlsConfig := upstreamTLSConfig(route.ID(), backendURL)
+		var resp *backendResponse
+		if route.Streaming {
+			resp, err = sendStreamingRequest(c, targetFullURL, route, timeouts, tlsConfig, useCache)
+		} else {
+			resp, err = sendProxyRequest(c, targetFullURL, route, timeouts, tlsConfig)
+		}
+		metrics.ObserveBackend(route.ID(), backendURL, statusCodeOf(resp), time.Since(startTime))
+		if err != nil {
+			lb.ReportFailure(backendURL)
+			lastErr = err
+			retryable := attempt < attempts && isRetryableError(err, retry)
+			logger.Error("Backend request failed",
+				zap.String("backend", backendURL),
+				zap.Int("attempt", attempt),
+				zap.Int("maxAttempts", attempts),
+				zap.Bool("retry", retryable),
+				zap.Error(err))
+			if retryable {
+				continue
+			}
+			break
+		}
+
+		// 后端返回了可重试的状态码，换一个后端重试
+		// Backend returned a retryable status code, retry on another backend
+		if attempt < attempts && slices.Contains(retry.RetryOnStatus, resp.statusCode) {
+			resp.close()
+			lb.ReportFailure(backendURL)
+			logger.Warn("Backend returned retryable status",
+				zap.String("backend", backendURL),
+				zap.Int("statusCode", resp.statusCode),
+				zap.Int("attempt", attempt),
+				zap.Int("maxAttempts", attempts))
+			continue
+		}
+
+		// 计为失败的状态码照常返回给客户端，但向负载均衡器报告失败
+		// Status codes counted as failures are returned to the client as usual but reported as failures
+		if route.IsFailureStatus(resp.statusCode) {
+			lb.ReportFailure(backendURL)
+			logger.Warn("Backend returned failure status",
+				zap.String("backend", backendURL),
+				zap.Int("statusCode", resp.statusCode))
+			return resp, nil
+		}
+
+		// 请求成功，报告成功；流式响应在响应体发送完毕后才报告，以便正确统计活动连接
+		// Request succeeded, report success; streamed responses report once the body was sent so active connections stay accurate
+		responseTime := time.Since(startTime)
+		if resp.bodyStream != nil {
+			resp.bodyStream = onClose(resp.bodyStream, func() {
+				lb.ReportSuccess(backendURL, responseTime)
+			})
+		} else {
+			lb.ReportSuccess(backendURL, responseTime)
+		}
+		logger.Debug("Backend request succeeded",
 			zap.String("backend", backendURL),
-			zap.Error(err))
-		return 500, nil, nil, c.Status(500).SendString(fmt.Sprintf("Error: %v", err))
+			zap.Int("statusCode", resp.statusCode),
+			zap.Int("attempt", attempt),
+			zap.Bool("streaming", resp.bodyStream != nil),
+			zap.Duration("responseTime", responseTime))
+
+		return resp, nil
 	}
 
-	// 请求成功，报告成功
-	// Request succeeded, report success
-	responseTime := time.Since(startTime)
-	lb.ReportSuccess(backendURL, responseTime)
-	logger.Debug("Backend request succeeded",
-		zap.String("backend", backendURL),
-		zap.Int("statusCode", statusCode),
-		zap.Duration("responseTime", responseTime))
+	return nil, backendError(lastErr)
+}
 
-	return statusCode, body, headers, nil
+// statusCodeOf 返回后端响应的状态码，没有响应时返回 0
+// statusCodeOf returns the status code of a backend response, 0 without a response
+func statusCodeOf(resp *backendResponse) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.statusCode
+}
+
+// backendError 将最后一次后端错误转换为返回给客户端的错误，没有错误表示没有可用的后端
+// backendError converts the last backend error into the error returned to the client, nil means no backend was available
+func backendError(lastErr error) error {
+	if lastErr == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "No backend servers available")
+	}
+	if isTimeoutError(lastErr) {
+		return fiber.NewError(fiber.StatusGatewayTimeout, "Gateway Timeout")
+	}
+	return fiber.NewError(fiber.StatusBadGateway, "Bad Gateway")
 }
 
 // buildTargetURL constructs the target URL for the proxy request
@@ -257,35 +625,18 @@ func buildTargetURL(c *fiber.Ctx, backendURL string, route config.Route) (string
 		return "", err
 	}
 
-	// Build target URL
-	// 构建目标URL
-	trimmedPath := c.Path()[len(route.Path):]
-
-	// Normalize trimmedPath so it always joins cleanly with the backend URL.
-	// 规范化trimmedPath，确保能正确拼接到后端URL。
-	if trimmedPath != "" && !strings.HasPrefix(trimmedPath, "/") {
-		trimmedPath = "/" + trimmedPath
-	}
-
-	// Apply rewrite rule if configured
-	// 如果配置了重写规则，则应用重写
-	if route.RewriteFrom != "" && route.RewriteTo != "" {
-		// Check if the path portion starts with rewrite_from
-		// 检查路径部分是否以 rewrite_from 开头
-		if strings.HasPrefix(trimmedPath, route.RewriteFrom) {
-			// Replace the prefix
-			// 替换前缀
-			trimmedPath = route.RewriteTo + trimmedPath[len(route.RewriteFrom):]
-			logger.Debug("Applied path rewrite",
-				zap.String("route", route.Path),
-				zap.String("originalPath", c.Path()),
-				zap.String("trimmedPath", trimmedPath),
-				zap.String("rewrite_from", route.RewriteFrom),
-				zap.String("rewrite_to", route.RewriteTo))
-		}
+	// Remove the route prefix and apply the rewrite rules if configured
+	// 去掉路由前缀，如果配置了重写规则，则依次应用
+	trimmedPath, queryString, steps := route.Rewrite(c.Path(), string(c.Request().URI().QueryString()))
+	if len(steps) > 0 {
+		logger.Debug("Applied path rewrite",
+			zap.String("route", route.Path),
+			zap.String("originalPath", c.Path()),
+			zap.String("trimmedPath", trimmedPath),
+			zap.String("query", queryString),
+			zap.Int("matchedRules", len(steps)))
 	}
 
-	queryString := string(c.Request().URI().QueryString())
 	targetFullURL := targetURL.String() + trimmedPath
 	if queryString != "" {
 		targetFullURL += "?" + queryString
@@ -300,6 +651,7 @@ func CreateNewHandler(route config.Route, globalCacheEnabled bool) fiber.Handler
 	// 获取路由的负载均衡器
 	// Get load balancer for the route
 	lb := getLoadBalancer(route)
+	mirror := newMirror(route)
 
 	return func(c *fiber.Ctx) error {
 		requestStartTime := time.Now()
@@ -311,31 +663,53 @@ func CreateNewHandler(route config.Route, globalCacheEnabled bool) fiber.Handler
 			zap.String("method", requestMethod),
 			zap.String("route", route.Path))
 
-		// 检查是否应该使用缓存
-		// Check if caching should be used
-		useCache := shouldCache(route, globalCacheEnabled, requestPath) && cacheManager != nil
+		// WebSocket 升级请求直接转发，不经过缓存
+		// WebSocket upgrades are relayed directly, bypassing the cache
+		if isWebSocketUpgrade(c) {
+			return proxyWebSocket(c, lb, route)
+		}
+
+		// 在查找缓存之前准备请求体：缓存键包含请求体，非流式路由不接受超过大小限制的请求体
+		// Prepare the request body before the cache lookup: cache keys include the body, and non-streaming routes
+		// reject bodies over the size limit
+		if err := prepareRequestBody(c, route.Streaming); err != nil {
+			return err
+		}
+
+		// 检查是否应该使用缓存，仍以流的方式读取的请求体无法生成缓存键，不使用缓存
+		// Check if caching should be used, request bodies still read as a stream cannot be part of a cache key so
+		// they bypass the cache
+		cm, releaseCache := acquireCacheManager()
+		defer releaseCache()
+		useCache := shouldCache(route, globalCacheEnabled, requestPath) && cm != nil && !c.Request().IsBodyStream()
 
 		logCacheStatus(useCache, requestPath, requestMethod)
 
 		// 如果使用缓存，尝试从缓存获取响应
 		// If using cache, try to get response from cache
 		if useCache {
-			if cachedResponse := tryGetFromCache(c, route, requestPath, requestMethod); cachedResponse != nil {
+			if cachedResponse := tryGetFromCache(c, cm, route, requestPath, requestMethod); cachedResponse != nil {
+				applyResponseHeaderRules(c, route)
 				return c.Send(cachedResponse)
 			}
 		}
 
+		// 将请求副本发送到镜像后端，不等待镜像响应
+		// Send a copy of the request to the mirror backend without waiting for its response
+		mirror.send(c, route)
+
 		// 处理后端请求
 		// Handle backend request
-		statusCode, body, headers, err := handleBackendRequest(c, lb, route)
+		resp, err := handleBackendRequest(c, lb, route, useCache)
 		if err != nil {
 			return err
 		}
+		statusCode := resp.statusCode
 
-		// 如果需要，缓存响应
-		// Cache response if needed
-		if useCache {
-			tryCacheResponse(c, route, requestPath, requestMethod, statusCode, body, headers)
+		// 如果需要，缓存响应，流式发送的响应不缓存
+		// Cache response if needed, streamed responses are not cached
+		if useCache && resp.bodyStream == nil {
+			tryCacheResponse(c, cm, route, requestPath, requestMethod, statusCode, resp.body, resp.headers)
 		}
 
 		// 记录请求总处理时间
@@ -353,15 +727,19 @@ func CreateNewHandler(route config.Route, globalCacheEnabled bool) fiber.Handler
 
 		// 复制响应头
 		// Copy response headers
-		for key, values := range headers {
+		for key, values := range resp.headers {
 			for _, value := range values {
 				c.Response().Header.Add(key, value)
 			}
 		}
+		applyResponseHeaderRules(c, route)
 
 		// 发送响应体
 		// Send response body
-		return c.Send(body)
+		if resp.bodyStream != nil {
+			return c.SendStream(resp.bodyStream, resp.contentLength)
+		}
+		return c.Send(resp.body)
 	}
 }
 
@@ -381,14 +759,14 @@ func logCacheStatus(useCache bool, requestPath, requestMethod string) {
 
 // tryGetFromCache attempts to get a response from cache
 // 尝试从缓存获取响应
-func tryGetFromCache(c *fiber.Ctx, route config.Route, requestPath, requestMethod string) []byte {
+func tryGetFromCache(c *fiber.Ctx, cm *cache.CacheManager, route config.Route, requestPath, requestMethod string) []byte {
 	cacheKey := generateCacheKey(c, route)
 	logger.Debug("Attempting to get response from cache",
 		zap.String("path", requestPath),
 		zap.String("key", cacheKey))
 
 	cacheStartTime := time.Now()
-	cachedItem, err := cacheManager.Get(cacheKey)
+	cachedItem, err := cm.Get(cacheKey)
 	cacheLookupDuration := time.Since(cacheStartTime)
 
 	if err == nil {
@@ -428,7 +806,7 @@ func tryGetFromCache(c *fiber.Ctx, route config.Route, requestPath, requestMetho
 
 // tryCacheResponse attempts to cache a successful response
 // 尝试缓存成功的响应
-func tryCacheResponse(c *fiber.Ctx, route config.Route, requestPath, requestMethod string, statusCode int, body []byte, headers map[string][]string) {
+func tryCacheResponse(c *fiber.Ctx, cm *cache.CacheManager, route config.Route, requestPath, requestMethod string, statusCode int, body []byte, headers map[string][]string) {
 	// If successful response and should cache, cache the response
 	// 如果是成功的响应并且应该缓存，则缓存响应
 	if statusCode >= 200 && statusCode < 300 {
@@ -446,7 +824,7 @@ func tryCacheResponse(c *fiber.Ctx, route config.Route, requestPath, requestMeth
 			Body:    body,
 			Headers: headers,
 		}
-		if err := cacheManager.Set(cacheKey, cacheItem, route.CacheTTL); err != nil {
+		if err := cm.Set(cacheKey, cacheItem, route.CacheTTL); err != nil {
 			logger.Error("Failed to cache response",
 				zap.String("path", requestPath),
 				zap.String("key", cacheKey),
@@ -468,10 +846,16 @@ func tryCacheResponse(c *fiber.Ctx, route config.Route, requestPath, requestMeth
 	}
 }
 
-// Run starts the API gateway server
-// 启动API网关服务器
-func Run(config_ *config.Config, gitCommit string) {
-	app := fiber.New()
+// Run starts the API gateway server, configPath is used for hot reload and may be empty
+// 启动API网关服务器，configPath 用于热重载，可以为空
+func Run(config_ *config.Config, configPath string, watchConfig bool, gitCommit string) {
+	// 启用请求体流式读取，超过大小限制的请求体交给流式路由处理；不预先解析 multipart 表单，以便原样转发
+	// Enable request body streaming so bodies over the size limit can be handled by streaming routes;
+	// multipart forms are not pre-parsed so they are forwarded as is
+	app := fiber.New(fiber.Config{
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+	})
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.SendString("hello world")
@@ -494,52 +878,37 @@ func Run(config_ *config.Config, gitCommit string) {
 		app.All(wikiMount+"/*", wikiHandler)
 	}
 
-	// Initialize cache manager
-	// 初始化缓存管理器
-	if config_.Cache.Enabled {
-		logger.Info("Initializing cache manager",
-			zap.Bool("useRedis", config_.Cache.UseRedis),
-			zap.String("redisPrefix", config_.Cache.RedisPrefix))
-
-		var err error
-		cacheStartTime := time.Now()
-		cacheManager, err = cache.NewCacheManager(config_.Cache)
-		cacheDuration := time.Since(cacheStartTime)
+	// 初始化缓存、负载均衡器和路由表
+	// Initialize cache, load balancers and the route table
+	applyConfig(config_)
+	defer swapCacheManager(nil)
+
+	// 暴露 Prometheus 指标，需在路由分发之前注册
+	// Expose Prometheus metrics, registered before route dispatching
+	serveMetrics(app, config_.Metrics)
+
+	// 提供管理 API，需在路由分发之前注册
+	// Serve the admin API, registered before route dispatching
+	serveAdmin(app, config_.Admin)
+
+	// 所有代理路由通过路由表分发，以便热重载时无需重新注册
+	// All proxy routes are dispatched through the route table so hot reload does not need to re-register them
+	app.All("/*", dispatchRoute)
+
+	// 监听配置文件变化和 SIGHUP 信号以热重载配置
+	// Watch the config file and SIGHUP to hot reload the config
+	if configPath != "" {
+		watchReloadSignal(configPath)
+		if watchConfig {
+			watchConfigFile(configPath)
+		}
+	}
 
-		if err != nil {
-			logger.Warn("Failed to initialize cache manager, running without cache",
-				zap.Error(err),
-				zap.Duration("initTime", cacheDuration))
-		} else {
-			logger.Info("Cache manager initialized successfully",
-				zap.Bool("useRedis", config_.Cache.UseRedis),
-				zap.Duration("initTime", cacheDuration))
-			defer func() {
-				logger.Info("Closing cache manager")
-				cacheManager.Close()
-			}()
+	if config_.TLS.Enabled {
+		if err := listenTLS(app, config_); err != nil {
+			logger.Fatal("failed to run server", zap.Error(err))
 		}
-	} else {
-		logger.Info("Cache is disabled in configuration, running without cache")
-	}
-
-	// 初始化路由处理程序
-	// Initialize route handlers
-	routeCount := len(config_.Routes)
-	logger.Info("Initializing routes", zap.Int("routeCount", routeCount))
-
-	for i, route := range config_.Routes {
-		backendCount := len(route.Backends)
-		logger.Info("Setting up route",
-			zap.Int("routeIndex", i+1),
-			zap.Int("totalRoutes", routeCount),
-			zap.String("path", route.Path),
-			zap.Int("backendCount", backendCount),
-			zap.Bool("cacheEnabled", route.CacheEnable && config_.Cache.Enabled),
-			zap.Int("cacheTTL", route.CacheTTL),
-			zap.Int("cachePathCount", len(route.CachePaths)))
-
-		app.All(route.Path+"/*", CreateNewHandler(route, config_.Cache.Enabled))
+		return
 	}
 
 	addrString := config_.Host + ":" + fmt.Sprint(config_.Port)
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"hash/crc32"
+	"math/rand/v2"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"go.uber.org/zap"
+)
+
+// localsBackendGroup 请求分配到的后端分组
+// localsBackendGroup is the backend group the request is assigned to
+const localsBackendGroup = "backendGroup"
+
+// backendGroup 返回请求分配到的后端分组，同一请求中保持不变。依次使用覆盖请求头、粘性 Cookie、粘性请求头的哈希，
+// 最后按权重随机分配；随机分配和按请求头分配的结果写入粘性 Cookie
+// backendGroup returns the backend group the request is assigned to, which stays the same for the whole request. It
+// uses the override headers, the sticky cookie, a hash of the sticky header and finally a random pick by weight, in
+// that order; hashed and random assignments are written to the sticky cookie
+func backendGroup(c *fiber.Ctx, route config.Route) string {
+	if group, ok := c.Locals(localsBackendGroup).(string); ok {
+		return group
+	}
+
+	group, source := assignBackendGroup(c, route)
+	c.Locals(localsBackendGroup, group)
+
+	split := route.Split.WithDefaults()
+	if split.StickyCookie != "" && source != "override" && source != "cookie" {
+		c.Cookie(&fiber.Cookie{
+			Name:     split.StickyCookie,
+			Value:    group,
+			Path:     "/",
+			Expires:  time.Now().Add(split.CookieMaxAge),
+			HTTPOnly: true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+	}
+
+	logger.Debug("Assigned backend group",
+		zap.String("route", route.ID()),
+		zap.String("group", group),
+		zap.String("source", source))
+	return group
+}
+
+// assignBackendGroup 选择请求的后端分组并返回选择依据
+// assignBackendGroup picks the backend group of the request and returns what the pick is based on
+func assignBackendGroup(c *fiber.Ctx, route config.Route) (group, source string) {
+	for _, group := range route.BackendGroups {
+		if len(group.Override) > 0 && overrideMatches(c, group.Override) {
+			return group.Name, "override"
+		}
+	}
+
+	split := route.Split
+	if split.StickyCookie != "" {
+		// 权重为0的分组不再接收粘性流量，调整权重即可让客户端离开该分组
+		// Groups with weight 0 get no sticky traffic, so lowering the weight moves clients off the group
+		if group := route.BackendGroup(c.Cookies(split.StickyCookie)); group != nil && group.Weight > 0 {
+			return group.Name, "cookie"
+		}
+	}
+
+	if split.StickyHeader != "" {
+		if value := c.Get(split.StickyHeader); value != "" {
+			return route.GroupForBucket(int(crc32.ChecksumIEEE([]byte(value)) % 100)), "header"
+		}
+	}
+
+	return route.GroupForBucket(rand.IntN(100)), "weight"
+}
+
+// overrideMatches 判断请求是否带有所有覆盖请求头，值为 "*" 时只要求请求头存在
+// overrideMatches reports whether the request has every override header, "*" only requires the header
+func overrideMatches(c *fiber.Ctx, override map[string]string) bool {
+	for name, want := range override {
+		value := c.Request().Header.Peek(name)
+		if value == nil || want != config.MatchAny && string(value) != want {
+			return false
+		}
+	}
+	return true
+}
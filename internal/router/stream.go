@@ -0,0 +1,152 @@
+package router
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"github.com/valyala/fasthttp"
+)
+
+// readCloser 在关闭时执行回调的读取器，回调只执行一次
+// readCloser is a reader that runs a callback exactly once when closed
+type readCloser struct {
+	io.Reader
+	close func() error
+	once  sync.Once
+}
+
+// Close 执行关闭回调
+// Close runs the close callback
+func (r *readCloser) Close() error {
+	var err error
+	r.once.Do(func() {
+		err = r.close()
+	})
+	return err
+}
+
+// onClose 返回一个在关闭后调用 fn 的读取器
+// onClose returns a reader that calls fn after it was closed
+func onClose(rc io.ReadCloser, fn func()) io.ReadCloser {
+	return &readCloser{Reader: rc, close: func() error {
+		err := rc.Close()
+		fn()
+		return err
+	}}
+}
+
+// prepareRequestBody 缓冲不超过大小限制的请求体；超过限制的请求体只有流式路由会直接转发，其他路由返回 413
+// prepareRequestBody buffers request bodies within the body size limit; larger bodies are only passed through
+// by streaming routes, other routes answer 413
+func prepareRequestBody(c *fiber.Ctx, streaming bool) error {
+	if !c.Request().IsBodyStream() {
+		return nil
+	}
+
+	limit := c.App().Config().BodyLimit
+	contentLength := c.Request().Header.ContentLength()
+	if contentLength >= 0 && contentLength <= limit {
+		c.Request().Body()
+		return nil
+	}
+	if streaming {
+		return nil
+	}
+	if contentLength > limit {
+		return fiber.ErrRequestEntityTooLarge
+	}
+
+	// 分块请求体长度未知，最多读取到大小限制
+	// Chunked request bodies have no known length, read at most up to the size limit
+	body, err := io.ReadAll(io.LimitReader(c.Context().RequestBodyStream(), int64(limit)+1))
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+	if len(body) > limit {
+		return fiber.ErrRequestEntityTooLarge
+	}
+	c.Request().SetBody(body)
+	return nil
+}
+
+// sendStreamingRequest 向后端发送请求，请求体和响应体以流的方式转发。
+// 需要缓存且长度已知的小响应会被缓冲，以便写入缓存
+// sendStreamingRequest sends the request to the backend, passing request and response bodies through as streams.
+// Small responses of known length are buffered when they should be cached
+func sendStreamingRequest(c *fiber.Ctx, targetFullURL string, route config.Route, timeouts config.Timeouts, tlsConfig *tls.Config, useCache bool) (*backendResponse, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	prepareProxyRequest(c, req, targetFullURL, route)
+
+	// Add request body, a streamed body is wrapped so releasing the proxy request does not release the client stream
+	// 添加请求体，流式请求体会被包装，避免释放代理请求时释放客户端的流
+	if c.Request().IsBodyStream() {
+		req.SetBodyStream(struct{ io.Reader }{c.Context().RequestBodyStream()}, c.Request().Header.ContentLength())
+	} else if len(c.Body()) > 0 {
+		req.SetBody(c.Body())
+	}
+
+	// 流式响应体可能持续很久，总超时只用于等待响应头
+	// Streamed bodies may last indefinitely, the total timeout only applies while waiting for the response headers
+	timeouts.ResponseHeader = min(timeouts.ResponseHeader, timeouts.Total)
+
+	uri := req.URI()
+	isTLS := bytes.Equal(uri.Scheme(), []byte("https"))
+	client := &fasthttp.HostClient{
+		Addr:                      fasthttp.AddMissingPort(string(uri.Host()), isTLS),
+		IsTLS:                     isTLS,
+		TLSConfig:                 tlsConfig,
+		Dial:                      backendDialer(timeouts),
+		MaxIdemponentCallAttempts: 1,
+		StreamResponseBody:        true,
+	}
+
+	resp := fasthttp.AcquireResponse()
+	if err := client.Do(req, resp); err != nil {
+		fasthttp.ReleaseResponse(resp)
+		return nil, err
+	}
+
+	result := &backendResponse{
+		statusCode: resp.StatusCode(),
+		headers:    responseHeaders(resp),
+	}
+
+	stream := resp.BodyStream()
+	if stream == nil {
+		result.body = append([]byte(nil), resp.Body()...)
+		fasthttp.ReleaseResponse(resp)
+		return result, nil
+	}
+
+	// 需要缓存的小响应完整读取，以便写入缓存
+	// Read small cacheable responses completely so they can be cached
+	contentLength := resp.Header.ContentLength()
+	cacheLimit := route.StreamCacheMaxSize
+	if cacheLimit == 0 {
+		cacheLimit = config.DefaultStreamCacheMaxSize
+	}
+	if useCache && contentLength >= 0 && contentLength <= cacheLimit {
+		body, err := io.ReadAll(stream)
+		resp.CloseBodyStream()
+		fasthttp.ReleaseResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+		result.body = body
+		return result, nil
+	}
+
+	result.contentLength = max(contentLength, -1)
+	result.bodyStream = &readCloser{Reader: stream, close: func() error {
+		err := resp.CloseBodyStream()
+		fasthttp.ReleaseResponse(resp)
+		return err
+	}}
+	return result, nil
+}
@@ -0,0 +1,94 @@
+package router
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/cache"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+)
+
+// chunkedReader 隐藏底层读取器的类型，使请求以分块编码发送
+// chunkedReader hides the type of the underlying reader so the request is sent with chunked encoding
+type chunkedReader struct{ io.Reader }
+
+func TestOversizedChunkedBodyOnCachedRoute(t *testing.T) {
+	const bodyLimit = 64
+
+	var backendRequests atomic.Int32
+	var lastBodySize atomic.Int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendRequests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		lastBodySize.Store(int64(len(body)))
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	manager, err := cache.NewCacheManager(config.Cache{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapCacheManager(manager)
+	defer swapCacheManager(nil)
+
+	app := fiber.New(fiber.Config{
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		BodyLimit:                    bodyLimit,
+	})
+	for _, route := range []config.Route{
+		{Path: "/buffered", Backends: []string{backend.URL}, CacheEnable: true, CacheTTL: 60},
+		{Path: "/streaming", Backends: []string{backend.URL}, CacheEnable: true, CacheTTL: 60, Streaming: true},
+	} {
+		route.Timeouts = route.Timeouts.WithDefaults()
+		app.All(route.Path+"/*", CreateNewHandler(route, true))
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(listener)
+	defer app.Shutdown()
+
+	oversized := strings.Repeat("x", bodyLimit*4)
+	post := func(path string) *http.Response {
+		t.Helper()
+		resp, err := http.Post("http://"+listener.Addr().String()+path, "text/plain", chunkedReader{strings.NewReader(oversized)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// 非流式路由拒绝超过大小限制的分块请求体，不读入内存也不访问后端
+	// A non-streaming route rejects an over-limit chunked body without buffering it or contacting a backend
+	if resp := post("/buffered/item"); resp.StatusCode != fiber.StatusRequestEntityTooLarge {
+		t.Fatalf("buffered route status = %d, want %d", resp.StatusCode, fiber.StatusRequestEntityTooLarge)
+	}
+	if got := backendRequests.Load(); got != 0 {
+		t.Fatalf("backend received %d requests, want 0", got)
+	}
+
+	// 流式路由将请求体完整转发给后端，且不使用缓存
+	// A streaming route forwards the whole body to the backend and bypasses the cache
+	for i := 1; i <= 2; i++ {
+		if resp := post("/streaming/item"); resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("streaming route status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+		}
+		if got := backendRequests.Load(); got != int32(i) {
+			t.Fatalf("backend received %d requests, want %d", got, i)
+		}
+		if got := lastBodySize.Load(); got != int64(len(oversized)) {
+			t.Fatalf("backend received a %d byte body, want %d", got, len(oversized))
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package router
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"github.com/valyala/fasthttp"
+)
+
+// backendDialer 返回带连接超时的拨号函数，拨出的连接会限制等待响应头的时间
+// backendDialer returns a dial function with a connect timeout whose connections limit the wait for response headers
+func backendDialer(timeouts config.Timeouts) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		conn, err := fasthttp.DialTimeout(addr, timeouts.Connect)
+		if err != nil {
+			return nil, err
+		}
+		if timeouts.ResponseHeader <= 0 {
+			return conn, nil
+		}
+		return &responseHeaderConn{Conn: conn, timeout: timeouts.ResponseHeader}, nil
+	}
+}
+
+// responseHeaderConn 在发送请求后限制后端开始响应的时间，收到第一个字节后恢复调用方设置的读取截止时间
+// responseHeaderConn limits how long the backend may take to start responding after a request was written,
+// restoring the caller's read deadline once the first byte arrives
+type responseHeaderConn struct {
+	net.Conn
+	timeout time.Duration
+
+	mutex          sync.Mutex
+	readDeadline   time.Time
+	headerDeadline time.Time
+}
+
+// Write 发送请求并开始计算响应头超时
+// Write sends the request and starts the response header timeout
+func (c *responseHeaderConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if err == nil {
+		c.mutex.Lock()
+		c.headerDeadline = time.Now().Add(c.timeout)
+		c.applyReadDeadline()
+		c.mutex.Unlock()
+	}
+	return n, err
+}
+
+// Read 读取响应，收到数据后取消响应头超时
+// Read reads the response and cancels the response header timeout once data arrives
+func (c *responseHeaderConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.mutex.Lock()
+		if !c.headerDeadline.IsZero() {
+			c.headerDeadline = time.Time{}
+			c.applyReadDeadline()
+		}
+		c.mutex.Unlock()
+	}
+	return n, err
+}
+
+// SetReadDeadline 记录调用方的读取截止时间，并与响应头超时取较早者
+// SetReadDeadline records the caller's read deadline and applies the earlier of it and the response header deadline
+func (c *responseHeaderConn) SetReadDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.readDeadline = t
+	return c.applyReadDeadline()
+}
+
+// SetDeadline 同时设置读取和写入截止时间
+// SetDeadline sets both the read and write deadlines
+func (c *responseHeaderConn) SetDeadline(t time.Time) error {
+	if err := c.Conn.SetWriteDeadline(t); err != nil {
+		return err
+	}
+	return c.SetReadDeadline(t)
+}
+
+// applyReadDeadline 应用当前生效的读取截止时间，调用方需持有锁
+// applyReadDeadline applies the effective read deadline, the caller must hold the mutex
+func (c *responseHeaderConn) applyReadDeadline() error {
+	deadline := c.readDeadline
+	if !c.headerDeadline.IsZero() && (deadline.IsZero() || c.headerDeadline.Before(deadline)) {
+		deadline = c.headerDeadline
+	}
+	return c.Conn.SetReadDeadline(deadline)
+}
+
+// isTimeoutError 判断代理错误是否由连接、响应头或总超时引起
+// isTimeoutError reports whether the proxy error was caused by the connect, response header or total timeout
+func isTimeoutError(err error) bool {
+	if errors.Is(err, fasthttp.ErrDialTimeout) || errors.Is(err, fasthttp.ErrTimeout) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,132 @@
+package router
+
+import (
+	"crypto/tls"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"go.uber.org/zap"
+)
+
+// 当前的 TLS 配置，每次握手时读取，热重载后新连接使用新的证书、版本和密码套件
+// Current TLS config, read on every handshake so new connections use the reloaded certificates, version and cipher
+// suites
+var currentTLSConfig atomic.Pointer[tls.Config]
+
+// buildTLSConfig 根据配置创建 TLS 配置。配置多个证书时 crypto/tls 按 SNI 选择证书，没有匹配时使用第一个。
+// 有路由需要客户端证书时请求客户端证书，证书由 requireClientCert 按路由验证
+// buildTLSConfig creates the TLS config from the config. With several certificates crypto/tls selects one by SNI and
+// falls back to the first. Client certificates are requested when a route requires them and are verified per route by
+// requireClientCert
+func buildTLSConfig(tlsConfig config.TLS, routes []config.Route) (*tls.Config, error) {
+	tlsConfig = tlsConfig.WithDefaults()
+
+	certificates, err := tlsConfig.LoadCertificates()
+	if err != nil {
+		return nil, err
+	}
+	minVersion, err := tlsConfig.Version()
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := tlsConfig.CipherSuiteIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	clientAuth := tls.NoClientCert
+	if slices.ContainsFunc(routes, func(route config.Route) bool { return route.ClientCert.Enabled }) {
+		clientAuth = tls.RequestClientCert
+	}
+
+	return &tls.Config{
+		Certificates: certificates,
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+		ClientAuth:   clientAuth,
+	}, nil
+}
+
+// syncTLS 重新读取证书并替换当前的 TLS 配置，失败时保留当前配置
+// syncTLS reloads the certificates and replaces the current TLS config, keeping the current one on failure
+func syncTLS(tlsConfig config.TLS, routes []config.Route) {
+	if !tlsConfig.Enabled {
+		return
+	}
+
+	next, err := buildTLSConfig(tlsConfig, routes)
+	if err != nil {
+		logger.Error("Failed to load TLS certificates, keeping the current certificates", zap.Error(err))
+		return
+	}
+
+	currentTLSConfig.Store(next)
+	logger.Info("TLS certificates loaded",
+		zap.Int("certificateCount", len(next.Certificates)),
+		zap.String("minVersion", tlsConfig.WithDefaults().MinVersion))
+}
+
+// listenTLS 在 HTTPS 端口上提供网关，明文端口提供相同的路由或重定向到 HTTPS
+// listenTLS serves the gateway on the HTTPS port, while the plain port serves the same routes or redirects to HTTPS
+func listenTLS(app *fiber.App, config_ *config.Config) error {
+	tlsConfig := config_.TLS.WithDefaults()
+
+	tlsAddr := config_.Host + ":" + strconv.Itoa(tlsConfig.Port)
+	ln, err := net.Listen("tcp", tlsAddr)
+	if err != nil {
+		return err
+	}
+	tlsListener := tls.NewListener(ln, &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return currentTLSConfig.Load(), nil
+		},
+	})
+
+	plainAddr := config_.Host + ":" + strconv.Itoa(config_.Port)
+	plainApp := app
+	if tlsConfig.RedirectHTTP {
+		plainApp = fiber.New(fiber.Config{DisableStartupMessage: true})
+		plainApp.All("/*", redirectToHTTPS(tlsConfig.Port))
+	}
+
+	go func() {
+		logger.Info("Starting plain HTTP server", zap.String("address", plainAddr), zap.Bool("redirectHTTP", tlsConfig.RedirectHTTP))
+		if err := plainApp.Listen(plainAddr); err != nil {
+			logger.Fatal("failed to run plain HTTP server", zap.Error(err))
+		}
+	}()
+
+	logger.Info("Starting HTTPS server", zap.String("address", tlsAddr))
+	return app.Listener(tlsListener)
+}
+
+// redirectToHTTPS 将请求重定向到 HTTPS 端口上的相同地址。GET 和 HEAD 使用 301，其他方法使用 308 以保留方法和请求体
+// redirectToHTTPS redirects requests to the same URL on the HTTPS port. GET and HEAD use 301, other methods use 308 so
+// the method and body are kept
+func redirectToHTTPS(tlsPort int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		host := string(c.Request().Host())
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		host = strings.Trim(host, "[]")
+		if tlsPort != config.DefaultTLSPort {
+			host = net.JoinHostPort(host, strconv.Itoa(tlsPort))
+		} else if strings.Contains(host, ":") {
+			// IPv6 地址需要方括号
+			// IPv6 addresses need brackets
+			host = "[" + host + "]"
+		}
+
+		status := fiber.StatusPermanentRedirect
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			status = fiber.StatusMovedPermanently
+		}
+		return c.Redirect("https://"+host+string(c.Request().RequestURI()), status)
+	}
+}
@@ -0,0 +1,56 @@
+package router
+
+import (
+	"crypto/tls"
+	"sync"
+
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"go.uber.org/zap"
+)
+
+// 存储每个路由中各后端的上游 TLS 配置，没有配置的后端使用默认设置
+// Store the upstream TLS config of each backend per route, backends without one use the default settings
+var (
+	upstreamTLSConfigs = make(map[string]map[string]*tls.Config)
+	upstreamTLSMutex   sync.RWMutex
+)
+
+// syncUpstreamTLS 重新读取 CA 证书和客户端证书，读取失败的后端保留当前配置
+// syncUpstreamTLS reloads the CA bundles and client certificates, backends whose files fail to load keep their
+// current config
+func syncUpstreamTLS(routes []config.Route) {
+	upstreamTLSMutex.Lock()
+	defer upstreamTLSMutex.Unlock()
+
+	next := make(map[string]map[string]*tls.Config, len(routes))
+	for _, route := range routes {
+		for _, backend := range route.Backends {
+			clientConfig, err := route.UpstreamTLSFor(backend).ClientConfig()
+			if err != nil {
+				logger.Error("Failed to load upstream TLS config, keeping the current config",
+					zap.String("path", route.Path),
+					zap.String("backend", backend),
+					zap.Error(err))
+				clientConfig = upstreamTLSConfigs[route.ID()][backend]
+			}
+			if clientConfig == nil {
+				continue
+			}
+
+			if next[route.ID()] == nil {
+				next[route.ID()] = make(map[string]*tls.Config)
+			}
+			next[route.ID()][backend] = clientConfig
+		}
+	}
+
+	upstreamTLSConfigs = next
+}
+
+// upstreamTLSConfig 返回连接后端使用的 TLS 配置，没有配置时返回 nil
+// upstreamTLSConfig returns the TLS config used to connect to the backend, or nil when none is configured
+func upstreamTLSConfig(routeID, backend string) *tls.Config {
+	upstreamTLSMutex.RLock()
+	defer upstreamTLSMutex.RUnlock()
+	return upstreamTLSConfigs[routeID][backend]
+}
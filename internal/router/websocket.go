@@ -0,0 +1,343 @@
+package router
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/config"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/loadbalancer"
+	"github.com/nerdneilsfield/simple_api_gateway/internal/metrics"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+// WebSocket 转发时每次读取的缓冲区大小
+// Buffer size of each read when relaying WebSocket traffic
+const webSocketBufferSize = 32 * 1024
+
+// webSocketConnKey 标识 WebSocket 连接所属的路由和后端
+// webSocketConnKey identifies the route and backend a WebSocket connection belongs to
+type webSocketConnKey struct {
+	route   string
+	backend string
+}
+
+// 每个路由和后端上打开的 WebSocket 客户端连接，禁用后端时关闭
+// Open WebSocket client connections per route and backend, closed when the backend is disabled
+var (
+	webSocketConns      = make(map[webSocketConnKey]map[net.Conn]struct{})
+	webSocketConnsMutex sync.Mutex
+)
+
+// trackWebSocket 记录一个打开的 WebSocket 连接，返回的函数在连接关闭后调用
+// trackWebSocket records an open WebSocket connection, the returned function is called after it closed
+func trackWebSocket(route, backend string, conn net.Conn) func() {
+	key := webSocketConnKey{route: route, backend: backend}
+
+	webSocketConnsMutex.Lock()
+	defer webSocketConnsMutex.Unlock()
+	if webSocketConns[key] == nil {
+		webSocketConns[key] = make(map[net.Conn]struct{})
+	}
+	webSocketConns[key][conn] = struct{}{}
+
+	return func() {
+		webSocketConnsMutex.Lock()
+		defer webSocketConnsMutex.Unlock()
+		delete(webSocketConns[key], conn)
+		if len(webSocketConns[key]) == 0 {
+			delete(webSocketConns, key)
+		}
+	}
+}
+
+// closeWebSocketConns 关闭路由中连接到该后端的所有 WebSocket 连接，返回关闭的连接数
+// closeWebSocketConns closes every WebSocket connection of the route to the backend and returns how many were closed
+func closeWebSocketConns(route, backend string) int {
+	key := webSocketConnKey{route: route, backend: backend}
+
+	webSocketConnsMutex.Lock()
+	conns := make([]net.Conn, 0, len(webSocketConns[key]))
+	for conn := range webSocketConns[key] {
+		conns = append(conns, conn)
+	}
+	webSocketConnsMutex.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	return len(conns)
+}
+
+// isWebSocketUpgrade 判断请求是否为 WebSocket 升级请求
+// isWebSocketUpgrade reports whether the request is a WebSocket upgrade
+func isWebSocketUpgrade(c *fiber.Ctx) bool {
+	return c.Context().Request.Header.ConnectionUpgrade() && strings.EqualFold(c.Get(fiber.HeaderUpgrade), "websocket")
+}
+
+// webSocketBackend 已完成握手的 WebSocket 后端连接
+// webSocketBackend is a backend WebSocket connection that completed the handshake
+type webSocketBackend struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	header []byte
+}
+
+// proxyWebSocket 通过负载均衡器选择后端，完成握手后在客户端和后端之间双向转发数据。
+// 连接在负载均衡器中保持活动状态直到关闭
+// proxyWebSocket picks a backend through the load balancer, performs the handshake and relays traffic between the
+// client and the backend in both directions. The connection stays active in the load balancer until it is closed
+func proxyWebSocket(c *fiber.Ctx, lb loadbalancer.LoadBalancer, route config.Route) error {
+	retry := route.Retry.WithDefaults()
+	tried := make([]string, 0, retry.Attempts)
+
+	var lastErr error
+	for attempt := 1; attempt <= retry.Attempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(retryBackoff(attempt-1, retry))
+		}
+
+		startTime := time.Now()
+		backendURL := nextBackend(c, lb, route, tried)
+		if backendURL == "" {
+			break
+		}
+		tried = append(tried, backendURL)
+		c.Locals(localsBackend, backendURL)
+
+		targetFullURL, err := buildTargetURL(c, backendURL, route)
+		if err != nil {
+			// 握手没有发送到后端，只释放选择后端时记录的请求，不计为后端失败
+			// The handshake never reached the backend, so only release it without counting a backend failure
+			lb.Release(backendURL)
+			logger.Error("Error parsing backend URL", zap.String("backend", backendURL), zap.Error(err))
+			return fiber.NewError(fiber.StatusInternalServerError, "Error parsing backend URL")
+		}
+
+		backend, resp, err := dialWebSocketBackend(c, targetFullURL, route, route.TimeoutsFor(backendURL), upstreamTLSConfig(route.ID(), backendURL))
+		switch {
+		case err != nil:
+			metrics.ObserveBackend(route.ID(), backendURL, 0, time.Since(startTime))
+		case resp != nil:
+			metrics.ObserveBackend(route.ID(), backendURL, resp.statusCode, time.Since(startTime))
+		default:
+			metrics.ObserveBackend(route.ID(), backendURL, fiber.StatusSwitchingProtocols, time.Since(startTime))
+		}
+		if err != nil {
+			lb.ReportFailure(backendURL)
+			lastErr = err
+			retryable := attempt < retry.Attempts && isRetryableError(err, retry)
+			logger.Error("WebSocket handshake with backend failed",
+				zap.String("backend", backendURL),
+				zap.Int("attempt", attempt),
+				zap.Bool("retry", retryable),
+				zap.Error(err))
+			if retryable {
+				continue
+			}
+			break
+		}
+
+		// 后端拒绝升级，按普通响应返回给客户端
+		// The backend refused the upgrade, return its response to the client as is
+		if resp != nil {
+			lb.ReportSuccess(backendURL, time.Since(startTime))
+			c.Status(resp.statusCode)
+			for key, values := range resp.headers {
+				for _, value := range values {
+					c.Response().Header.Add(key, value)
+				}
+			}
+			applyResponseHeaderRules(c, route)
+			return c.Send(resp.body)
+		}
+
+		handshakeTime := time.Since(startTime)
+		idleTimeout := route.WebSocketIdleTimeout
+		if idleTimeout == 0 {
+			idleTimeout = config.DefaultWebSocketIdleTimeout
+		}
+
+		logger.Debug("WebSocket connection established",
+			zap.String("path", c.Path()),
+			zap.String("backend", backendURL),
+			zap.Duration("handshakeTime", handshakeTime))
+
+		c.Status(fiber.StatusSwitchingProtocols)
+		c.Context().HijackSetNoResponse(true)
+		c.Context().Hijack(func(clientConn net.Conn) {
+			connectedAt := time.Now()
+			untrack := trackWebSocket(route.ID(), backendURL, clientConn)
+			relayWebSocket(clientConn, backend, idleTimeout)
+			untrack()
+			lb.ReportSuccess(backendURL, handshakeTime)
+			logger.Debug("WebSocket connection closed",
+				zap.String("backend", backendURL),
+				zap.Duration("duration", time.Since(connectedAt)))
+		})
+		return nil
+	}
+
+	return backendError(lastErr)
+}
+
+// dialWebSocketBackend 连接后端并发送升级请求。握手成功时返回后端连接；
+// 后端没有切换协议时关闭连接并返回其响应
+// dialWebSocketBackend connects to the backend and sends the upgrade request. It returns the backend connection when
+// the handshake succeeds, or closes the connection and returns the backend response when it did not switch protocols
+func dialWebSocketBackend(c *fiber.Ctx, targetFullURL string, route config.Route, timeouts config.Timeouts, tlsConfig *tls.Config) (*webSocketBackend, *backendResponse, error) {
+	targetURL, err := url.Parse(targetFullURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	isTLS := targetURL.Scheme == "https" || targetURL.Scheme == "wss"
+
+	conn, err := fasthttp.DialTimeout(fasthttp.AddMissingPort(targetURL.Host, isTLS), timeouts.Connect)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 握手必须在响应头超时内完成
+	// The handshake must complete within the response header timeout
+	if err := conn.SetDeadline(time.Now().Add(min(timeouts.ResponseHeader, timeouts.Total))); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	if isTLS {
+		// 未配置服务器名称时使用后端主机名
+		// Use the backend host name unless a server name is configured
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = targetURL.Hostname()
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn = tlsConn
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	prepareProxyRequest(c, req, targetFullURL, route)
+
+	writer := bufio.NewWriter(conn)
+	if err := req.Write(writer); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if err := writer.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	reader := bufio.NewReader(conn)
+	if err := resp.Read(reader); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	if resp.StatusCode() != fiber.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, &backendResponse{
+			statusCode: resp.StatusCode(),
+			body:       append([]byte(nil), resp.Body()...),
+			headers:    responseHeaders(resp),
+		}, nil
+	}
+
+	// 不要给握手响应添加默认的 Content-Type
+	// Do not add a default Content-Type to the handshake response
+	resp.Header.SetNoDefaultContentType(true)
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return &webSocketBackend{
+		conn:   conn,
+		reader: reader,
+		header: append([]byte(nil), resp.Header.Header()...),
+	}, nil, nil
+}
+
+// relayWebSocket 将后端的握手响应发送给客户端，然后双向转发数据，
+// 任一方向关闭或双方都空闲超过 idleTimeout 时关闭两个连接
+// relayWebSocket sends the backend handshake response to the client and relays traffic in both directions,
+// closing both connections when either side closes or no traffic flows for idleTimeout
+func relayWebSocket(clientConn net.Conn, backend *webSocketBackend, idleTimeout time.Duration) {
+	defer backend.conn.Close()
+
+	if _, err := clientConn.Write(backend.header); err != nil {
+		logger.Debug("Failed to send WebSocket handshake to client", zap.Error(err))
+		return
+	}
+
+	var lastActivity atomic.Int64
+	lastActivity.Store(time.Now().UnixNano())
+
+	var closeOnce sync.Once
+	closeBoth := func() {
+		closeOnce.Do(func() {
+			clientConn.Close()
+			backend.conn.Close()
+		})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		relayIdle(backend.conn, clientConn, clientConn, idleTimeout, &lastActivity)
+	}()
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		relayIdle(clientConn, backend.reader, backend.conn, idleTimeout, &lastActivity)
+	}()
+	wg.Wait()
+}
+
+// relayIdle 从 src 复制数据到 dst，直到出错或连接双向空闲超过 idleTimeout
+// relayIdle copies from src to dst until an error occurs or the connection was idle in both directions for idleTimeout
+func relayIdle(dst io.Writer, src io.Reader, srcConn net.Conn, idleTimeout time.Duration, lastActivity *atomic.Int64) {
+	buf := make([]byte, webSocketBufferSize)
+	for {
+		if err := srcConn.SetReadDeadline(time.Now().Add(idleTimeout)); err != nil {
+			return
+		}
+
+		n, err := src.Read(buf)
+		if n > 0 {
+			lastActivity.Store(time.Now().UnixNano())
+			if _, writeErr := dst.Write(buf[:n]); writeErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			// 另一个方向仍有数据时继续等待
+			// Keep waiting while the other direction still carries traffic
+			if isTimeoutError(err) && time.Since(time.Unix(0, lastActivity.Load())) < idleTimeout {
+				continue
+			}
+			return
+		}
+	}
+}